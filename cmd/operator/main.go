@@ -27,12 +27,14 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/time/rate"
 	"k8s.io/api/core/v1"
 	extsclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
@@ -43,6 +45,7 @@ import (
 
 	"github.com/nats-io/nats-operator/pkg/chaos"
 	natsclientset "github.com/nats-io/nats-operator/pkg/client/clientset/versioned"
+	natsscheme "github.com/nats-io/nats-operator/pkg/client/clientset/versioned/scheme"
 	"github.com/nats-io/nats-operator/pkg/constants"
 	"github.com/nats-io/nats-operator/pkg/controller"
 	"github.com/nats-io/nats-operator/pkg/debug"
@@ -50,10 +53,13 @@ import (
 	"github.com/nats-io/nats-operator/pkg/features"
 	kubernetesutil "github.com/nats-io/nats-operator/pkg/util/kubernetes"
 	"github.com/nats-io/nats-operator/pkg/util/probe"
+	"github.com/nats-io/nats-operator/pkg/webhook"
 	"github.com/nats-io/nats-operator/version"
 )
 
 const (
+	// metricsEndpoint is the path on which Prometheus metrics are served.
+	metricsEndpoint = "/metrics"
 	// featureGatesFlagName is the name of the flag used to define feature gates.
 	featureGatesFlagName = "feature-gates"
 	// natsOperatorName is the string used to detect whether a given pod is a nats-operator pod.
@@ -72,6 +78,13 @@ var (
 
 	// featureGates is a comma-separated list of "key=value" pairs used to toggle certain features.
 	featureGates string
+
+	// webhookListenAddr is the address on which the admission webhooks are served.
+	webhookListenAddr string
+	// webhookCertFile is the path to the certificate used to serve the admission webhooks.
+	webhookCertFile string
+	// webhookKeyFile is the path to the private key used to serve the admission webhooks.
+	webhookKeyFile string
 )
 
 func init() {
@@ -81,6 +94,9 @@ func init() {
 	flag.StringVar(&local.PodName, "debug-pod-name", "nats-operator-debug", "the name of the pod which to report to EventRecorder (only for local debugging).")
 
 	flag.StringVar(&listenAddr, "listen-addr", "0.0.0.0:8080", "The address on which the HTTP server will listen to")
+	flag.StringVar(&webhookListenAddr, "webhook-listen-addr", "0.0.0.0:8443", "The address on which the admission webhooks will be served")
+	flag.StringVar(&webhookCertFile, "webhook-cert-file", "", "the path to the certificate used to serve the admission webhooks (the admission webhooks are only served if both this flag and --webhook-key-file are set)")
+	flag.StringVar(&webhookKeyFile, "webhook-key-file", "", "the path to the private key used to serve the admission webhooks")
 	// chaos level will be removed once we have a formal tool to inject failures.
 	flag.IntVar(&chaosLevel, "chaos-level", -1, "DO NOT USE IN PRODUCTION - level of chaos injected into the nats clusters created by the operator.")
 	flag.BoolVar(&printVersion, "version", false, "Show version and quit")
@@ -159,8 +175,19 @@ func main() {
 	}
 
 	http.HandleFunc(probe.HTTPReadyzEndpoint, probe.ReadyzHandler)
+	http.Handle(metricsEndpoint, promhttp.Handler())
 	go http.ListenAndServe(listenAddr, nil)
 
+	// Serve the admission webhooks if a certificate and private key have been provided.
+	// This is done by every instance of nats-operator (and not only by the leader) so that admission requests can be load-balanced across all instances.
+	if webhookCertFile != "" && webhookKeyFile != "" {
+		go func() {
+			if err := webhook.NewServer(kubeClient).Run(context.Background(), webhookListenAddr, webhookCertFile, webhookKeyFile); err != nil {
+				logrus.Fatalf("failed to serve admission webhooks: %v", err)
+			}
+		}()
+	}
+
 	rl, err := resourcelock.New(resourcelock.EndpointsResourceLock,
 		namespace,
 		"nats-operator",
@@ -228,6 +255,7 @@ func newControllerConfig(featureMap features.FeatureMap, kubeConfig *rest.Config
 		KubeExtCli:            extsClient,
 		OperatorCli:           natsClient,
 		KubeConfig:            kubeConfig,
+		EventRecorder:         createRecorder(kubeClient.CoreV1(), name, namespace),
 	}
 }
 
@@ -268,7 +296,11 @@ func startChaos(ctx context.Context, kubecli corev1client.CoreV1Interface, ns st
 	}
 }
 
+// createRecorder returns an event recorder reporting the current instance of nats-operator as the source of events.
+// Events concerning both Kubernetes and nats.io/v1alpha2 resources may be recorded.
 func createRecorder(kubecli corev1client.CoreV1Interface, name, namespace string) record.EventRecorder {
+	// Our API types must be known to the scheme used by the recorder so that references to NatsCluster resources can be built.
+	utilruntime.Must(natsscheme.AddToScheme(scheme.Scheme))
 	eventBroadcaster := record.NewBroadcaster()
 	eventBroadcaster.StartLogging(logrus.Infof)
 	eventBroadcaster.StartRecordingToSink(&corev1client.EventSinkImpl{Interface: corev1client.New(kubecli.RESTClient()).Events(namespace)})
@@ -39,6 +39,10 @@ func main() {
 	fs.IntVar(&nconfig.MaxRetries, "max-retries", 5, "Max attempts to trigger reload")
 	fs.IntVar(&nconfig.RetryWaitSecs, "retry-wait-secs", 2, "Time to back off when reloading fails before retrying")
 
+	rconfig := &natsreloader.ReadinessConfig{}
+	fs.StringVar(&rconfig.ListenAddr, "readiness-addr", "", "Address on which to serve the readiness check of the NATS Server (disabled if empty)")
+	fs.StringVar(&rconfig.MonitoringURL, "monitoring-url", "http://localhost:8222", "NATS Server Monitoring URL")
+
 	fs.Parse(os.Args[1:])
 
 	switch {
@@ -55,6 +59,23 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Readiness check.
+	if rconfig.ListenAddr != "" {
+		rconfig.ConfigFile = nconfig.ConfigFile
+		rconfig.Hostname, err = os.Hostname()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(1)
+		}
+		rc := natsreloader.NewReadinessChecker(rconfig)
+		go func() {
+			if err := rc.Run(context.Background()); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
 	// Signal handling.
 	go func() {
 		c := make(chan os.Signal, 1)
@@ -18,14 +18,18 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"reflect"
+	"sort"
 	"strings"
-	"time"
 
 	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 
+	natsconf "github.com/nats-io/nats-operator/pkg/conf"
 	"github.com/nats-io/nats-operator/pkg/constants"
+	"github.com/nats-io/nats-operator/pkg/util/semver"
 )
 
 const (
@@ -39,6 +43,16 @@ const (
 	// annotation that holds the hash of the comma-separated list
 	// of NatsServiceRole UIDs associated with the NATS cluster.
 	natsServiceRolesHashAnnotationKey = "nats.io/nsr"
+
+	// approvedUpgradeVersionAnnotationKey is the key of the
+	// annotation that holds the version to which a canary
+	// upgrade has been approved to proceed.
+	approvedUpgradeVersionAnnotationKey = "nats.io/approve-upgrade"
+
+	// ClusterFinalizer is the finalizer added to NatsCluster
+	// resources so that their members are gracefully removed
+	// before the resources are deleted.
+	ClusterFinalizer = "nats.io/teardown"
 )
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -53,7 +67,6 @@ type NatsClusterList struct {
 // NatsCluster is a NATS cluster.
 //
 // +genclient
-// +genclient:noStatus
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 type NatsCluster struct {
 	metav1.TypeMeta   `json:",inline"`
@@ -105,7 +118,7 @@ type ClusterSpec struct {
 
 	// Pod defines the policy to create pod for the NATS pod.
 	//
-	// Updating Pod does not take effect on any existing NATS pods.
+	// Updating Pod causes existing NATS pods to be replaced one at a time.
 	Pod *PodPolicy `json:"pod,omitempty"`
 
 	// TLS is the configuration to secure the cluster.
@@ -114,6 +127,14 @@ type ClusterSpec struct {
 	// Auth is the configuration to set permissions for users.
 	Auth *AuthConfig `json:"auth,omitempty"`
 
+	// Accounts is the list of accounts (NATS 2.x) isolating the users of the cluster from one another.
+	// Messages only flow between accounts by means of exports and imports.
+	// Requires version 2.0.0 or later.
+	Accounts []*AccountConfig `json:"accounts,omitempty"`
+
+	// SystemAccount is the name of the account (among the ones in "accounts") used by the servers to exchange system events.
+	SystemAccount string `json:"systemAccount,omitempty"`
+
 	// LameDuckDurationSeconds is the number of seconds during
 	// which the server spreads the closing of clients when
 	// signaled to go into "lame duck mode".
@@ -128,6 +149,271 @@ type ClusterSpec struct {
 
 	// ExtraRoutes is a list of extra routes to which the cluster will connect.
 	ExtraRoutes []*ExtraRoute `json:"extraRoutes,omitempty"`
+
+	// Scaling is the policy to follow when changing the size of the cluster.
+	// If unset, pods are created and removed one at a time.
+	Scaling *ScalingPolicy `json:"scaling,omitempty"`
+
+	// Storage is the persistent storage to give to each member of the cluster.
+	// If unset, members only use ephemeral storage.
+	//
+	// This field cannot be updated once the cluster is created.
+	Storage *StorageConfig `json:"storage,omitempty"`
+
+	// DisruptionBudget is the configuration of the PodDisruptionBudget protecting the cluster against voluntary disruptions (such as node drains).
+	// If unset, no PodDisruptionBudget is created.
+	DisruptionBudget *DisruptionBudgetPolicy `json:"disruptionBudget,omitempty"`
+
+	// Upgrade is the policy to follow when changing the version of the cluster.
+	// If unset, failed upgrades are retried indefinitely.
+	Upgrade *UpgradePolicy `json:"upgrade,omitempty"`
+
+	// UpgradeStrategy defines which members of the cluster are upgraded when the version changes.
+	// If unset, all members are upgraded one at a time.
+	UpgradeStrategy *UpgradeStrategy `json:"upgradeStrategy,omitempty"`
+
+	// MeshHealth is the policy to follow when periodically checking that the members of the cluster form a full mesh.
+	// If unset, the mesh is only checked while replacing members.
+	MeshHealth *MeshHealthPolicy `json:"meshHealth,omitempty"`
+}
+
+// MeshHealthPolicy defines how often the route mesh of the cluster is checked, and whether members causing it to be unhealthy are restarted.
+type MeshHealthPolicy struct {
+	// PeriodSeconds is the number of seconds between two consecutive checks of the route mesh.
+	// (default: 30)
+	PeriodSeconds int64 `json:"periodSeconds,omitempty"`
+
+	// RestartUnhealthyMembers indicates whether members causing the mesh to be unhealthy (e.g. isolated members) are replaced.
+	RestartUnhealthyMembers bool `json:"restartUnhealthyMembers,omitempty"`
+
+	// RestartAfterSeconds is the number of seconds the mesh must have been unhealthy for before a member is replaced.
+	// (default: 120)
+	RestartAfterSeconds int64 `json:"restartAfterSeconds,omitempty"`
+}
+
+// UpgradeStrategyType is the type of an upgrade strategy.
+type UpgradeStrategyType string
+
+const (
+	// UpgradeStrategyTypePartition is used to upgrade only the members whose ordinal is greater than or equal to a given value.
+	UpgradeStrategyTypePartition UpgradeStrategyType = "Partition"
+	// UpgradeStrategyTypeCanary is used to upgrade a number of members first, and hold the rest of the upgrade until it is approved.
+	UpgradeStrategyTypeCanary UpgradeStrategyType = "Canary"
+)
+
+// UpgradeStrategy defines which members of the cluster are upgraded when the version changes.
+type UpgradeStrategy struct {
+	// Type is the type of the upgrade strategy, either "Partition" or "Canary".
+	Type UpgradeStrategyType `json:"type"`
+
+	// Partition holds the parameters of the "Partition" strategy.
+	Partition *PartitionUpgradeStrategy `json:"partition,omitempty"`
+
+	// Canary holds the parameters of the "Canary" strategy.
+	Canary *CanaryUpgradeStrategy `json:"canary,omitempty"`
+}
+
+// PartitionUpgradeStrategy upgrades only the members whose ordinal is greater than or equal to a given value.
+// The ordinal of a member is the (hexadecimal) number at the end of the name of its pod.
+type PartitionUpgradeStrategy struct {
+	// Ordinal is the ordinal from which members are upgraded.
+	// Members with a lower ordinal (including ones created in the meantime) keep running the current version of the cluster.
+	Ordinal int `json:"ordinal"`
+}
+
+// CanaryUpgradeStrategy upgrades a number of members first, and holds the rest of the upgrade until they have soaked for a given period and the upgrade has been approved.
+// An upgrade to a given version is approved by setting the "nats.io/approve-upgrade" annotation of the NatsCluster resource to that version.
+type CanaryUpgradeStrategy struct {
+	// Replicas is the number of members to upgrade before holding the upgrade.
+	Replicas int `json:"replicas"`
+
+	// SoakSeconds is the number of seconds to wait after the canary members have been upgraded before the upgrade may proceed.
+	SoakSeconds int64 `json:"soakSeconds,omitempty"`
+}
+
+// UpgradePolicy defines when a version upgrade is considered to have failed, in which case the cluster is rolled back to the last known-good version.
+// A rolled back upgrade is not attempted again until the spec changes.
+type UpgradePolicy struct {
+	// FailureThreshold is the number of upgraded pods that may fail before the upgrade is rolled back.
+	// An upgraded pod fails when it terminates with an error or doesn't become ready within TimeoutSeconds.
+	// (default: 3)
+	FailureThreshold int `json:"failureThreshold,omitempty"`
+
+	// TimeoutSeconds is the number of seconds an upgraded pod has to become ready before it is considered to have failed.
+	// (default: 300)
+	TimeoutSeconds int64 `json:"timeoutSeconds,omitempty"`
+}
+
+// DisruptionBudgetPolicy defines how many members of the cluster may be disrupted at once.
+// At most one of MaxUnavailable and MinAvailable may be set.
+type DisruptionBudgetPolicy struct {
+	// MaxUnavailable is the maximum number of members that may be unavailable at once.
+	// As PodDisruptionBudgets only support "maxUnavailable" for pods managed by built-in controllers, it is translated into a minimum number of available members based on ".spec.size".
+	// (default: 1)
+	MaxUnavailable *int32 `json:"maxUnavailable,omitempty"`
+
+	// MinAvailable is the minimum number of members that must remain available.
+	MinAvailable *int32 `json:"minAvailable,omitempty"`
+}
+
+// GetMinAvailable returns the minimum number of members that must remain available in a cluster with the specified size.
+func (p *DisruptionBudgetPolicy) GetMinAvailable(size int) int32 {
+	if p.MinAvailable != nil {
+		return *p.MinAvailable
+	}
+	maxUnavailable := int32(1)
+	if p.MaxUnavailable != nil {
+		maxUnavailable = *p.MaxUnavailable
+	}
+	if min := int32(size) - maxUnavailable; min > 0 {
+		return min
+	}
+	return 0
+}
+
+// StorageConfig defines the persistent volume claim created for each member of the cluster.
+// Claims are bound to the name of the member, so that a replacement pod gets the same volume re-attached.
+// Claims are not deleted when the cluster is scaled down, but are deleted along with the cluster.
+type StorageConfig struct {
+	// StorageClassName is the name of the storage class to use.
+	// If unset, the default storage class is used.
+	StorageClassName *string `json:"storageClassName,omitempty"`
+
+	// Size is the requested size of each volume.
+	Size resource.Quantity `json:"size"`
+
+	// AccessModes are the access modes requested for each volume.
+	// (default: ReadWriteOnce)
+	AccessModes []v1.PersistentVolumeAccessMode `json:"accessModes,omitempty"`
+
+	// MountPath is the path on which each volume is mounted in the NATS container.
+	// (default: /data)
+	MountPath string `json:"mountPath,omitempty"`
+}
+
+// ScalingPolicy defines how many pods may be created or removed at once when changing the size of the cluster.
+type ScalingPolicy struct {
+	// MaxParallelCreations is the maximum number of pods to create at once when scaling up.
+	// Routes are recomputed once per batch of created pods.
+	// (default: 1)
+	MaxParallelCreations int `json:"maxParallelCreations,omitempty"`
+
+	// MaxParallelDeletions is the maximum number of pods to remove at once when scaling down.
+	// Routes are recomputed once per batch of removed pods.
+	// (default: 1)
+	MaxParallelDeletions int `json:"maxParallelDeletions,omitempty"`
+}
+
+// AntiAffinityMode indicates whether an anti-affinity term must be satisfied for a pod to be scheduled, or is just a preference.
+type AntiAffinityMode string
+
+const (
+	// AntiAffinityModeRequired indicates that an anti-affinity term must be satisfied for a pod to be scheduled.
+	AntiAffinityModeRequired AntiAffinityMode = "Required"
+	// AntiAffinityModePreferred indicates that the scheduler should try to satisfy an anti-affinity term, but may schedule a pod anyway.
+	AntiAffinityModePreferred AntiAffinityMode = "Preferred"
+)
+
+// UnsatisfiableConstraintAction indicates how a spread constraint is dealt with when it cannot be satisfied.
+type UnsatisfiableConstraintAction string
+
+const (
+	// UnsatisfiableConstraintActionDoNotSchedule indicates that a pod must not be scheduled in a topology domain that would violate a spread constraint.
+	UnsatisfiableConstraintActionDoNotSchedule UnsatisfiableConstraintAction = "DoNotSchedule"
+	// UnsatisfiableConstraintActionScheduleAnyway indicates that the scheduler should prefer the topology domains that satisfy a spread constraint, but may schedule a pod anyway.
+	UnsatisfiableConstraintActionScheduleAnyway UnsatisfiableConstraintAction = "ScheduleAnyway"
+)
+
+// TopologyPolicy defines how the members of the cluster are spread across topology domains.
+type TopologyPolicy struct {
+	// AntiAffinity is the list of topology domains across which members should be spread.
+	AntiAffinity []TopologyAntiAffinityTerm `json:"antiAffinity,omitempty"`
+
+	// SpreadConstraints is the list of topology domains across which members should be evenly spread.
+	SpreadConstraints []TopologySpreadConstraint `json:"spreadConstraints,omitempty"`
+}
+
+// TopologyAntiAffinityTerm prevents (or discourages) two members of the cluster from being placed in the same topology domain.
+type TopologyAntiAffinityTerm struct {
+	// TopologyKey is the label of the nodes that defines the topology domain
+	// (e.g. "kubernetes.io/hostname" or "failure-domain.beta.kubernetes.io/zone").
+	TopologyKey string `json:"topologyKey"`
+
+	// Mode indicates whether the term must be satisfied ("Required") or is
+	// just a preference ("Preferred").
+	// (default: Preferred)
+	Mode AntiAffinityMode `json:"mode,omitempty"`
+
+	// Weight is the weight of a preferred term, in the range 1-100.
+	// It is ignored for required terms.
+	// (default: 100)
+	Weight int32 `json:"weight,omitempty"`
+}
+
+// TopologySpreadConstraint limits how unevenly the members of the cluster may be spread across the domains of a topology key.
+// The version of the Kubernetes API nats-operator is built against has no topology spread constraints, so nats-operator enforces these itself when creating pods:
+// each new pod gets a node affinity restricting it to the domains in which it may be placed, computed from the labels of the nodes and the pods that have already been scheduled.
+// Pods are not created while no domain satisfies a "DoNotSchedule" constraint (e.g. until the pods created previously are scheduled).
+// Only the node selector of the pods is taken into account when finding the domains in which pods may be placed, and existing pods are never moved to restore the balance.
+type TopologySpreadConstraint struct {
+	// TopologyKey is the label of the nodes that defines the topology domain
+	// (e.g. "failure-domain.beta.kubernetes.io/zone").
+	// Nodes without this label are not eligible.
+	TopologyKey string `json:"topologyKey"`
+
+	// MaxSkew is the maximum difference between the number of members in any
+	// two domains of the topology key, which must be at least 1.
+	MaxSkew int32 `json:"maxSkew"`
+
+	// WhenUnsatisfiable indicates whether a pod must not be placed in a domain
+	// that would violate the constraint ("DoNotSchedule"), or whether the
+	// domains that satisfy it are just preferred ("ScheduleAnyway").
+	// (default: DoNotSchedule)
+	WhenUnsatisfiable UnsatisfiableConstraintAction `json:"whenUnsatisfiable,omitempty"`
+}
+
+// ReadinessProbePolicy holds the timings of the readiness probe of the NATS container.
+// Fields left unset take the default values used by Kubernetes.
+type ReadinessProbePolicy struct {
+	// InitialDelaySeconds is the number of seconds after the container has
+	// started before the readiness probe is initiated.
+	InitialDelaySeconds int32 `json:"initialDelaySeconds,omitempty"`
+
+	// TimeoutSeconds is the number of seconds after which the probe times out.
+	TimeoutSeconds int32 `json:"timeoutSeconds,omitempty"`
+
+	// PeriodSeconds is how often (in seconds) to perform the probe.
+	PeriodSeconds int32 `json:"periodSeconds,omitempty"`
+
+	// SuccessThreshold is the minimum number of consecutive successes for the
+	// probe to be considered successful after having failed.
+	SuccessThreshold int32 `json:"successThreshold,omitempty"`
+
+	// FailureThreshold is the minimum number of consecutive failures for the
+	// probe to be considered failed after having succeeded.
+	FailureThreshold int32 `json:"failureThreshold,omitempty"`
+}
+
+// GetMaxParallelCreations returns the maximum number of pods to create at once when scaling up.
+func (p *ScalingPolicy) GetMaxParallelCreations() int {
+	if p == nil || p.MaxParallelCreations < 1 {
+		return 1
+	}
+	return p.MaxParallelCreations
+}
+
+// GetMaxParallelDeletions returns the maximum number of pods to remove at once when scaling down.
+func (p *ScalingPolicy) GetMaxParallelDeletions() int {
+	if p == nil || p.MaxParallelDeletions < 1 {
+		return 1
+	}
+	return p.MaxParallelDeletions
+}
+
+// reservedConfigKeys holds the keys of the configuration for the NATS server which are managed by nats-operator, and hence cannot be set via "spec.natsConfig.raw", keyed by the enclosing block.
+var reservedConfigKeys = map[string][]string{
+	"":        {"host", "net", "listen", "port", "http", "http_port", "monitor_port", "https", "https_port", "client_advertise", "include", "authorization", "tls", "accounts", "system_account"},
+	"cluster": {"host", "listen", "port", "routes", "advertise", "cluster_advertise", "authorization", "tls"},
 }
 
 // ServerConfig is extra configuration for the NATS server.
@@ -141,6 +427,75 @@ type ServerConfig struct {
 	MaxSubscriptions int    `json:"maxSubscriptions,omitempty"`
 	MaxControlLine   int    `json:"maxControlLine,omitempty"`
 	DisableLogtime   bool   `json:"disableLogtime,omitempty"`
+
+	// Raw is a snippet of configuration in the NATS configuration format, which is deep-merged on top of the configuration generated by nats-operator.
+	// It allows for setting options which have no matching field (e.g. "ping_interval"), but must not set any of the keys managed by nats-operator (such as ports and routes).
+	Raw string `json:"raw,omitempty"`
+	// RawFrom references a key of a ConfigMap or Secret in the namespace of the cluster holding a snippet of configuration, which is used in the same way as "raw".
+	RawFrom *RawConfigSource `json:"rawFrom,omitempty"`
+}
+
+// RawConfigSource references a snippet of configuration for the NATS server held in a ConfigMap or Secret.
+// Exactly one of its fields must be set.
+type RawConfigSource struct {
+	// ConfigMapKeyRef selects a key of a ConfigMap.
+	ConfigMapKeyRef *v1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+	// SecretKeyRef selects a key of a Secret.
+	SecretKeyRef *v1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+}
+
+// ReferencesConfigMap returns whether the specified ConfigMap holds the snippet of configuration for the NATS server.
+func (s *RawConfigSource) ReferencesConfigMap(name string) bool {
+	return s != nil && s.ConfigMapKeyRef != nil && s.ConfigMapKeyRef.Name == name
+}
+
+// ReferencesSecret returns whether the specified Secret holds the snippet of configuration for the NATS server.
+func (s *RawConfigSource) ReferencesSecret(name string) bool {
+	return s != nil && s.SecretKeyRef != nil && s.SecretKeyRef.Name == name
+}
+
+// ParseRawConfig parses the specified snippet of configuration for the NATS server, as found in ".spec.natsConfig.raw".
+// It makes sure that the snippet doesn't set any of the keys managed by nats-operator, and that the values it holds for known keys are of the expected type.
+func ParseRawConfig(raw string) (map[string]interface{}, error) {
+	overlay, err := natsconf.Parse([]byte(raw))
+	if err != nil {
+		return nil, err
+	}
+	reserved := make([]string, 0)
+	for path, keys := range reservedConfigKeys {
+		block := overlay
+		if path != "" {
+			if block = findBlock(overlay, path); block == nil {
+				continue
+			}
+		}
+		for key := range block {
+			for _, k := range keys {
+				if strings.EqualFold(key, k) {
+					reserved = append(reserved, strings.TrimPrefix(path+"."+key, "."))
+				}
+			}
+		}
+	}
+	if len(reserved) > 0 {
+		sort.Strings(reserved)
+		return nil, fmt.Errorf("keys managed by nats-operator cannot be set: %s", strings.Join(reserved, ", "))
+	}
+	if err := natsconf.Merge(&natsconf.ServerConfig{}, overlay); err != nil {
+		return nil, err
+	}
+	return overlay, nil
+}
+
+// findBlock returns the block found under the specified key of the specified configuration (ignoring case, as the NATS server does), or nil if there is none.
+func findBlock(conf map[string]interface{}, key string) map[string]interface{} {
+	for k, v := range conf {
+		if strings.EqualFold(k, key) {
+			block, _ := v.(map[string]interface{})
+			return block
+		}
+	}
+	return nil
 }
 
 // ExtraRoute is a route that is not originally part of the NatsCluster
@@ -217,10 +572,15 @@ type PodPolicy struct {
 
 	// AntiAffinity determines if the nats-operator tries to avoid putting
 	// the NATS members in the same cluster onto the same node.
+	// It is ignored if Topology is set.
 	AntiAffinity bool `json:"antiAffinity,omitempty"`
 
+	// Topology defines how the NATS members in the same cluster are spread
+	// across topology domains (such as nodes, zones or regions).
+	Topology *TopologyPolicy `json:"topology,omitempty"`
+
 	// Resources is the resource requirements for the NATS container.
-	// This field cannot be updated once the cluster is created.
+	// Updating this field causes existing NATS pods to be replaced one at a time.
 	Resources v1.ResourceRequirements `json:"resources,omitempty"`
 
 	// Tolerations specifies the pod's tolerations.
@@ -242,6 +602,12 @@ type PodPolicy struct {
 	// ReloaderImagePullPolicy is the pull policy for the reloader image.
 	ReloaderImagePullPolicy string `json:"reloaderImagePullPolicy,omitempty"`
 
+	// ReadinessProbe makes each NATS container report ready only once the
+	// server has connected to the routes of all the other members of the cluster.
+	// The readiness check is served by the config reloader sidecar, and hence
+	// requires EnableConfigReload.
+	ReadinessProbe *ReadinessProbePolicy `json:"readinessProbe,omitempty"`
+
 	// EnableMetrics attaches a sidecar to each NATS Server
 	// that will export prometheus metrics.
 	EnableMetrics bool `json:"enableMetrics,omitempty"`
@@ -295,20 +661,361 @@ type AuthConfig struct {
 	TLSVerifyAndMap bool `json:"tlsVerifyAndMap,omitempty"`
 }
 
+// AccountConfig is an account (NATS 2.x) along with its users and the subjects it shares with other accounts.
+type AccountConfig struct {
+	// Name is the name of the account, which must be unique within the cluster.
+	Name string `json:"name"`
+
+	// Users is the list of users that belong to the account.
+	Users []*AccountUser `json:"users,omitempty"`
+
+	// Exports is the list of streams and services that the account makes available to other accounts.
+	Exports []*AccountExport `json:"exports,omitempty"`
+
+	// Imports is the list of streams and services exported by other accounts that the account makes use of.
+	Imports []*AccountImport `json:"imports,omitempty"`
+}
+
+// AccountUser is a user belonging to an account.
+type AccountUser struct {
+	// Username is the name of the user, which must be unique within the cluster.
+	Username string `json:"username"`
+
+	// PasswordSecretRef selects the key of a Secret in the namespace of the cluster holding the password of the user.
+	// The password may be either in plain text or hashed with bcrypt.
+	PasswordSecretRef *v1.SecretKeySelector `json:"passwordSecretRef"`
+
+	// Permissions are the subjects to which the user may publish and subscribe within the account.
+	// If unset, the user may publish and subscribe to any subject.
+	Permissions *Permissions `json:"permissions,omitempty"`
+}
+
+// AccountExport makes either a stream or a service of an account available to other accounts.
+// Exactly one of "stream" and "service" must be set.
+type AccountExport struct {
+	// Stream is the subject (possibly with wildcards) of the messages published by the account which other accounts may subscribe to.
+	Stream string `json:"stream,omitempty"`
+
+	// Service is the subject (possibly with wildcards) on which the account answers requests made by other accounts.
+	Service string `json:"service,omitempty"`
+
+	// Accounts is the list of accounts allowed to import the stream or service.
+	// If empty, any account may import it.
+	Accounts []string `json:"accounts,omitempty"`
+}
+
+// AccountImport brings either a stream or a service exported by another account into an account.
+// Exactly one of "stream" and "service" must be set.
+type AccountImport struct {
+	// Stream is the stream to import.
+	Stream *AccountImportSource `json:"stream,omitempty"`
+
+	// Service is the service to import.
+	Service *AccountImportSource `json:"service,omitempty"`
+
+	// Prefix is the prefix added to the subjects of an imported stream.
+	Prefix string `json:"prefix,omitempty"`
+
+	// To is the subject on which an imported service is made available within the account.
+	// If unset, the service is made available on its original subject.
+	To string `json:"to,omitempty"`
+}
+
+// AccountImportSource is the account and subject from which a stream or service is imported.
+type AccountImportSource struct {
+	// Account is the name of the exporting account.
+	Account string `json:"account"`
+
+	// Subject is the exported subject.
+	Subject string `json:"subject"`
+}
+
+// ReferencesAccountSecret returns whether the specified Secret holds the password of any of the users of the accounts in the spec.
+func (c *ClusterSpec) ReferencesAccountSecret(name string) bool {
+	for _, account := range c.Accounts {
+		for _, user := range account.Users {
+			if user.PasswordSecretRef != nil && user.PasswordSecretRef.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Validate checks whether the spec is valid, returning an error describing the first problem found otherwise.
 func (c *ClusterSpec) Validate() error {
+	if c.Size < 1 {
+		return fmt.Errorf("spec: size must be a positive integer (got %d)", c.Size)
+	}
+	if len(c.Version) > 0 {
+		if _, err := semver.Parse(c.Version); err != nil {
+			return fmt.Errorf("spec: invalid version: %v", err)
+		}
+	}
 	if c.Pod != nil {
 		for k := range c.Pod.Labels {
 			if k == "app" || strings.HasPrefix(k, "nats_") {
 				return errors.New("spec: pod labels contains reserved label")
 			}
 		}
+		if c.Pod.ReadinessProbe != nil && !c.Pod.EnableConfigReload {
+			return errors.New("spec: pod: readinessProbe requires enableConfigReload to be set")
+		}
+		if c.Pod.Topology != nil {
+			for _, term := range c.Pod.Topology.AntiAffinity {
+				if len(term.TopologyKey) == 0 {
+					return errors.New("spec: pod: topology: antiAffinity: topologyKey must be set")
+				}
+				if term.Mode != "" && term.Mode != AntiAffinityModeRequired && term.Mode != AntiAffinityModePreferred {
+					return fmt.Errorf("spec: pod: topology: antiAffinity: invalid mode %q", term.Mode)
+				}
+				if term.Weight < 0 || term.Weight > 100 {
+					return fmt.Errorf("spec: pod: topology: antiAffinity: weight must be in the range 1-100 (got %d)", term.Weight)
+				}
+			}
+			topologyKeys := make(map[string]bool, len(c.Pod.Topology.SpreadConstraints))
+			for _, constraint := range c.Pod.Topology.SpreadConstraints {
+				if len(constraint.TopologyKey) == 0 {
+					return errors.New("spec: pod: topology: spreadConstraints: topologyKey must be set")
+				}
+				if topologyKeys[constraint.TopologyKey] {
+					return fmt.Errorf("spec: pod: topology: spreadConstraints: duplicate topologyKey %q", constraint.TopologyKey)
+				}
+				topologyKeys[constraint.TopologyKey] = true
+				if constraint.MaxSkew < 1 {
+					return fmt.Errorf("spec: pod: topology: spreadConstraints: maxSkew must be a positive integer (got %d)", constraint.MaxSkew)
+				}
+				if constraint.WhenUnsatisfiable != "" && constraint.WhenUnsatisfiable != UnsatisfiableConstraintActionDoNotSchedule && constraint.WhenUnsatisfiable != UnsatisfiableConstraintActionScheduleAnyway {
+					return fmt.Errorf("spec: pod: topology: spreadConstraints: invalid whenUnsatisfiable %q", constraint.WhenUnsatisfiable)
+				}
+			}
+		}
+	}
+	if c.ServerConfig != nil {
+		if len(c.ServerConfig.Raw) > 0 && c.ServerConfig.RawFrom != nil {
+			return errors.New("spec: natsConfig: raw and rawFrom are mutually exclusive")
+		}
+		if len(c.ServerConfig.Raw) > 0 {
+			if _, err := ParseRawConfig(c.ServerConfig.Raw); err != nil {
+				return fmt.Errorf("spec: natsConfig: raw: %v", err)
+			}
+		}
+		if s := c.ServerConfig.RawFrom; s != nil {
+			switch {
+			case (s.ConfigMapKeyRef == nil) == (s.SecretKeyRef == nil):
+				return errors.New("spec: natsConfig: rawFrom: exactly one of configMapKeyRef and secretKeyRef must be set")
+			case s.ConfigMapKeyRef != nil && (len(s.ConfigMapKeyRef.Name) == 0 || len(s.ConfigMapKeyRef.Key) == 0):
+				return errors.New("spec: natsConfig: rawFrom: configMapKeyRef: name and key must be set")
+			case s.SecretKeyRef != nil && (len(s.SecretKeyRef.Name) == 0 || len(s.SecretKeyRef.Key) == 0):
+				return errors.New("spec: natsConfig: rawFrom: secretKeyRef: name and key must be set")
+			}
+		}
+	}
+	if c.Auth != nil && c.Auth.EnableServiceAccounts && len(c.Auth.ClientsAuthSecret) > 0 {
+		return errors.New("spec: auth: enableServiceAccounts and clientsAuthSecret are mutually exclusive")
+	}
+	if len(c.Accounts) > 0 || len(c.SystemAccount) > 0 {
+		if err := c.validateAccounts(); err != nil {
+			return err
+		}
+	}
+	if c.DisruptionBudget != nil {
+		if c.DisruptionBudget.MaxUnavailable != nil && c.DisruptionBudget.MinAvailable != nil {
+			return errors.New("spec: disruptionBudget: maxUnavailable and minAvailable are mutually exclusive")
+		}
+		if c.DisruptionBudget.MaxUnavailable != nil && *c.DisruptionBudget.MaxUnavailable < 0 {
+			return fmt.Errorf("spec: disruptionBudget: maxUnavailable must not be negative (got %d)", *c.DisruptionBudget.MaxUnavailable)
+		}
+		if c.DisruptionBudget.MinAvailable != nil && *c.DisruptionBudget.MinAvailable < 0 {
+			return fmt.Errorf("spec: disruptionBudget: minAvailable must not be negative (got %d)", *c.DisruptionBudget.MinAvailable)
+		}
+	}
+	if c.Storage != nil && c.Storage.Size.Sign() <= 0 {
+		return errors.New("spec: storage: size must be positive")
+	}
+	if c.Upgrade != nil {
+		if c.Upgrade.FailureThreshold < 0 {
+			return fmt.Errorf("spec: upgrade: failureThreshold must be a positive integer (got %d)", c.Upgrade.FailureThreshold)
+		}
+		if c.Upgrade.TimeoutSeconds < 0 {
+			return fmt.Errorf("spec: upgrade: timeoutSeconds must be a positive integer (got %d)", c.Upgrade.TimeoutSeconds)
+		}
+	}
+	if c.UpgradeStrategy != nil {
+		switch c.UpgradeStrategy.Type {
+		case UpgradeStrategyTypePartition:
+			if c.UpgradeStrategy.Partition == nil {
+				return errors.New("spec: upgradeStrategy: partition must be set when type is \"Partition\"")
+			}
+			if c.UpgradeStrategy.Partition.Ordinal < 0 {
+				return fmt.Errorf("spec: upgradeStrategy: partition: ordinal must not be negative (got %d)", c.UpgradeStrategy.Partition.Ordinal)
+			}
+		case UpgradeStrategyTypeCanary:
+			if c.UpgradeStrategy.Canary == nil {
+				return errors.New("spec: upgradeStrategy: canary must be set when type is \"Canary\"")
+			}
+			if c.UpgradeStrategy.Canary.Replicas < 1 {
+				return fmt.Errorf("spec: upgradeStrategy: canary: replicas must be a positive integer (got %d)", c.UpgradeStrategy.Canary.Replicas)
+			}
+			if c.UpgradeStrategy.Canary.SoakSeconds < 0 {
+				return fmt.Errorf("spec: upgradeStrategy: canary: soakSeconds must not be negative (got %d)", c.UpgradeStrategy.Canary.SoakSeconds)
+			}
+		default:
+			return fmt.Errorf("spec: upgradeStrategy: invalid type %q", c.UpgradeStrategy.Type)
+		}
+	}
+	if c.MeshHealth != nil {
+		if c.MeshHealth.PeriodSeconds < 0 {
+			return fmt.Errorf("spec: meshHealth: periodSeconds must be a positive integer (got %d)", c.MeshHealth.PeriodSeconds)
+		}
+		if c.MeshHealth.RestartAfterSeconds < 0 {
+			return fmt.Errorf("spec: meshHealth: restartAfterSeconds must be a positive integer (got %d)", c.MeshHealth.RestartAfterSeconds)
+		}
+	}
+	if c.Scaling != nil {
+		if c.Scaling.MaxParallelCreations < 0 {
+			return fmt.Errorf("spec: scaling: maxParallelCreations must be a positive integer (got %d)", c.Scaling.MaxParallelCreations)
+		}
+		if c.Scaling.MaxParallelDeletions < 0 {
+			return fmt.Errorf("spec: scaling: maxParallelDeletions must be a positive integer (got %d)", c.Scaling.MaxParallelDeletions)
+		}
+	}
+	return nil
+}
+
+// validateAccounts checks whether the accounts in the spec are valid and only reference one another, returning an error describing the first problem found otherwise.
+func (c *ClusterSpec) validateAccounts() error {
+	if v, err := semver.Parse(versionOrDefault(c.Version)); err == nil && v.Major < 2 {
+		return fmt.Errorf("spec: accounts require version 2.0.0 or later (got %s)", v)
+	}
+	names := make(map[string]*AccountConfig, len(c.Accounts))
+	for _, account := range c.Accounts {
+		if len(account.Name) == 0 {
+			return errors.New("spec: accounts: name must be set")
+		}
+		if names[account.Name] != nil {
+			return fmt.Errorf("spec: accounts: duplicate account %q", account.Name)
+		}
+		names[account.Name] = account
+	}
+	if len(c.SystemAccount) > 0 && names[c.SystemAccount] == nil {
+		return fmt.Errorf("spec: systemAccount: unknown account %q", c.SystemAccount)
+	}
+	usernames := make(map[string]bool)
+	for _, account := range c.Accounts {
+		for _, user := range account.Users {
+			switch {
+			case len(user.Username) == 0:
+				return fmt.Errorf("spec: accounts: %s: users: username must be set", account.Name)
+			case usernames[user.Username]:
+				return fmt.Errorf("spec: accounts: %s: users: duplicate user %q", account.Name, user.Username)
+			case user.PasswordSecretRef == nil || len(user.PasswordSecretRef.Name) == 0 || len(user.PasswordSecretRef.Key) == 0:
+				return fmt.Errorf("spec: accounts: %s: users: %s: passwordSecretRef: name and key must be set", account.Name, user.Username)
+			}
+			usernames[user.Username] = true
+			if user.Permissions != nil {
+				if err := user.Permissions.Validate(); err != nil {
+					return fmt.Errorf("spec: accounts: %s: users: %s: %v", account.Name, user.Username, strings.TrimPrefix(err.Error(), "spec: "))
+				}
+			}
+		}
+		for _, export := range account.Exports {
+			if (len(export.Stream) == 0) == (len(export.Service) == 0) {
+				return fmt.Errorf("spec: accounts: %s: exports: exactly one of stream and service must be set", account.Name)
+			}
+			if err := validateSubject(export.Stream + export.Service); err != nil {
+				return fmt.Errorf("spec: accounts: %s: exports: %v", account.Name, err)
+			}
+			for _, name := range export.Accounts {
+				if names[name] == nil {
+					return fmt.Errorf("spec: accounts: %s: exports: unknown account %q", account.Name, name)
+				}
+			}
+		}
+		for _, imp := range account.Imports {
+			source, kind := imp.Stream, "stream"
+			switch {
+			case (imp.Stream == nil) == (imp.Service == nil):
+				return fmt.Errorf("spec: accounts: %s: imports: exactly one of stream and service must be set", account.Name)
+			case imp.Stream != nil && len(imp.To) > 0:
+				return fmt.Errorf("spec: accounts: %s: imports: to can only be set when importing a service", account.Name)
+			case imp.Service != nil && len(imp.Prefix) > 0:
+				return fmt.Errorf("spec: accounts: %s: imports: prefix can only be set when importing a stream", account.Name)
+			case imp.Service != nil:
+				source, kind = imp.Service, "service"
+			}
+			if names[source.Account] == nil {
+				return fmt.Errorf("spec: accounts: %s: imports: unknown account %q", account.Name, source.Account)
+			}
+			if source.Account == account.Name {
+				return fmt.Errorf("spec: accounts: %s: imports: cannot import from the same account", account.Name)
+			}
+			if err := validateSubject(source.Subject); err != nil {
+				return fmt.Errorf("spec: accounts: %s: imports: %v", account.Name, err)
+			}
+			if !exportsTo(names[source.Account], imp.Stream != nil, source.Subject, account.Name) {
+				return fmt.Errorf("spec: accounts: %s: imports: account %q does not export %s %q to this account", account.Name, source.Account, kind, source.Subject)
+			}
+		}
+	}
+	return nil
+}
+
+// exportsTo returns whether the specified account exports a stream (or a service) covering the specified subject to the account with the specified name.
+func exportsTo(account *AccountConfig, stream bool, subject, name string) bool {
+	for _, export := range account.Exports {
+		exported := export.Service
+		if stream {
+			exported = export.Stream
+		}
+		if len(exported) == 0 || !subjectContains(exported, subject) {
+			continue
+		}
+		if len(export.Accounts) == 0 {
+			return true
+		}
+		for _, allowed := range export.Accounts {
+			if allowed == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ValidateUpdate checks whether the spec is valid and represents a supported transition from the specified old spec.
+func (c *ClusterSpec) ValidateUpdate(old *ClusterSpec) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	// Downgrades are only supported within the same major version, as the configuration emitted by the operator may not be understood by older major versions.
+	oldVersion, oldErr := semver.Parse(versionOrDefault(old.Version))
+	newVersion, newErr := semver.Parse(versionOrDefault(c.Version))
+	if oldErr == nil && newErr == nil && newVersion.LessThan(oldVersion) && newVersion.Major != oldVersion.Major {
+		return fmt.Errorf("spec: downgrading from version %s to %s is not supported", oldVersion, newVersion)
+	}
+	// Existing persistent volume claims are never updated, so the storage configuration must not change.
+	// Defaults are set on copies of both specs so that resources created before defaulting was in place can still be updated.
+	oldSpec, newSpec := old.DeepCopy(), c.DeepCopy()
+	oldSpec.SetDefaults()
+	newSpec.SetDefaults()
+	if !reflect.DeepEqual(oldSpec.Storage, newSpec.Storage) {
+		return errors.New("spec: storage cannot be updated once the cluster is created")
 	}
 	return nil
 }
 
-// Cleanup cleans up user passed spec, e.g. defaulting, transforming fields.
-// TODO: move this to admission controller
-func (c *ClusterSpec) Cleanup() {
+// versionOrDefault returns the specified version, or the default NATS version if it is empty.
+func versionOrDefault(v string) string {
+	if len(v) == 0 {
+		return constants.DefaultNatsVersion
+	}
+	return v
+}
+
+// SetDefaults sets default values for the fields in the spec that have not been set, and normalizes the ones that have.
+// It is used by the mutating admission webhook so that the stored resource reflects what the operator is actually running.
+func (c *ClusterSpec) SetDefaults() {
 	if len(c.Version) == 0 {
 		c.Version = constants.DefaultNatsVersion
 	}
@@ -318,6 +1025,88 @@ func (c *ClusterSpec) Cleanup() {
 
 	c.Version = strings.TrimLeft(c.Version, "v")
 
+	if c.LameDuckDurationSeconds == nil {
+		d := int64(constants.DefaultLameDuckDurationSeconds)
+		c.LameDuckDurationSeconds = &d
+	}
+
+	if c.Upgrade != nil {
+		if c.Upgrade.FailureThreshold == 0 {
+			c.Upgrade.FailureThreshold = constants.DefaultUpgradeFailureThreshold
+		}
+		if c.Upgrade.TimeoutSeconds == 0 {
+			c.Upgrade.TimeoutSeconds = constants.DefaultUpgradeTimeoutSeconds
+		}
+	}
+
+	if c.MeshHealth != nil {
+		if c.MeshHealth.PeriodSeconds == 0 {
+			c.MeshHealth.PeriodSeconds = constants.DefaultMeshHealthPeriodSeconds
+		}
+		if c.MeshHealth.RestartAfterSeconds == 0 {
+			c.MeshHealth.RestartAfterSeconds = constants.DefaultMeshHealthRestartAfterSeconds
+		}
+	}
+
+	if c.Storage != nil {
+		if len(c.Storage.AccessModes) == 0 {
+			c.Storage.AccessModes = []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce}
+		}
+		if len(c.Storage.MountPath) == 0 {
+			c.Storage.MountPath = constants.DefaultDataMountPath
+		}
+	}
+
+	if c.Pod != nil {
+		if c.Pod.Topology != nil {
+			for i := range c.Pod.Topology.AntiAffinity {
+				term := &c.Pod.Topology.AntiAffinity[i]
+				if len(term.Mode) == 0 {
+					term.Mode = AntiAffinityModePreferred
+				}
+				if term.Mode == AntiAffinityModePreferred && term.Weight == 0 {
+					term.Weight = 100
+				}
+			}
+			for i := range c.Pod.Topology.SpreadConstraints {
+				constraint := &c.Pod.Topology.SpreadConstraints[i]
+				if len(constraint.WhenUnsatisfiable) == 0 {
+					constraint.WhenUnsatisfiable = UnsatisfiableConstraintActionDoNotSchedule
+				}
+			}
+		}
+		if c.Pod.EnableConfigReload {
+			if len(c.Pod.ReloaderImage) == 0 {
+				c.Pod.ReloaderImage = constants.DefaultReloaderImage
+			}
+			if len(c.Pod.ReloaderImageTag) == 0 {
+				c.Pod.ReloaderImageTag = constants.DefaultReloaderImageTag
+			}
+			if len(c.Pod.ReloaderImagePullPolicy) == 0 {
+				c.Pod.ReloaderImagePullPolicy = constants.DefaultReloaderImagePullPolicy
+			}
+		}
+		if c.Pod.EnableMetrics {
+			if len(c.Pod.MetricsImage) == 0 {
+				c.Pod.MetricsImage = constants.DefaultMetricsImage
+			}
+			if len(c.Pod.MetricsImageTag) == 0 {
+				c.Pod.MetricsImageTag = constants.DefaultMetricsImageTag
+			}
+			if len(c.Pod.MetricsImagePullPolicy) == 0 {
+				c.Pod.MetricsImagePullPolicy = constants.DefaultMetricsImagePullPolicy
+			}
+		}
+		if c.Pod.AdvertiseExternalIP {
+			if len(c.Pod.BootConfigContainerImage) == 0 {
+				c.Pod.BootConfigContainerImage = constants.DefaultBootConfigImage
+			}
+			if len(c.Pod.BootConfigContainerImageTag) == 0 {
+				c.Pod.BootConfigContainerImageTag = constants.DefaultBootConfigImageTag
+			}
+		}
+	}
+
 	if c.TLS != nil {
 		if len(c.TLS.ServerSecretCAFileName) == 0 {
 			c.TLS.ServerSecretCAFileName = constants.DefaultServerCAFileName
@@ -343,29 +1132,79 @@ func (c *ClusterSpec) Cleanup() {
 type ClusterPhase string
 
 const (
-	ClusterPhaseNone     ClusterPhase = ""
-	ClusterPhaseCreating              = "Creating"
-	ClusterPhaseRunning               = "Running"
-	ClusterPhaseFailed                = "Failed"
+	ClusterPhaseNone        ClusterPhase = ""
+	ClusterPhaseCreating                 = "Creating"
+	ClusterPhaseRunning                  = "Running"
+	ClusterPhaseFailed                   = "Failed"
+	ClusterPhaseTerminating              = "Terminating"
 )
 
+// ClusterCondition represents the state of a NATS cluster with respect to a given aspect.
+// Conditions are keyed by type, meaning that there is at most one condition of each type in the status of a NatsCluster.
 type ClusterCondition struct {
+	// Type is the type of the condition.
 	Type ClusterConditionType `json:"type"`
 
-	Reason string `json:"reason"`
+	// Status is the status of the condition, one of "True", "False" or "Unknown".
+	Status v1.ConditionStatus `json:"status"`
+
+	// Reason is a brief, CamelCase reason for the condition's last transition.
+	Reason string `json:"reason,omitempty"`
 
-	TransitionTime string `json:"transitionTime"`
+	// Message is a human-readable message indicating details about the condition's last transition.
+	Message string `json:"message,omitempty"`
+
+	// LastTransitionTime is the last time the condition transitioned from one status to another.
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
 }
 
 type ClusterConditionType string
 
 const (
-	ClusterConditionReady = "Ready"
-
-	ClusterConditionScalingUp   = "ScalingUp"
-	ClusterConditionScalingDown = "ScalingDown"
+	// ClusterConditionReady indicates whether the current state of the NATS cluster matches the desired state.
+	ClusterConditionReady ClusterConditionType = "Ready"
+	// ClusterConditionProgressing indicates whether the NATS cluster is being driven towards the desired state.
+	ClusterConditionProgressing ClusterConditionType = "Progressing"
+	// ClusterConditionDegraded indicates whether the last attempt at reconciling the NATS cluster has failed.
+	// Problems with the route mesh are reported by the "MeshHealthy" condition instead.
+	ClusterConditionDegraded ClusterConditionType = "Degraded"
+	// ClusterConditionMeshHealthy indicates whether the members of the NATS cluster formed a full mesh of routes during the latest check, and is only set when ".spec.meshHealth" is set.
+	ClusterConditionMeshHealthy ClusterConditionType = "MeshHealthy"
+
+	// ClusterConditionScalingUp indicates whether members are being added to the NATS cluster.
+	ClusterConditionScalingUp ClusterConditionType = "ScalingUp"
+	// ClusterConditionScalingDown indicates whether members are being removed from the NATS cluster.
+	ClusterConditionScalingDown ClusterConditionType = "ScalingDown"
+
+	// ClusterConditionUpgrading indicates whether the members of the NATS cluster are being upgraded to a different version.
+	ClusterConditionUpgrading ClusterConditionType = "Upgrading"
+	// ClusterConditionUpgradeFailed indicates whether the latest version upgrade has failed and been rolled back.
+	ClusterConditionUpgradeFailed ClusterConditionType = "UpgradeFailed"
+)
 
-	ClusterConditionUpgrading = "Upgrading"
+const (
+	// ClusterReasonReady is used when the current state of the NATS cluster matches the desired state.
+	ClusterReasonReady = "ClusterReady"
+	// ClusterReasonCompleted is used when an operation on the NATS cluster (e.g. scaling or upgrading) has completed.
+	ClusterReasonCompleted = "Completed"
+	// ClusterReasonScalingUp is used when members are being added to the NATS cluster.
+	ClusterReasonScalingUp = "ScalingUp"
+	// ClusterReasonScalingDown is used when members are being removed from the NATS cluster.
+	ClusterReasonScalingDown = "ScalingDown"
+	// ClusterReasonUpgrading is used when the members of the NATS cluster are being upgraded.
+	ClusterReasonUpgrading = "Upgrading"
+	// ClusterReasonUpgradeHeld is used when the upgrade of the NATS cluster is held until the canary members have soaked and the upgrade has been approved.
+	ClusterReasonUpgradeHeld = "UpgradeHeld"
+	// ClusterReasonRollingBack is used when the members of the NATS cluster are being rolled back to the last known-good version after a failed upgrade.
+	ClusterReasonRollingBack = "RollingBack"
+	// ClusterReasonUpgradeFailed is used when a version upgrade has failed and been rolled back.
+	ClusterReasonUpgradeFailed = "UpgradeFailed"
+	// ClusterReasonMeshDegraded is used when the members of the NATS cluster don't form a full mesh of routes.
+	ClusterReasonMeshDegraded = "MeshDegraded"
+	// ClusterReasonFullMesh is used when the members of the NATS cluster form a full mesh of routes.
+	ClusterReasonFullMesh = "FullMesh"
+	// ClusterReasonRollingUpdate is used when the members of the NATS cluster are being replaced because their spec has drifted.
+	ClusterReasonRollingUpdate = "RollingUpdate"
 )
 
 type ClusterStatus struct {
@@ -373,18 +1212,88 @@ type ClusterStatus struct {
 	Phase  ClusterPhase `json:"phase"`
 	Reason string       `json:"reason"`
 
+	// ObservedGeneration is the most recent generation of the NatsCluster resource observed by the operator.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
 	// ControlPaused indicates the operator pauses the control of
 	// the cluster.
 	ControlPaused bool `json:"controlPaused"`
 
-	// Condition keeps ten most recent cluster conditions.
+	// Conditions holds the latest observations of the cluster's state, keyed by type.
 	Conditions []ClusterCondition `json:"conditions"`
 
 	// Size is the current size of the cluster.
 	Size int `json:"size"`
 
+	// Selector is the label selector (in string form) matching the pods that belong to the cluster.
+	// It is exposed through the "/scale" subresource so that the cluster can be targeted by a HorizontalPodAutoscaler.
+	Selector string `json:"selector,omitempty"`
+
 	// CurrentVersion is the current cluster version.
 	CurrentVersion string `json:"currentVersion"`
+
+	// PendingOperation is the operation on a member of the cluster which is currently in flight, if any.
+	// Operations span multiple reconcile iterations, and no further changes are made to the cluster until the pending operation completes.
+	// A single operation may target several pods at once (e.g. when scaling up in parallel).
+	PendingOperation *ClusterOperation `json:"pendingOperation,omitempty"`
+
+	// Upgrade holds the progress of the latest version upgrade, if any.
+	// It is kept after an upgrade has been rolled back, so that it is not attempted again until the spec changes.
+	Upgrade *UpgradeStatus `json:"upgrade,omitempty"`
+
+	// MeshHealth holds the result of the latest check of the route mesh, if ".spec.meshHealth" is set.
+	MeshHealth *MeshHealthStatus `json:"meshHealth,omitempty"`
+}
+
+// MeshHealthStatus describes the result of the latest check of the route mesh.
+type MeshHealthStatus struct {
+	// LastCheckTime is the time at which the route mesh was last checked.
+	LastCheckTime metav1.Time `json:"lastCheckTime"`
+	// UnhealthySince is the time at which the route mesh was first found to be unhealthy, if it currently is.
+	UnhealthySince *metav1.Time `json:"unhealthySince,omitempty"`
+	// Problems is the list of problems found in the route mesh during the latest check.
+	Problems []string `json:"problems,omitempty"`
+}
+
+// UpgradeStatus describes the progress of a version upgrade.
+type UpgradeStatus struct {
+	// FromVersion is the last known-good version of the cluster, to which it is rolled back if the upgrade fails.
+	FromVersion string `json:"fromVersion"`
+	// ToVersion is the version the cluster is being upgraded to.
+	ToVersion string `json:"toVersion"`
+	// StartTime is the time at which the upgrade started.
+	StartTime metav1.Time `json:"startTime"`
+	// FailedPods is the list of UIDs of the upgraded pods that have failed so far.
+	FailedPods []string `json:"failedPods,omitempty"`
+	// CanaryTime is the time at which the canary members finished being upgraded, if the "Canary" upgrade strategy is used.
+	CanaryTime *metav1.Time `json:"canaryTime,omitempty"`
+	// RolledBack indicates whether the upgrade has failed and the cluster has been rolled back to FromVersion.
+	RolledBack bool `json:"rolledBack,omitempty"`
+	// RolledBackGeneration is the generation of the NatsCluster resource at which the upgrade was rolled back.
+	// The upgrade is attempted again once the generation changes.
+	RolledBackGeneration int64 `json:"rolledBackGeneration,omitempty"`
+}
+
+// ClusterOperationType is the type of an operation on a member of the cluster.
+type ClusterOperationType string
+
+const (
+	// ClusterOperationCreatePod is used while waiting for newly created pods to be observed.
+	ClusterOperationCreatePod ClusterOperationType = "CreatePod"
+	// ClusterOperationRemovePod is used while pods are gracefully removed from the cluster.
+	ClusterOperationRemovePod ClusterOperationType = "RemovePod"
+	// ClusterOperationReplacePod is used while pods are gracefully removed from the cluster in order to be replaced by new ones.
+	ClusterOperationReplacePod ClusterOperationType = "ReplacePod"
+)
+
+// ClusterOperation describes an operation on members of the cluster.
+type ClusterOperation struct {
+	// Type is the type of the operation.
+	Type ClusterOperationType `json:"type"`
+	// Pods is the list of names of the pods targeted by the operation.
+	Pods []string `json:"pods"`
+	// StartTime is the time at which the operation started.
+	StartTime metav1.Time `json:"startTime"`
 }
 
 func (cs ClusterStatus) Copy() ClusterStatus {
@@ -424,6 +1333,11 @@ func (cs *ClusterStatus) SetSize(size int) {
 	cs.Size = size
 }
 
+// SetSelector sets the label selector matching the pods that belong to the cluster.
+func (cs *ClusterStatus) SetSelector(s string) {
+	cs.Selector = s
+}
+
 func (cs *ClusterStatus) SetCurrentVersion(v string) {
 	cs.CurrentVersion = v
 }
@@ -432,57 +1346,196 @@ func (cs *ClusterStatus) SetReason(r string) {
 	cs.Reason = r
 }
 
-func (cs *ClusterStatus) AppendScalingUpCondition(from, to int) {
-	c := ClusterCondition{
-		Type:           ClusterConditionScalingUp,
-		Reason:         scalingReason(from, to),
-		TransitionTime: time.Now().Format(time.RFC3339),
+// SetPendingOperation records the start of an operation of the specified type on the specified pods.
+func (cs *ClusterStatus) SetPendingOperation(t ClusterOperationType, pods ...string) {
+	cs.PendingOperation = &ClusterOperation{
+		Type:      t,
+		Pods:      pods,
+		StartTime: metav1.Now(),
 	}
-	cs.appendCondition(c)
 }
 
-func (cs *ClusterStatus) AppendScalingDownCondition(from, to int) {
-	c := ClusterCondition{
-		Type:           ClusterConditionScalingDown,
-		Reason:         scalingReason(from, to),
-		TransitionTime: time.Now().Format(time.RFC3339),
+// StartUpgrade records the start of an upgrade from the specified version to the specified version.
+// Any previous upgrade failure is cleared.
+func (cs *ClusterStatus) StartUpgrade(from, to string) {
+	cs.Upgrade = &UpgradeStatus{
+		FromVersion: from,
+		ToVersion:   to,
+		StartTime:   metav1.Now(),
+	}
+	if cs.IsConditionTrue(ClusterConditionUpgradeFailed) {
+		cs.SetCondition(ClusterConditionUpgradeFailed, v1.ConditionFalse, ClusterReasonUpgrading, "")
 	}
-	cs.appendCondition(c)
 }
 
-func (cs *ClusterStatus) AppendUpgradingCondition(from, to string) {
-	c := ClusterCondition{
-		Type:           ClusterConditionUpgrading,
-		Reason:         fmt.Sprintf("upgrading cluster version from %s to %s", from, to),
-		TransitionTime: time.Now().Format(time.RFC3339),
+// RollbackUpgrade records that the upgrade in progress has failed for the specified reason at the specified generation of the NatsCluster resource.
+func (cs *ClusterStatus) RollbackUpgrade(generation int64, reason string) {
+	cs.Upgrade.RolledBack = true
+	cs.Upgrade.RolledBackGeneration = generation
+	msg := fmt.Sprintf("upgrade from %s to %s failed and was rolled back: %s", cs.Upgrade.FromVersion, cs.Upgrade.ToVersion, reason)
+	cs.SetCondition(ClusterConditionUpgradeFailed, v1.ConditionTrue, ClusterReasonUpgradeFailed, msg)
+}
+
+// CompleteUpgrade records the successful completion of the upgrade in progress.
+func (cs *ClusterStatus) CompleteUpgrade() {
+	cs.Upgrade = nil
+	cs.completeCondition(ClusterConditionUpgradeFailed)
+}
+
+// ClearPendingOperation records the completion of the pending operation.
+func (cs *ClusterStatus) ClearPendingOperation() {
+	cs.PendingOperation = nil
+}
+
+// SetObservedGeneration sets the most recent generation of the NatsCluster resource observed by the operator.
+func (cs *ClusterStatus) SetObservedGeneration(g int64) {
+	cs.ObservedGeneration = g
+}
+
+// GetCondition returns the condition with the specified type, or nil if no such condition exists.
+func (cs *ClusterStatus) GetCondition(t ClusterConditionType) *ClusterCondition {
+	for i := range cs.Conditions {
+		if cs.Conditions[i].Type == t {
+			return &cs.Conditions[i]
+		}
 	}
-	cs.appendCondition(c)
+	return nil
 }
 
-func (cs *ClusterStatus) SetReadyCondition() {
+// IsConditionTrue returns whether the condition with the specified type exists and has a status of "True".
+func (cs *ClusterStatus) IsConditionTrue(t ClusterConditionType) bool {
+	c := cs.GetCondition(t)
+	return c != nil && c.Status == v1.ConditionTrue
+}
+
+// SetCondition sets the condition with the specified type to the specified status, reason and message.
+// The last transition time of the condition is only updated in case its status changes.
+func (cs *ClusterStatus) SetCondition(t ClusterConditionType, status v1.ConditionStatus, reason, message string) {
 	c := ClusterCondition{
-		Type:           ClusterConditionReady,
-		Reason:         "current state matches desired state",
-		TransitionTime: time.Now().Format(time.RFC3339),
+		Type:               t,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		LastTransitionTime: metav1.Now(),
 	}
 
-	if len(cs.Conditions) == 0 {
-		cs.appendCondition(c)
-		return
+	// Replace the first condition with the same type in place, dropping any duplicates left behind by older versions of the operator.
+	conditions := make([]ClusterCondition, 0, len(cs.Conditions)+1)
+	found := false
+	for _, existing := range cs.Conditions {
+		if existing.Type != t {
+			conditions = append(conditions, existing)
+			continue
+		}
+		if found {
+			continue
+		}
+		found = true
+		if existing.Status == status {
+			c.LastTransitionTime = existing.LastTransitionTime
+		}
+		conditions = append(conditions, c)
+	}
+	if !found {
+		conditions = append(conditions, c)
+	}
+	cs.Conditions = conditions
+}
+
+// completeCondition sets the condition with the specified type to "False" in case it is currently "True".
+func (cs *ClusterStatus) completeCondition(t ClusterConditionType) {
+	if cs.IsConditionTrue(t) {
+		cs.SetCondition(t, v1.ConditionFalse, ClusterReasonCompleted, "")
 	}
+}
+
+// setProgressing marks the cluster as being driven towards the desired state.
+func (cs *ClusterStatus) setProgressing(reason, message string) {
+	cs.SetCondition(ClusterConditionProgressing, v1.ConditionTrue, reason, message)
+	cs.SetCondition(ClusterConditionReady, v1.ConditionFalse, reason, message)
+}
+
+func (cs *ClusterStatus) SetScalingUpCondition(from, to int) {
+	cs.SetCondition(ClusterConditionScalingUp, v1.ConditionTrue, ClusterReasonScalingUp, scalingReason(from, to))
+	cs.setProgressing(ClusterReasonScalingUp, scalingReason(from, to))
+}
+
+func (cs *ClusterStatus) SetScalingDownCondition(from, to int) {
+	cs.SetCondition(ClusterConditionScalingDown, v1.ConditionTrue, ClusterReasonScalingDown, scalingReason(from, to))
+	cs.setProgressing(ClusterReasonScalingDown, scalingReason(from, to))
+}
+
+func (cs *ClusterStatus) SetUpgradingCondition(from, to string) {
+	msg := fmt.Sprintf("upgrading cluster version from %s to %s", from, to)
+	cs.SetCondition(ClusterConditionUpgrading, v1.ConditionTrue, ClusterReasonUpgrading, msg)
+	cs.setProgressing(ClusterReasonUpgrading, msg)
+}
 
-	lastc := cs.Conditions[len(cs.Conditions)-1]
-	if lastc.Type == ClusterConditionReady {
+// SetUpgradeHeldCondition marks the cluster as upgrading, but with the upgrade held for the specified reason.
+func (cs *ClusterStatus) SetUpgradeHeldCondition(message string) {
+	cs.SetCondition(ClusterConditionUpgrading, v1.ConditionTrue, ClusterReasonUpgradeHeld, message)
+	cs.setProgressing(ClusterReasonUpgradeHeld, message)
+}
+
+// SetRollingBackCondition marks the cluster as progressing while its members are rolled back from the specified version to the specified version.
+func (cs *ClusterStatus) SetRollingBackCondition(from, to string) {
+	cs.setProgressing(ClusterReasonRollingBack, fmt.Sprintf("rolling back cluster version from %s to %s", from, to))
+}
+
+// SetRollingUpdateCondition marks the cluster as progressing while the specified pod is replaced because its spec has drifted.
+func (cs *ClusterStatus) SetRollingUpdateCondition(pod string) {
+	cs.setProgressing(ClusterReasonRollingUpdate, fmt.Sprintf("replacing pod %s as its spec has drifted", pod))
+}
+
+// SetDegradedCondition marks the cluster as degraded (and hence not ready) with the specified reason and message.
+func (cs *ClusterStatus) SetDegradedCondition(reason, message string) {
+	cs.SetCondition(ClusterConditionDegraded, v1.ConditionTrue, reason, message)
+	cs.SetCondition(ClusterConditionReady, v1.ConditionFalse, reason, message)
+}
+
+// SetMeshHealth records the result of a check of the route mesh in the "MeshHealthy" condition, and marks the cluster as not ready in case any problems were found.
+// The "Degraded" condition is left untouched, so that the result of the check never hides (or clears) a failure to reconcile the cluster.
+func (cs *ClusterStatus) SetMeshHealth(problems []string) {
+	now := metav1.Now()
+	if cs.MeshHealth == nil {
+		cs.MeshHealth = &MeshHealthStatus{}
+	}
+	cs.MeshHealth.LastCheckTime = now
+	cs.MeshHealth.Problems = problems
+	if len(problems) == 0 {
+		cs.MeshHealth.UnhealthySince = nil
+		cs.SetCondition(ClusterConditionMeshHealthy, v1.ConditionTrue, ClusterReasonFullMesh, "")
 		return
 	}
-	cs.appendCondition(c)
+	if cs.MeshHealth.UnhealthySince == nil {
+		cs.MeshHealth.UnhealthySince = &now
+	}
+	message := strings.Join(problems, "; ")
+	cs.SetCondition(ClusterConditionMeshHealthy, v1.ConditionFalse, ClusterReasonMeshDegraded, message)
+	cs.SetCondition(ClusterConditionReady, v1.ConditionFalse, ClusterReasonMeshDegraded, message)
 }
 
-func (cs *ClusterStatus) appendCondition(c ClusterCondition) {
-	cs.Conditions = append(cs.Conditions, c)
-	if len(cs.Conditions) > 10 {
-		cs.Conditions = cs.Conditions[1:]
+// ClearMeshHealth removes the result of the latest check of the route mesh, along with the "MeshHealthy" condition.
+func (cs *ClusterStatus) ClearMeshHealth() {
+	cs.MeshHealth = nil
+	conditions := make([]ClusterCondition, 0, len(cs.Conditions))
+	for _, c := range cs.Conditions {
+		if c.Type != ClusterConditionMeshHealthy {
+			conditions = append(conditions, c)
+		}
 	}
+	cs.Conditions = conditions
+}
+
+// SetReadyCondition marks the cluster as ready (and hence running), and any ongoing operations as completed.
+func (cs *ClusterStatus) SetReadyCondition() {
+	cs.SetPhase(ClusterPhaseRunning)
+	cs.completeCondition(ClusterConditionScalingUp)
+	cs.completeCondition(ClusterConditionScalingDown)
+	cs.completeCondition(ClusterConditionUpgrading)
+	cs.SetCondition(ClusterConditionProgressing, v1.ConditionFalse, ClusterReasonReady, "")
+	cs.SetCondition(ClusterConditionDegraded, v1.ConditionFalse, ClusterReasonReady, "")
+	cs.SetCondition(ClusterConditionReady, v1.ConditionTrue, ClusterReasonReady, "current state matches desired state")
 }
 
 func scalingReason(from, to int) string {
@@ -528,3 +1581,39 @@ func (c *NatsCluster) SetNatsServiceRolesHash(v string) {
 	}
 	c.Annotations[natsServiceRolesHashAnnotationKey] = v
 }
+
+// GetApprovedUpgradeVersion returns the version to which a canary upgrade of the NATS cluster has been approved to proceed.
+func (c *NatsCluster) GetApprovedUpgradeVersion() string {
+	if c.Annotations == nil {
+		return ""
+	}
+	return c.Annotations[approvedUpgradeVersionAnnotationKey]
+}
+
+// HasFinalizer returns whether the NatsCluster resource has the specified finalizer.
+func (c *NatsCluster) HasFinalizer(name string) bool {
+	for _, f := range c.Finalizers {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// AddFinalizer adds the specified finalizer to the NatsCluster resource, unless it is already present.
+func (c *NatsCluster) AddFinalizer(name string) {
+	if !c.HasFinalizer(name) {
+		c.Finalizers = append(c.Finalizers, name)
+	}
+}
+
+// RemoveFinalizer removes the specified finalizer from the NatsCluster resource.
+func (c *NatsCluster) RemoveFinalizer(name string) {
+	finalizers := make([]string, 0, len(c.Finalizers))
+	for _, f := range c.Finalizers {
+		if f != name {
+			finalizers = append(finalizers, f)
+		}
+	}
+	c.Finalizers = finalizers
+}
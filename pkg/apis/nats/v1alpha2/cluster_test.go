@@ -0,0 +1,739 @@
+// Copyright 2019 The nats-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha2
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/nats-io/nats-operator/pkg/constants"
+)
+
+func TestClusterSpecValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		spec ClusterSpec
+		err  string
+	}{
+		{
+			name: "minimal",
+			spec: ClusterSpec{Size: 1},
+		},
+		{
+			name: "zero size",
+			spec: ClusterSpec{Size: 0},
+			err:  "spec: size must be a positive integer (got 0)",
+		},
+		{
+			name: "negative size",
+			spec: ClusterSpec{Size: -1},
+			err:  "spec: size must be a positive integer (got -1)",
+		},
+		{
+			name: "valid version",
+			spec: ClusterSpec{Size: 3, Version: "v1.4.1"},
+		},
+		{
+			name: "invalid version",
+			spec: ClusterSpec{Size: 3, Version: "latest"},
+			err:  "spec: invalid version",
+		},
+		{
+			name: "reserved app label",
+			spec: ClusterSpec{Size: 1, Pod: &PodPolicy{Labels: map[string]string{"app": "foo"}}},
+			err:  "spec: pod labels contains reserved label",
+		},
+		{
+			name: "reserved nats_ label",
+			spec: ClusterSpec{Size: 1, Pod: &PodPolicy{Labels: map[string]string{"nats_cluster": "foo"}}},
+			err:  "spec: pod labels contains reserved label",
+		},
+		{
+			name: "custom label",
+			spec: ClusterSpec{Size: 1, Pod: &PodPolicy{Labels: map[string]string{"team": "foo"}}},
+		},
+		{
+			name: "service accounts and clients auth secret",
+			spec: ClusterSpec{Size: 1, Auth: &AuthConfig{EnableServiceAccounts: true, ClientsAuthSecret: "auth"}},
+			err:  "spec: auth: enableServiceAccounts and clientsAuthSecret are mutually exclusive",
+		},
+		{
+			name: "service accounts",
+			spec: ClusterSpec{Size: 1, Auth: &AuthConfig{EnableServiceAccounts: true}},
+		},
+		{
+			name: "spread constraints",
+			spec: ClusterSpec{Size: 3, Pod: &PodPolicy{Topology: &TopologyPolicy{SpreadConstraints: []TopologySpreadConstraint{
+				{TopologyKey: "zone", MaxSkew: 1},
+				{TopologyKey: "node", MaxSkew: 2, WhenUnsatisfiable: UnsatisfiableConstraintActionScheduleAnyway},
+			}}}},
+		},
+		{
+			name: "spread constraint without topology key",
+			spec: ClusterSpec{Size: 3, Pod: &PodPolicy{Topology: &TopologyPolicy{SpreadConstraints: []TopologySpreadConstraint{
+				{MaxSkew: 1},
+			}}}},
+			err: "spec: pod: topology: spreadConstraints: topologyKey must be set",
+		},
+		{
+			name: "spread constraint with zero max skew",
+			spec: ClusterSpec{Size: 3, Pod: &PodPolicy{Topology: &TopologyPolicy{SpreadConstraints: []TopologySpreadConstraint{
+				{TopologyKey: "zone"},
+			}}}},
+			err: "spec: pod: topology: spreadConstraints: maxSkew must be a positive integer (got 0)",
+		},
+		{
+			name: "spread constraints with duplicate topology key",
+			spec: ClusterSpec{Size: 3, Pod: &PodPolicy{Topology: &TopologyPolicy{SpreadConstraints: []TopologySpreadConstraint{
+				{TopologyKey: "zone", MaxSkew: 1},
+				{TopologyKey: "zone", MaxSkew: 2},
+			}}}},
+			err: "spec: pod: topology: spreadConstraints: duplicate topologyKey \"zone\"",
+		},
+		{
+			name: "spread constraint with invalid action",
+			spec: ClusterSpec{Size: 3, Pod: &PodPolicy{Topology: &TopologyPolicy{SpreadConstraints: []TopologySpreadConstraint{
+				{TopologyKey: "zone", MaxSkew: 1, WhenUnsatisfiable: "Never"},
+			}}}},
+			err: "spec: pod: topology: spreadConstraints: invalid whenUnsatisfiable \"Never\"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.spec.Validate()
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("Error: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+				t.Errorf("Expected error %q, got: %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestClusterSpecValidateUpdate(t *testing.T) {
+	tests := []struct {
+		name       string
+		oldVersion string
+		newVersion string
+		err        string
+	}{
+		{
+			name:       "upgrade",
+			oldVersion: "1.3.0",
+			newVersion: "1.4.1",
+		},
+		{
+			name:       "major upgrade",
+			oldVersion: "1.4.1",
+			newVersion: "2.0.0",
+		},
+		{
+			name:       "downgrade within major version",
+			oldVersion: "1.4.1",
+			newVersion: "1.3.0",
+		},
+		{
+			name:       "downgrade across major versions",
+			oldVersion: "2.0.0",
+			newVersion: "1.4.1",
+			err:        "spec: downgrading from version 2.0.0 to 1.4.1 is not supported",
+		},
+		{
+			name:       "downgrade to default version",
+			oldVersion: "2.0.0",
+			newVersion: "",
+			err:        "spec: downgrading from version 2.0.0 to 1.4.0 is not supported",
+		},
+		{
+			name:       "invalid new version",
+			oldVersion: "1.4.1",
+			newVersion: "latest",
+			err:        "spec: invalid version",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := &ClusterSpec{Size: 1, Version: tt.oldVersion}
+			spec := &ClusterSpec{Size: 1, Version: tt.newVersion}
+			err := spec.ValidateUpdate(old)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("Error: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+				t.Errorf("Expected error %q, got: %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestClusterSpecSetDefaults(t *testing.T) {
+	lameDuckDurationSeconds := int64(constants.DefaultLameDuckDurationSeconds)
+	customLameDuckDurationSeconds := int64(30)
+
+	tests := []struct {
+		name     string
+		spec     ClusterSpec
+		expected ClusterSpec
+	}{
+		{
+			name: "empty",
+			spec: ClusterSpec{Size: 3},
+			expected: ClusterSpec{
+				Size:                    3,
+				Version:                 constants.DefaultNatsVersion,
+				ServerImage:             constants.DefaultServerImage,
+				LameDuckDurationSeconds: &lameDuckDurationSeconds,
+			},
+		},
+		{
+			name: "explicit values are kept",
+			spec: ClusterSpec{
+				Size:                    3,
+				Version:                 "v1.4.1",
+				ServerImage:             "example.com/nats",
+				LameDuckDurationSeconds: &customLameDuckDurationSeconds,
+				Upgrade:                 &UpgradePolicy{FailureThreshold: 1, TimeoutSeconds: 60},
+				MeshHealth:              &MeshHealthPolicy{PeriodSeconds: 10, RestartAfterSeconds: 20},
+			},
+			expected: ClusterSpec{
+				Size:                    3,
+				Version:                 "1.4.1",
+				ServerImage:             "example.com/nats",
+				LameDuckDurationSeconds: &customLameDuckDurationSeconds,
+				Upgrade:                 &UpgradePolicy{FailureThreshold: 1, TimeoutSeconds: 60},
+				MeshHealth:              &MeshHealthPolicy{PeriodSeconds: 10, RestartAfterSeconds: 20},
+			},
+		},
+		{
+			name: "nested policies",
+			spec: ClusterSpec{
+				Size:       3,
+				Upgrade:    &UpgradePolicy{},
+				MeshHealth: &MeshHealthPolicy{},
+				Storage:    &StorageConfig{Size: resource.MustParse("1Gi")},
+				TLS:        &TLSConfig{ServerSecret: "server-tls", RoutesSecret: "routes-tls"},
+			},
+			expected: ClusterSpec{
+				Size:                    3,
+				Version:                 constants.DefaultNatsVersion,
+				ServerImage:             constants.DefaultServerImage,
+				LameDuckDurationSeconds: &lameDuckDurationSeconds,
+				Upgrade: &UpgradePolicy{
+					FailureThreshold: constants.DefaultUpgradeFailureThreshold,
+					TimeoutSeconds:   constants.DefaultUpgradeTimeoutSeconds,
+				},
+				MeshHealth: &MeshHealthPolicy{
+					PeriodSeconds:       constants.DefaultMeshHealthPeriodSeconds,
+					RestartAfterSeconds: constants.DefaultMeshHealthRestartAfterSeconds,
+				},
+				Storage: &StorageConfig{
+					Size:        resource.MustParse("1Gi"),
+					AccessModes: []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+					MountPath:   constants.DefaultDataMountPath,
+				},
+				TLS: &TLSConfig{
+					ServerSecret:             "server-tls",
+					ServerSecretCAFileName:   constants.DefaultServerCAFileName,
+					ServerSecretCertFileName: constants.DefaultServerCertFileName,
+					ServerSecretKeyFileName:  constants.DefaultServerKeyFileName,
+					RoutesSecret:             "routes-tls",
+					RoutesSecretCAFileName:   constants.DefaultRoutesCAFileName,
+					RoutesSecretCertFileName: constants.DefaultRoutesCertFileName,
+					RoutesSecretKeyFileName:  constants.DefaultRoutesKeyFileName,
+				},
+			},
+		},
+		{
+			name: "pod images",
+			spec: ClusterSpec{
+				Size: 3,
+				Pod: &PodPolicy{
+					EnableConfigReload:  true,
+					EnableMetrics:       true,
+					AdvertiseExternalIP: true,
+					MetricsImageTag:     "0.3.0",
+					Topology: &TopologyPolicy{
+						AntiAffinity: []TopologyAntiAffinityTerm{
+							{TopologyKey: "zone"},
+							{TopologyKey: "node", Mode: AntiAffinityModeRequired},
+						},
+						SpreadConstraints: []TopologySpreadConstraint{
+							{TopologyKey: "region", MaxSkew: 1},
+						},
+					},
+				},
+			},
+			expected: ClusterSpec{
+				Size:                    3,
+				Version:                 constants.DefaultNatsVersion,
+				ServerImage:             constants.DefaultServerImage,
+				LameDuckDurationSeconds: &lameDuckDurationSeconds,
+				Pod: &PodPolicy{
+					EnableConfigReload:          true,
+					ReloaderImage:               constants.DefaultReloaderImage,
+					ReloaderImageTag:            constants.DefaultReloaderImageTag,
+					ReloaderImagePullPolicy:     constants.DefaultReloaderImagePullPolicy,
+					EnableMetrics:               true,
+					MetricsImage:                constants.DefaultMetricsImage,
+					MetricsImageTag:             "0.3.0",
+					MetricsImagePullPolicy:      constants.DefaultMetricsImagePullPolicy,
+					AdvertiseExternalIP:         true,
+					BootConfigContainerImage:    constants.DefaultBootConfigImage,
+					BootConfigContainerImageTag: constants.DefaultBootConfigImageTag,
+					Topology: &TopologyPolicy{
+						AntiAffinity: []TopologyAntiAffinityTerm{
+							{TopologyKey: "zone", Mode: AntiAffinityModePreferred, Weight: 100},
+							{TopologyKey: "node", Mode: AntiAffinityModeRequired},
+						},
+						SpreadConstraints: []TopologySpreadConstraint{
+							{TopologyKey: "region", MaxSkew: 1, WhenUnsatisfiable: UnsatisfiableConstraintActionDoNotSchedule},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := tt.spec.DeepCopy()
+			spec.SetDefaults()
+			if !reflect.DeepEqual(*spec, tt.expected) {
+				t.Errorf("Expected %+v, got: %+v", tt.expected, *spec)
+			}
+			// Setting defaults must be idempotent, as it happens both in the mutating admission webhook and when reconciling.
+			again := spec.DeepCopy()
+			again.SetDefaults()
+			if !reflect.DeepEqual(again, spec) {
+				t.Errorf("Expected defaults to be idempotent, got: %+v", *again)
+			}
+			// Defaulted specs must still be valid.
+			if err := spec.Validate(); err != nil {
+				t.Errorf("Error: %s", err)
+			}
+		})
+	}
+}
+
+func TestClusterStatusMeshHealth(t *testing.T) {
+	// expectCondition checks that the condition with the specified type has the specified status and reason.
+	expectCondition := func(t *testing.T, cs *ClusterStatus, ct ClusterConditionType, status v1.ConditionStatus, reason string) {
+		c := cs.GetCondition(ct)
+		if c == nil {
+			t.Fatalf("Expected the %s condition to be set", ct)
+		}
+		if c.Status != status || c.Reason != reason {
+			t.Errorf("Expected the %s condition to be %s (%s), got: %s (%s)", ct, status, reason, c.Status, c.Reason)
+		}
+	}
+
+	cs := &ClusterStatus{}
+	cs.SetReadyCondition()
+
+	// A failure to reconcile the cluster must survive checks of the route mesh, whatever their result.
+	cs.SetDegradedCondition("PodsFailed", "failed to reconcile pods")
+	cs.SetMeshHealth([]string{"pod example-nats-1 is isolated"})
+	expectCondition(t, cs, ClusterConditionMeshHealthy, v1.ConditionFalse, ClusterReasonMeshDegraded)
+	expectCondition(t, cs, ClusterConditionReady, v1.ConditionFalse, ClusterReasonMeshDegraded)
+	expectCondition(t, cs, ClusterConditionDegraded, v1.ConditionTrue, "PodsFailed")
+	cs.SetMeshHealth(nil)
+	expectCondition(t, cs, ClusterConditionMeshHealthy, v1.ConditionTrue, ClusterReasonFullMesh)
+	expectCondition(t, cs, ClusterConditionDegraded, v1.ConditionTrue, "PodsFailed")
+
+	// Problems with the route mesh must survive successful attempts at reconciling the cluster until the mesh is checked again.
+	cs.SetMeshHealth([]string{"pod example-nats-1 is isolated"})
+	cs.SetDegradedCondition("ServicesFailed", "failed to create services")
+	cs.SetCondition(ClusterConditionDegraded, v1.ConditionFalse, ClusterReasonReady, "")
+	expectCondition(t, cs, ClusterConditionMeshHealthy, v1.ConditionFalse, ClusterReasonMeshDegraded)
+	if cs.MeshHealth == nil || cs.MeshHealth.UnhealthySince == nil {
+		t.Errorf("Expected the mesh to be reported as unhealthy, got: %+v", cs.MeshHealth)
+	}
+
+	// Disabling checks of the route mesh removes their result.
+	cs.ClearMeshHealth()
+	if cs.MeshHealth != nil || cs.GetCondition(ClusterConditionMeshHealthy) != nil {
+		t.Errorf("Expected the result of the mesh check to be removed, got: %+v", *cs)
+	}
+	expectCondition(t, cs, ClusterConditionDegraded, v1.ConditionFalse, ClusterReasonReady)
+}
+
+func TestParseRawConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		// invalid indicates whether the snippet is expected to be rejected regardless of the keys it sets (e.g. because of a syntax error).
+		invalid bool
+		// err is the error expected because of the keys set by the snippet, if any.
+		err string
+	}{
+		{
+			name: "empty",
+			raw:  "",
+		},
+		{
+			name: "unmanaged keys",
+			raw:  "ping_interval: \"1m\"\nmax_traced_msg_len: 1024\ncluster {\n  no_advertise: true\n}\n",
+		},
+		{
+			name:    "invalid syntax",
+			raw:     "cluster {",
+			invalid: true,
+		},
+		{
+			name: "port",
+			raw:  "port: 4333",
+			err:  "keys managed by nats-operator cannot be set: port",
+		},
+		{
+			name: "port ignoring case",
+			raw:  "PORT: 4333",
+			err:  "keys managed by nats-operator cannot be set: PORT",
+		},
+		{
+			name: "include",
+			raw:  "include ./other.conf",
+			err:  "keys managed by nats-operator cannot be set: include",
+		},
+		{
+			name: "authorization",
+			raw:  "authorization {\n  user: foo\n  password: bar\n}",
+			err:  "keys managed by nats-operator cannot be set: authorization",
+		},
+		{
+			name: "tls",
+			raw:  "tls {\n  cert_file: /etc/nats/tls/server.pem\n}",
+			err:  "keys managed by nats-operator cannot be set: tls",
+		},
+		{
+			name: "accounts",
+			raw:  "accounts {\n  foo {\n    users: [{user: foo, password: bar}]\n  }\n}",
+			err:  "keys managed by nats-operator cannot be set: accounts",
+		},
+		{
+			name: "system account",
+			raw:  "system_account: SYS",
+			err:  "keys managed by nats-operator cannot be set: system_account",
+		},
+		{
+			name: "cluster routes",
+			raw:  "cluster {\n  routes: [\"nats://foo:6222\"]\n}",
+			err:  "keys managed by nats-operator cannot be set: cluster.routes",
+		},
+		{
+			name: "cluster authorization",
+			raw:  "cluster {\n  authorization {\n    user: foo\n    password: bar\n  }\n}",
+			err:  "keys managed by nats-operator cannot be set: cluster.authorization",
+		},
+		{
+			name: "cluster tls",
+			raw:  "cluster {\n  tls {\n    cert_file: /etc/nats/tls/route.pem\n  }\n}",
+			err:  "keys managed by nats-operator cannot be set: cluster.tls",
+		},
+		{
+			name: "several keys",
+			raw:  "tls {}\nport: 4333\ncluster {\n  tls {}\n  port: 6333\n}",
+			err:  "keys managed by nats-operator cannot be set: cluster.port, cluster.tls, port, tls",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseRawConfig(tt.raw)
+			if tt.invalid {
+				if err == nil {
+					t.Errorf("Expected an error")
+				}
+				return
+			}
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("Error: %s", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("Expected error %q, got: %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestClusterSpecValidateAccounts(t *testing.T) {
+	tests := []struct {
+		name          string
+		version       string
+		accounts      []*AccountConfig
+		systemAccount string
+		err           string
+	}{
+		{
+			name:     "accounts",
+			version:  "2.0.0",
+			accounts: []*AccountConfig{newTestAccount("foo", "foo"), newTestAccount("bar", "bar")},
+		},
+		{
+			name:     "default version",
+			accounts: []*AccountConfig{newTestAccount("foo", "foo")},
+			err:      "spec: accounts require version 2.0.0 or later (got 1.4.0)",
+		},
+		{
+			name:     "version 1.x",
+			version:  "1.4.1",
+			accounts: []*AccountConfig{newTestAccount("foo", "foo")},
+			err:      "spec: accounts require version 2.0.0 or later (got 1.4.1)",
+		},
+		{
+			name:          "system account only",
+			version:       "1.4.1",
+			systemAccount: "SYS",
+			err:           "spec: accounts require version 2.0.0 or later (got 1.4.1)",
+		},
+		{
+			name:     "unnamed account",
+			version:  "2.0.0",
+			accounts: []*AccountConfig{newTestAccount("", "foo")},
+			err:      "spec: accounts: name must be set",
+		},
+		{
+			name:     "duplicate account",
+			version:  "2.0.0",
+			accounts: []*AccountConfig{newTestAccount("foo", "foo"), newTestAccount("foo", "bar")},
+			err:      "spec: accounts: duplicate account \"foo\"",
+		},
+		{
+			name:          "system account",
+			version:       "2.0.0",
+			accounts:      []*AccountConfig{newTestAccount("foo", "foo"), newTestAccount("SYS", "admin")},
+			systemAccount: "SYS",
+		},
+		{
+			name:          "unknown system account",
+			version:       "2.0.0",
+			accounts:      []*AccountConfig{newTestAccount("foo", "foo")},
+			systemAccount: "SYS",
+			err:           "spec: systemAccount: unknown account \"SYS\"",
+		},
+		{
+			name:     "duplicate user within an account",
+			version:  "2.0.0",
+			accounts: []*AccountConfig{newTestAccount("foo", "foo", "foo")},
+			err:      "spec: accounts: foo: users: duplicate user \"foo\"",
+		},
+		{
+			name:     "duplicate user across accounts",
+			version:  "2.0.0",
+			accounts: []*AccountConfig{newTestAccount("foo", "foo"), newTestAccount("bar", "foo")},
+			err:      "spec: accounts: bar: users: duplicate user \"foo\"",
+		},
+		{
+			name:     "user without username",
+			version:  "2.0.0",
+			accounts: []*AccountConfig{newTestAccount("foo", "")},
+			err:      "spec: accounts: foo: users: username must be set",
+		},
+		{
+			name:    "user without password",
+			version: "2.0.0",
+			accounts: []*AccountConfig{
+				{Name: "foo", Users: []*AccountUser{{Username: "foo"}}},
+			},
+			err: "spec: accounts: foo: users: foo: passwordSecretRef: name and key must be set",
+		},
+		{
+			name:    "user with invalid permissions",
+			version: "2.0.0",
+			accounts: []*AccountConfig{
+				withUserPermissions(newTestAccount("foo", "foo"), &Permissions{Publish: []string{"foo..bar"}}),
+			},
+			err: "spec: accounts: foo: users: foo: permissions: publish: subject \"foo..bar\" must not contain empty tokens",
+		},
+		{
+			name:    "export with both stream and service",
+			version: "2.0.0",
+			accounts: []*AccountConfig{
+				withExports(newTestAccount("foo", "foo"), &AccountExport{Stream: "foo.events", Service: "foo.requests"}),
+			},
+			err: "spec: accounts: foo: exports: exactly one of stream and service must be set",
+		},
+		{
+			name:    "export to unknown account",
+			version: "2.0.0",
+			accounts: []*AccountConfig{
+				withExports(newTestAccount("foo", "foo"), &AccountExport{Stream: "foo.events", Accounts: []string{"baz"}}),
+			},
+			err: "spec: accounts: foo: exports: unknown account \"baz\"",
+		},
+		{
+			name:    "import of exported stream and service",
+			version: "2.0.0",
+			accounts: []*AccountConfig{
+				withExports(newTestAccount("foo", "foo"), &AccountExport{Stream: "foo.events"}, &AccountExport{Service: "foo.requests", Accounts: []string{"bar"}}),
+				withImports(newTestAccount("bar", "bar"), &AccountImport{Stream: &AccountImportSource{Account: "foo", Subject: "foo.events"}, Prefix: "foo"}, &AccountImport{Service: &AccountImportSource{Account: "foo", Subject: "foo.requests"}, To: "requests"}),
+			},
+		},
+		{
+			name:    "import covered by wildcard export",
+			version: "2.0.0",
+			accounts: []*AccountConfig{
+				withExports(newTestAccount("foo", "foo"), &AccountExport{Stream: "foo.>"}, &AccountExport{Service: "foo.*.requests"}),
+				withImports(newTestAccount("bar", "bar"), &AccountImport{Stream: &AccountImportSource{Account: "foo", Subject: "foo.events.*"}}, &AccountImport{Service: &AccountImportSource{Account: "foo", Subject: "foo.orders.requests"}}),
+			},
+		},
+		{
+			name:    "import wider than export",
+			version: "2.0.0",
+			accounts: []*AccountConfig{
+				withExports(newTestAccount("foo", "foo"), &AccountExport{Stream: "foo.events.*"}),
+				withImports(newTestAccount("bar", "bar"), &AccountImport{Stream: &AccountImportSource{Account: "foo", Subject: "foo.events.>"}}),
+			},
+			err: "spec: accounts: bar: imports: account \"foo\" does not export stream \"foo.events.>\" to this account",
+		},
+		{
+			name:    "import of subject not exported",
+			version: "2.0.0",
+			accounts: []*AccountConfig{
+				newTestAccount("foo", "foo"),
+				withImports(newTestAccount("bar", "bar"), &AccountImport{Stream: &AccountImportSource{Account: "foo", Subject: "foo.events"}}),
+			},
+			err: "spec: accounts: bar: imports: account \"foo\" does not export stream \"foo.events\" to this account",
+		},
+		{
+			name:    "import of service exported as stream",
+			version: "2.0.0",
+			accounts: []*AccountConfig{
+				withExports(newTestAccount("foo", "foo"), &AccountExport{Stream: "foo.requests"}),
+				withImports(newTestAccount("bar", "bar"), &AccountImport{Service: &AccountImportSource{Account: "foo", Subject: "foo.requests"}}),
+			},
+			err: "spec: accounts: bar: imports: account \"foo\" does not export service \"foo.requests\" to this account",
+		},
+		{
+			name:    "import of export restricted to another account",
+			version: "2.0.0",
+			accounts: []*AccountConfig{
+				withExports(newTestAccount("foo", "foo"), &AccountExport{Service: "foo.requests", Accounts: []string{"baz"}}),
+				withImports(newTestAccount("bar", "bar"), &AccountImport{Service: &AccountImportSource{Account: "foo", Subject: "foo.requests"}}),
+				newTestAccount("baz", "baz"),
+			},
+			err: "spec: accounts: bar: imports: account \"foo\" does not export service \"foo.requests\" to this account",
+		},
+		{
+			name:    "import from unknown account",
+			version: "2.0.0",
+			accounts: []*AccountConfig{
+				withImports(newTestAccount("bar", "bar"), &AccountImport{Stream: &AccountImportSource{Account: "foo", Subject: "foo.events"}}),
+			},
+			err: "spec: accounts: bar: imports: unknown account \"foo\"",
+		},
+		{
+			name:    "import from the same account",
+			version: "2.0.0",
+			accounts: []*AccountConfig{
+				withImports(withExports(newTestAccount("foo", "foo"), &AccountExport{Stream: "foo.events"}), &AccountImport{Stream: &AccountImportSource{Account: "foo", Subject: "foo.events"}}),
+			},
+			err: "spec: accounts: foo: imports: cannot import from the same account",
+		},
+		{
+			name:    "import with both stream and service",
+			version: "2.0.0",
+			accounts: []*AccountConfig{
+				newTestAccount("foo", "foo"),
+				withImports(newTestAccount("bar", "bar"), &AccountImport{Stream: &AccountImportSource{Account: "foo", Subject: "foo.events"}, Service: &AccountImportSource{Account: "foo", Subject: "foo.requests"}}),
+			},
+			err: "spec: accounts: bar: imports: exactly one of stream and service must be set",
+		},
+		{
+			name:    "stream import with to",
+			version: "2.0.0",
+			accounts: []*AccountConfig{
+				withExports(newTestAccount("foo", "foo"), &AccountExport{Stream: "foo.events"}),
+				withImports(newTestAccount("bar", "bar"), &AccountImport{Stream: &AccountImportSource{Account: "foo", Subject: "foo.events"}, To: "events"}),
+			},
+			err: "spec: accounts: bar: imports: to can only be set when importing a service",
+		},
+		{
+			name:    "service import with prefix",
+			version: "2.0.0",
+			accounts: []*AccountConfig{
+				withExports(newTestAccount("foo", "foo"), &AccountExport{Service: "foo.requests"}),
+				withImports(newTestAccount("bar", "bar"), &AccountImport{Service: &AccountImportSource{Account: "foo", Subject: "foo.requests"}, Prefix: "foo"}),
+			},
+			err: "spec: accounts: bar: imports: prefix can only be set when importing a stream",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := ClusterSpec{Size: 1, Version: tt.version, Accounts: tt.accounts, SystemAccount: tt.systemAccount}
+			err := spec.validateAccounts()
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("Error: %s", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("Expected error %q, got: %v", tt.err, err)
+			}
+		})
+	}
+}
+
+// newTestAccount returns an account with the specified name and users, each of which reads its password from a key of the "accounts" secret named after it.
+func newTestAccount(name string, usernames ...string) *AccountConfig {
+	account := &AccountConfig{Name: name}
+	for _, username := range usernames {
+		account.Users = append(account.Users, &AccountUser{
+			Username: username,
+			PasswordSecretRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{Name: "accounts"},
+				Key:                  username,
+			},
+		})
+	}
+	return account
+}
+
+// withUserPermissions sets the specified permissions on all the users of the specified account.
+func withUserPermissions(account *AccountConfig, permissions *Permissions) *AccountConfig {
+	for _, user := range account.Users {
+		user.Permissions = permissions
+	}
+	return account
+}
+
+// withExports adds the specified exports to the specified account.
+func withExports(account *AccountConfig, exports ...*AccountExport) *AccountConfig {
+	account.Exports = append(account.Exports, exports...)
+	return account
+}
+
+// withImports adds the specified imports to the specified account.
+func withImports(account *AccountConfig, imports ...*AccountImport) *AccountConfig {
+	account.Imports = append(account.Imports, imports...)
+	return account
+}
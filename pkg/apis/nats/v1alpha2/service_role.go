@@ -15,6 +15,10 @@
 package v1alpha2
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -61,3 +65,65 @@ type Permissions struct {
 	Publish   []string `json:"publish,omitempty"`
 	Subscribe []string `json:"subscribe,omitempty"`
 }
+
+// Validate checks whether the spec is valid, returning an error describing the first problem found otherwise.
+func (s *ServiceRoleSpec) Validate() error {
+	return s.Permissions.Validate()
+}
+
+// Validate checks whether all the subjects in the permissions are well-formed.
+func (p *Permissions) Validate() error {
+	for _, subject := range p.Publish {
+		if err := validateSubject(subject); err != nil {
+			return fmt.Errorf("spec: permissions: publish: %v", err)
+		}
+	}
+	for _, subject := range p.Subscribe {
+		if err := validateSubject(subject); err != nil {
+			return fmt.Errorf("spec: permissions: subscribe: %v", err)
+		}
+	}
+	return nil
+}
+
+// validateSubject checks whether the specified string is a well-formed NATS subject.
+// Subjects are made of non-empty, dot-separated tokens, where the "*" and ">" wildcards must appear as full tokens and ">" must be the last token.
+func validateSubject(subject string) error {
+	if len(subject) == 0 {
+		return errors.New("subject must not be empty")
+	}
+	if strings.ContainsAny(subject, " \t\r\n") {
+		return fmt.Errorf("subject %q must not contain whitespace", subject)
+	}
+	tokens := strings.Split(subject, ".")
+	for i, token := range tokens {
+		switch {
+		case len(token) == 0:
+			return fmt.Errorf("subject %q must not contain empty tokens", subject)
+		case token == ">" && i != len(tokens)-1:
+			return fmt.Errorf("subject %q must only use \">\" as its last token", subject)
+		case len(token) > 1 && strings.ContainsAny(token, "*>"):
+			return fmt.Errorf("subject %q must only use wildcards as full tokens", subject)
+		}
+	}
+	return nil
+}
+
+// subjectContains returns whether every subject matched by the specified subject (possibly with wildcards) is also matched by the specified pattern.
+// Both subjects are assumed to be well-formed.
+func subjectContains(pattern, subject string) bool {
+	p := strings.Split(pattern, ".")
+	s := strings.Split(subject, ".")
+	for i, token := range p {
+		switch {
+		case token == ">":
+			return len(s) > i
+		case i >= len(s):
+			return false
+		case token == "*" && s[i] != ">":
+		case token != s[i]:
+			return false
+		}
+	}
+	return len(p) == len(s)
+}
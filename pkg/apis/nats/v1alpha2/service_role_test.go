@@ -0,0 +1,143 @@
+// Copyright 2019 The nats-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha2
+
+import (
+	"testing"
+)
+
+func TestValidateSubject(t *testing.T) {
+	tests := []struct {
+		subject string
+		valid   bool
+	}{
+		{subject: "foo", valid: true},
+		{subject: "foo.bar", valid: true},
+		{subject: "foo.*", valid: true},
+		{subject: "*.bar", valid: true},
+		{subject: "foo.*.baz", valid: true},
+		{subject: "foo.>", valid: true},
+		{subject: "*.>", valid: true},
+		{subject: ">", valid: true},
+		{subject: "*", valid: true},
+		{subject: "_INBOX.abc123", valid: true},
+		{subject: "", valid: false},
+		{subject: "foo bar", valid: false},
+		{subject: "foo\tbar", valid: false},
+		{subject: "foo.", valid: false},
+		{subject: ".foo", valid: false},
+		{subject: "foo..bar", valid: false},
+		{subject: "foo.>.bar", valid: false},
+		{subject: ">.foo", valid: false},
+		{subject: "foo*", valid: false},
+		{subject: "foo.bar*", valid: false},
+		{subject: "foo.>>", valid: false},
+		{subject: "foo.*>", valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.subject, func(t *testing.T) {
+			err := validateSubject(tt.subject)
+			if tt.valid && err != nil {
+				t.Errorf("Expected %q to be valid, got: %v", tt.subject, err)
+			}
+			if !tt.valid && err == nil {
+				t.Errorf("Expected %q to be invalid", tt.subject)
+			}
+		})
+	}
+}
+
+func TestSubjectContains(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		subject  string
+		contains bool
+	}{
+		{pattern: "foo", subject: "foo", contains: true},
+		{pattern: "foo", subject: "bar", contains: false},
+		{pattern: "foo", subject: "foo.bar", contains: false},
+		{pattern: "foo.bar", subject: "foo", contains: false},
+		{pattern: "foo.*", subject: "foo.bar", contains: true},
+		{pattern: "foo.*", subject: "foo.*", contains: true},
+		{pattern: "foo.*", subject: "foo.>", contains: false},
+		{pattern: "foo.*", subject: "foo.bar.baz", contains: false},
+		{pattern: "foo.bar", subject: "foo.*", contains: false},
+		{pattern: "foo.>", subject: "foo.bar.baz", contains: true},
+		{pattern: "foo.>", subject: "foo.*", contains: true},
+		{pattern: "foo.>", subject: "foo.>", contains: true},
+		{pattern: "foo.>", subject: "foo", contains: false},
+		{pattern: "*.>", subject: "foo.bar", contains: true},
+		{pattern: ">", subject: "foo", contains: true},
+		{pattern: "foo.*.baz", subject: "foo.bar.baz", contains: true},
+		{pattern: "foo.*.baz", subject: "foo.bar.qux", contains: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.subject, func(t *testing.T) {
+			if contains := subjectContains(tt.pattern, tt.subject); contains != tt.contains {
+				t.Errorf("Expected %+v, got: %+v", tt.contains, contains)
+			}
+		})
+	}
+}
+
+func TestServiceRoleSpecValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		spec  ServiceRoleSpec
+		valid bool
+	}{
+		{
+			name:  "empty",
+			spec:  ServiceRoleSpec{},
+			valid: true,
+		},
+		{
+			name: "valid permissions",
+			spec: ServiceRoleSpec{
+				Permissions: Permissions{
+					Publish:   []string{"foo.>"},
+					Subscribe: []string{"bar.*", "_INBOX.>"},
+				},
+			},
+			valid: true,
+		},
+		{
+			name: "invalid publish subject",
+			spec: ServiceRoleSpec{
+				Permissions: Permissions{Publish: []string{"foo.>.bar"}},
+			},
+		},
+		{
+			name: "invalid subscribe subject",
+			spec: ServiceRoleSpec{
+				Permissions: Permissions{Subscribe: []string{"foo*"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.spec.Validate()
+			if tt.valid && err != nil {
+				t.Errorf("Error: %s", err)
+			}
+			if !tt.valid && err == nil {
+				t.Errorf("Expected an error")
+			}
+		})
+	}
+}
@@ -98,6 +98,18 @@ func (c *FakeNatsClusters) Update(natsCluster *v1alpha2.NatsCluster) (result *v1
 	return obj.(*v1alpha2.NatsCluster), err
 }
 
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeNatsClusters) UpdateStatus(natsCluster *v1alpha2.NatsCluster) (*v1alpha2.NatsCluster, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateSubresourceAction(natsclustersResource, "status", c.ns, natsCluster), &v1alpha2.NatsCluster{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha2.NatsCluster), err
+}
+
 // Delete takes name of the natsCluster and deletes it. Returns an error if one occurs.
 func (c *FakeNatsClusters) Delete(name string, options *v1.DeleteOptions) error {
 	_, err := c.Fake.
@@ -35,6 +35,7 @@ type NatsClustersGetter interface {
 type NatsClusterInterface interface {
 	Create(*v1alpha2.NatsCluster) (*v1alpha2.NatsCluster, error)
 	Update(*v1alpha2.NatsCluster) (*v1alpha2.NatsCluster, error)
+	UpdateStatus(*v1alpha2.NatsCluster) (*v1alpha2.NatsCluster, error)
 	Delete(name string, options *v1.DeleteOptions) error
 	DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error
 	Get(name string, options v1.GetOptions) (*v1alpha2.NatsCluster, error)
@@ -118,6 +119,22 @@ func (c *natsClusters) Update(natsCluster *v1alpha2.NatsCluster) (result *v1alph
 	return
 }
 
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+
+func (c *natsClusters) UpdateStatus(natsCluster *v1alpha2.NatsCluster) (result *v1alpha2.NatsCluster, err error) {
+	result = &v1alpha2.NatsCluster{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("natsclusters").
+		Name(natsCluster.Name).
+		SubResource("status").
+		Body(natsCluster).
+		Do().
+		Into(result)
+	return
+}
+
 // Delete takes name of the natsCluster and deletes it. Returns an error if one occurs.
 func (c *natsClusters) Delete(name string, options *v1.DeleteOptions) error {
 	return c.client.Delete().
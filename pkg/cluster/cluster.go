@@ -27,11 +27,12 @@ import (
 	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 	corev1listers "k8s.io/client-go/listers/core/v1"
+	policyv1beta1listers "k8s.io/client-go/listers/policy/v1beta1"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/kubernetes/pkg/util/slice"
 
 	"github.com/nats-io/nats-operator/pkg/apis/nats/v1alpha2"
@@ -49,14 +50,11 @@ var (
 )
 
 const (
-	// defaultNatsLameDuckDuration is the default duration for the "lame duck" mode.
-	// https://github.com/nats-io/gnatsd/blob/master/server/const.go#L136-L138
-	defaultNatsLameDuckDuration = 2 * time.Minute
-	// podDeletionTimeout is the maximum amount of time we wait for a pod to be actually deleted from the Kubernetes API after we delete it.
-	podDeletionTimeout = 3 * time.Minute
 	// podExecTimeout is the maximum amount of time we wait for an "exec" call to a container in a pod to produce a result.
 	podExecTimeout = 10 * time.Second
-	// podReadinessTimeout is the maximum amount of time we wait for a pod to be ready after we create/upgrade it.
+	// podObservationTimeout is the maximum amount of time we wait for a pod we've created to be observed by the pod informer.
+	podObservationTimeout = 1 * time.Minute
+	// podReadinessTimeout is the maximum amount of time we wait for a running pod to become ready before we stop waiting for it.
 	podReadinessTimeout = 5 * time.Minute
 )
 
@@ -64,11 +62,14 @@ type Config struct {
 	// Deprecated: Use KubeClient and its CoreV1() function instead.
 	KubeCli corev1client.CoreV1Interface
 	// Deprecated: Use NatsClient and its NatsV1alpha2() function instead.
-	OperatorCli           natsalphav2client.NatsV1alpha2Interface
-	PodLister             corev1listers.PodLister
-	SecretLister          corev1listers.SecretLister
-	ServiceLister         corev1listers.ServiceLister
-	NatsServiceRoleLister natslisters.NatsServiceRoleLister
+	OperatorCli               natsalphav2client.NatsV1alpha2Interface
+	PodLister                 corev1listers.PodLister
+	SecretLister              corev1listers.SecretLister
+	ServiceLister             corev1listers.ServiceLister
+	PodDisruptionBudgetLister policyv1beta1listers.PodDisruptionBudgetLister
+	NatsServiceRoleLister     natslisters.NatsServiceRoleLister
+	// EventRecorder is used to record events concerning NatsCluster resources.
+	EventRecorder record.EventRecorder
 
 	KubeClient kubernetes.Interface
 	KubeConfig *rest.Config
@@ -87,6 +88,9 @@ type Cluster struct {
 	// originalCluster holds the original, unmodified NatsCluster resource.
 	// Used to create a patch in the end of the reconcile loop.
 	originalCluster *v1alpha2.NatsCluster
+	// requeueAfter is the amount of time after which the NatsCluster resource must be reconciled again, regardless of any events concerning it.
+	// It is used when waiting for something that isn't signaled by an event (such as a timeout expiring).
+	requeueAfter time.Duration
 }
 
 // New returns a new instance of the reconciler for NatsCluster resources.
@@ -101,7 +105,18 @@ func New(config Config, cl *v1alpha2.NatsCluster) *Cluster {
 }
 
 // Reconcile looks at the current state of the associated NatsCluster resource and attempts to drive it towards the desired state.
+// Reconcile never blocks waiting for pods to change state.
+// Instead, it records any operation in flight in the status of the NatsCluster resource and returns, relying on events for the pods to be called again.
 func (c *Cluster) Reconcile() error {
+	// Report the current size and version of the cluster once we are done, regardless of the outcome of the current iteration.
+	defer c.reportMetrics()
+
+	// Gracefully remove all the members of the cluster in case the NatsCluster resource is being deleted.
+	// This takes precedence over pausing, as the resource would otherwise never be deleted.
+	if c.cluster.DeletionTimestamp != nil {
+		return c.teardown()
+	}
+
 	// Exit immediately in case the NatsCluster resource is marked as paused.
 	if c.cluster.Spec.Paused {
 		c.logger.Infof("control is paused, skipping reconciliation")
@@ -109,35 +124,92 @@ func (c *Cluster) Reconcile() error {
 		return c.updateCluster()
 	}
 
-	// Mark the NatsCluster resource as being active.
+	// Set default values for any unset fields in the spec.
+	// These are only persisted by the mutating admission webhook, and are applied in memory here so that resources created while it was not deployed are reconciled in the same way without fighting whoever owns the spec.
+	c.cluster.Spec.SetDefaults()
+
+	// Mark the NatsCluster resource as being active, and take note of the generation we are acting upon.
 	c.cluster.Status.Control()
+	// Resources which haven't been acted upon yet are reported as being created until they become ready for the first time.
+	if c.cluster.Status.Phase == v1alpha2.ClusterPhaseNone {
+		c.cluster.Status.SetPhase(v1alpha2.ClusterPhaseCreating)
+	}
+	c.cluster.Status.SetObservedGeneration(c.cluster.Generation)
+	c.cluster.Status.SetSelector(kubernetesutil.LabelSelectorForCluster(c.cluster.Name).String())
+
+	// Make sure that the members of the cluster are gracefully removed when the NatsCluster resource is deleted.
+	// The finalizer is persisted at the end of the current iteration.
+	c.cluster.AddFinalizer(v1alpha2.ClusterFinalizer)
+
+	// Refuse to act upon invalid specs, which may still reach us in case the validating admission webhook is not deployed.
+	// There is no point in retrying until the spec changes, so we just report the problem and return.
+	if err := c.cluster.Spec.Validate(); err != nil {
+		c.logger.Errorf("refusing to reconcile invalid spec: %v", err)
+		c.recordWarningEvent("InvalidSpec", "refusing to reconcile invalid spec: %v", err)
+		c.cluster.Status.SetDegradedCondition("InvalidSpec", err.Error())
+		return c.updateStatus()
+	}
 
 	// Take note of the current time so we can later report the duration of the current iteration.
 	start := time.Now()
 
 	// Make sure that both the client and management services for the current cluster have been created.
 	if err := c.checkServices(); err != nil {
-		return fmt.Errorf("failed to create services: %v", err)
+		return c.reportFailure("ServicesFailed", fmt.Errorf("failed to create services: %v", err))
+	}
+
+	// Make sure that the snippet of configuration to merge on top of the generated one (if any) can be read and is valid.
+	// Snippets held in a ConfigMap or Secret cannot be checked by the validating admission webhook, so problems with these are reported in the status and the configuration is left untouched until they are fixed.
+	if err := c.checkRawConfig(); err != nil {
+		return c.reportFailure("InvalidNatsConfig", err)
+	}
+
+	// Make sure that tokens have been issued for the service accounts mapped by NatsServiceRole resources, as rendering the configuration only reads them.
+	if err := kubernetesutil.IssueServiceRoleTokens(c.config.KubeCli, c.config.OperatorCli, c.cluster); err != nil {
+		return c.reportFailure("ServiceRoleTokensFailed", fmt.Errorf("failed to issue service role tokens: %v", err))
 	}
 
 	// Make sure that the configuration secret for the current cluster has been created.
 	if err := c.checkConfigSecret(); err != nil {
-		return fmt.Errorf("failed to create config secret: %s", err)
+		return c.reportFailure("ConfigSecretFailed", fmt.Errorf("failed to create config secret: %s", err))
+	}
+
+	// Make sure that the PodDisruptionBudget for the current cluster is in sync with the spec.
+	if err := c.checkPodDisruptionBudget(); err != nil {
+		return c.reportFailure("PodDisruptionBudgetFailed", fmt.Errorf("failed to reconcile pod disruption budget: %v", err))
 	}
 
 	// If the current NatsCluster resource has authentication configured, make sure that the configuration is in sync with the secrets.
 	if c.cluster.Spec.Auth != nil {
 		err := c.checkClientAuthUpdate()
 		if err != nil {
-			return fmt.Errorf("failed to update auth data in config secret: %v", err)
+			return c.reportFailure("AuthConfigFailed", fmt.Errorf("failed to update auth data in config secret: %v", err))
+		}
+	}
+
+	// If there is an operation in flight, attempt to move it forward.
+	// Unless the operation has completed, exit cleanly and wait for the next reconcile iteration (which will happen as soon as the state of the target pod changes).
+	if c.cluster.Status.PendingOperation != nil {
+		done, err := c.resumePendingOperation()
+		if err != nil {
+			reconcileFailed.WithLabelValues("failed to resume pending operation").Inc()
+			return c.reportFailure("PendingOperationFailed", fmt.Errorf("failed to resume pending operation: %v", err))
+		}
+		if !done {
+			return c.updateCluster()
 		}
 	}
 
 	// Poll pods in order to understand which are pending and which must be deleted.
-	_, waiting, deletable, err := c.pollPods()
+	running, waiting, deletable, err := c.pollPods()
 	if err != nil {
 		reconcileFailed.WithLabelValues("failed to poll pods").Inc()
-		return fmt.Errorf("failed to poll pods: %v", err)
+		return c.reportFailure("PollPodsFailed", fmt.Errorf("failed to poll pods: %v", err))
+	}
+
+	// Take note of any upgraded pods which have failed, rolling the upgrade in progress back if required.
+	if err := c.trackUpgrade(append(append(running, waiting...), deletable...)); err != nil {
+		return c.reportFailure("UpgradeFailed", fmt.Errorf("failed to track upgrade: %v", err))
 	}
 
 	// Delete all pods in terminal phases.
@@ -145,21 +217,45 @@ func (c *Cluster) Reconcile() error {
 		c.logger.Warnf("deleting pod %q in terminal phase %q", kubernetesutil.ResourceKey(pod), pod.Status.Phase)
 		if err := c.deletePod(pod); err != nil {
 			c.logger.Errorf("failed to delete pod %q: %v", kubernetesutil.ResourceKey(pod), err)
-			return err
+			return c.reportFailure("PodDeletionFailed", err)
 		}
 	}
 
-	// If there are pods in "waiting" state, exit cleanly and wait for the next reconcile iteration (which will happen as soon as these pods become running/succeeded/failed).
-	// This should not happen often in practice, as createPod waits for pods to be running before returning, but it is still a good safety measure.
+	// If there are pods in "waiting" state, exit cleanly and wait for the next reconcile iteration (which will happen as soon as these pods become ready or are deleted).
+	// Running pods only remain in this state until "podReadinessTimeout" elapses, so we make sure to be called again by then.
 	if len(waiting) > 0 {
 		c.logger.Infof("skipping reconciliation as there are %d waiting pods (%v)", len(waiting), kubernetesutil.GetPodNames(waiting))
-		return nil
+		for _, pod := range waiting {
+			if pod.Status.Phase == v1.PodRunning && pod.DeletionTimestamp == nil {
+				c.requeue(podReadinessTimeout - time.Since(notReadySince(pod)))
+			}
+		}
+		return c.updateCluster()
 	}
 
-	// Reconcile the size and version of the cluster.
-	if err := c.checkPods(); err != nil {
+	// Reconcile the size, version and spec of the pods in the cluster.
+	// In case an operation has been started, exit cleanly and wait for the next reconcile iteration.
+	converged, err := c.checkPods()
+	if err != nil {
 		reconcileFailed.WithLabelValues("failed to reconcile pods").Inc()
-		return fmt.Errorf("failed to reconcile pods: %v", err)
+		return c.reportFailure("PodsFailed", fmt.Errorf("failed to reconcile pods: %v", err))
+	}
+	if !converged {
+		return c.updateCluster()
+	}
+
+	// Make sure that the members of the cluster form a full mesh, in case periodic checks of the route mesh have been requested.
+	// An unhealthy mesh keeps the cluster from being reported as ready, and may lead to a member being replaced.
+	if c.cluster.Spec.MeshHealth != nil {
+		healthy, err := c.checkMeshHealth()
+		if err != nil {
+			return c.reportFailure("MeshHealthCheckFailed", fmt.Errorf("failed to check the route mesh: %v", err))
+		}
+		if !healthy {
+			return c.updateCluster()
+		}
+	} else {
+		c.cluster.Status.ClearMeshHealth()
 	}
 
 	// Mark the cluster as ready.
@@ -185,6 +281,7 @@ func (c *Cluster) checkClientAuthUpdate() error {
 			return err
 		}
 		if c.cluster.GetClientAuthSecretResourceVersion() != result.ResourceVersion {
+			c.recordNormalEvent(eventReasonAuthUpdated, "auth secret %q has changed", result.Name)
 			c.cluster.SetClientAuthSecretResourceVersion(result.ResourceVersion)
 			return c.updateConfigSecret()
 		}
@@ -216,6 +313,7 @@ func (c *Cluster) checkClientAuthUpdate() error {
 
 		// Update the configuration if the hashes differ.
 		if currentHash != desiredHash {
+			c.recordNormalEvent(eventReasonAuthUpdated, "the service roles of the cluster have changed")
 			c.cluster.SetNatsServiceRolesHash(desiredHash)
 			return c.updateConfigSecret()
 		}
@@ -223,55 +321,36 @@ func (c *Cluster) checkClientAuthUpdate() error {
 	return nil
 }
 
-// checkServices makes sure that the client and management services exist.
+// checkServices makes sure that the client and management services exist and match the desired state.
 func (c *Cluster) checkServices() error {
-	var (
-		// mustCreateClientService indicates whether we must create the client service.
-		mustCreateClientService bool
-		// mustCreateManagementService indicates whether we must create the management service.
-		mustCreateManagementService bool
-	)
-
-	// Check whether the client service already exists.
-	if _, err := c.config.ServiceLister.Services(c.cluster.Namespace).Get(kubernetesutil.ClientServiceName(c.cluster.Name)); err != nil {
-		if kubernetesutil.IsKubernetesResourceNotFoundError(err) {
-			// The client service does not exist, so we must create it.
-			mustCreateClientService = true
-		} else {
-			// We've got an unexpected error while getting the service.
-			return err
-		}
+	desired := []*v1.Service{
+		kubernetesutil.NewClientService(c.cluster.Name, c.cluster.Namespace, c.cluster.AsOwner()),
+		kubernetesutil.NewMgmtService(c.cluster.Name, c.desiredVersion(), c.cluster.Namespace, c.cluster.AsOwner()),
 	}
-
-	// Create the client service if required.
-	if mustCreateClientService {
-		if err := kubernetesutil.CreateClientService(c.config.KubeCli, c.cluster.Name, c.cluster.Namespace, c.cluster.AsOwner()); err != nil {
-			return err
-		}
-	}
-
-	// Check whether we need to create the management service.
-	if _, err := c.config.ServiceLister.Services(c.cluster.Namespace).Get(kubernetesutil.ManagementServiceName(c.cluster.Name)); err != nil {
-		if kubernetesutil.IsKubernetesResourceNotFoundError(err) {
-			// The management service does not exist, so we must create it.
-			mustCreateManagementService = true
-		} else {
-			// We've got an unexpected error while getting the service.
-			return err
+	for _, svc := range desired {
+		// Check whether the service already exists.
+		current, err := c.config.ServiceLister.Services(c.cluster.Namespace).Get(svc.Name)
+		if err != nil {
+			if !kubernetesutil.IsKubernetesResourceNotFoundError(err) {
+				// We've got an unexpected error while getting the service.
+				return err
+			}
+			// The service does not exist, so we must create it.
+			if err := kubernetesutil.CreateService(c.config.KubeCli, svc); err != nil && !kubernetesutil.IsKubernetesResourceAlreadyExistError(err) {
+				return err
+			}
+			continue
 		}
-	}
-
-	// Create the management service if required.
-	if mustCreateManagementService {
-		if err := kubernetesutil.CreateMgmtService(c.config.KubeCli, c.cluster.Name, c.cluster.Spec.Version, c.cluster.Namespace, c.cluster.AsOwner()); err != nil {
+		// The service exists, so we make sure that it hasn't drifted from the desired state.
+		if _, err := c.applyService(current, svc); err != nil {
 			return err
 		}
 	}
-
 	return nil
 }
 
 // checkConfigSecret makes sure that the secret used to hold the configuration for the current NATS cluster exists.
+// Its contents are brought in line with the desired configuration by updateConfigSecret at the end of every reconcile iteration.
 func (c *Cluster) checkConfigSecret() error {
 	var (
 		// mustCreateSecret indicates whether we must create the configuration secret.
@@ -291,114 +370,189 @@ func (c *Cluster) checkConfigSecret() error {
 
 	// Create the secret if required.
 	if mustCreateSecret {
-		return kubernetesutil.CreateConfigSecret(c.config.KubeCli, c.config.OperatorCli, c.cluster.Name, c.cluster.Namespace, c.cluster.Spec, c.cluster.AsOwner())
+		return kubernetesutil.CreateConfigSecret(c.config.KubeCli, c.config.OperatorCli, c.cluster)
 	}
 
 	return nil
 }
 
-func (c *Cluster) updateConfigSecret() error {
-	return kubernetesutil.UpdateConfigSecret(c.config.KubeCli, c.config.OperatorCli, c.cluster.Name, c.cluster.Namespace, c.cluster.Spec, c.cluster.AsOwner())
-}
+// checkPodDisruptionBudget makes sure that the PodDisruptionBudget for the current NATS cluster exists and is up-to-date if enabled, and that it doesn't exist otherwise.
+// Since the spec of a PodDisruptionBudget cannot be updated, an outdated one is deleted and created again.
+func (c *Cluster) checkPodDisruptionBudget() error {
+	name := kubernetesutil.PodDisruptionBudgetName(c.cluster.Name)
+	pdbs := c.config.KubeClient.PolicyV1beta1().PodDisruptionBudgets(c.cluster.Namespace)
 
-// createPod creates a pod using the first available name.
-// Pod names are of the form "<natscluster-name>-<idx>", where "<idx>" is a base-16 integer.
-func (c *Cluster) createPod() (*v1.Pod, error) {
-	// Grab the list of currently running pods.
-	// It is acceptable to call pollPods every time we create a new pod as it uses the pod lister (instead of hitting the Kubernetes API directly).
-	pods, _, _, err := c.pollPods()
+	// Check whether the PodDisruptionBudget already exists.
+	current, err := c.config.PodDisruptionBudgetLister.PodDisruptionBudgets(c.cluster.Namespace).Get(name)
 	if err != nil {
-		return nil, err
+		if !kubernetesutil.IsKubernetesResourceNotFoundError(err) {
+			// We've got an unexpected error while getting the PodDisruptionBudget.
+			return err
+		}
+		current = nil
 	}
-
-	// Grab a slice containing all existing pod names.
-	podNames := make([]string, len(pods))
-	for _, pod := range pods {
-		podNames = append(podNames, pod.Name)
+	// Never touch a PodDisruptionBudget that we don't own.
+	if current != nil && !metav1.IsControlledBy(current, c.cluster) {
+		c.logger.Warnf("ignoring pod disruption budget %q not owned by the cluster", kubernetesutil.ResourceKey(current))
+		return nil
 	}
 
-	var (
-		name string
-	)
+	// Delete the PodDisruptionBudget in case it has been disabled.
+	if c.cluster.Spec.DisruptionBudget == nil {
+		if current == nil {
+			return nil
+		}
+		c.logger.Infof("deleting pod disruption budget %q", kubernetesutil.ResourceKey(current))
+		if err := pdbs.Delete(name, &metav1.DeleteOptions{}); err != nil && !kubernetesutil.IsKubernetesResourceNotFoundError(err) {
+			return err
+		}
+		return nil
+	}
 
-	// Use the first name not found in the podNames slice.
-	for i := 1; i <= c.cluster.Spec.Size; i++ {
-		name = fmt.Sprintf("%s-%x", c.cluster.Name, i)
-		if !slice.ContainsString(podNames, name, nil) {
-			break
+	// Compute the desired PodDisruptionBudget based on the current size of the cluster.
+	desired := kubernetesutil.NewPodDisruptionBudget(c.cluster.Name, c.cluster.Namespace, c.cluster.Spec.DisruptionBudget.GetMinAvailable(c.cluster.Spec.Size), c.cluster.AsOwner())
+	if current != nil {
+		if reflect.DeepEqual(current.Spec, desired.Spec) {
+			return nil
+		}
+		c.logger.Infof("replacing outdated pod disruption budget %q", kubernetesutil.ResourceKey(current))
+		if err := pdbs.Delete(name, &metav1.DeleteOptions{}); err != nil && !kubernetesutil.IsKubernetesResourceNotFoundError(err) {
+			return err
+		}
+	}
+	if _, err := pdbs.Create(desired); err != nil {
+		if !kubernetesutil.IsKubernetesResourceAlreadyExistError(err) {
+			return err
+		}
+		// If we've just deleted the outdated PodDisruptionBudget, the one that already exists is the outdated one, which hasn't been removed yet.
+		// Report this as a failure so that the NatsCluster resource is requeued and creation is attempted again, instead of silently keeping the outdated PodDisruptionBudget around.
+		if current != nil {
+			return fmt.Errorf("outdated pod disruption budget %q has not been deleted yet", kubernetesutil.ResourceKey(current))
 		}
 	}
+	return nil
+}
 
-	// Create the pod.
-	pod := kubernetesutil.NewNatsPodSpec(c.cluster.Namespace, name, c.cluster.Name, c.cluster.Spec, c.cluster.AsOwner())
-	pod, err = c.config.KubeCli.Pods(c.cluster.Namespace).Create(pod)
+// checkRawConfig makes sure that the snippet of configuration specified in ".spec.natsConfig.raw" or referenced by ".spec.natsConfig.rawFrom" (if any) can be merged on top of the generated configuration.
+func (c *Cluster) checkRawConfig() error {
+	raw, err := kubernetesutil.GetRawConfig(c.config.KubeCli, c.cluster.Namespace, c.cluster.Spec)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to get raw config: %v", err)
 	}
-
-	// Wait for the pod to be running and ready.
-	c.logger.Infof("waiting for pod %q to become ready", kubernetesutil.ResourceKey(pod))
-	ctx, fn := context.WithTimeout(context.Background(), podReadinessTimeout)
-	defer fn()
-	if err := kubernetesutil.WaitUntilPodReady(ctx, c.config.KubeCli, pod); err != nil {
-		return nil, err
+	if _, err := v1alpha2.ParseRawConfig(raw); err != nil {
+		return fmt.Errorf("invalid raw config: %v", err)
 	}
-	c.logger.Infof("pod %q became ready", kubernetesutil.ResourceKey(pod))
-
-	// Append the newly created pod to the slice of existing pods.
-	pods = append(pods, pod)
-	return pod, err
+	return nil
 }
 
-// tryGracefulPodDeletion attempts to gracefully remove the specified pod from the NATS cluster.
-// It does this by trying to make the "gnatsd" process enter the "lame duck" mode before actually attempting to delete the pod.
-// This is done in a best-effort basis, since the NATS version running in the pod may not support this mode.
-// For that reason, we just log any errors without actually failing and proceed to the actual deletion of the pod.
-func (c *Cluster) tryGracefulPodDeletion(pod *v1.Pod) error {
-	if err := c.enterLameDuckModeAndWaitTermination(pod); err != nil {
-		c.logger.Warn(err)
+// updateConfigSecret brings the secret holding the configuration for the current NATS cluster in line with the desired configuration, which may cause a reload.
+func (c *Cluster) updateConfigSecret() error {
+	desired, err := kubernetesutil.NewConfigSecret(c.config.KubeCli, c.config.OperatorCli, c.cluster)
+	if err != nil {
+		return err
 	}
-	return c.deletePod(pod)
+	current, err := c.config.KubeCli.Secrets(desired.Namespace).Get(desired.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	updated, err := c.applySecret(current, desired)
+	if err != nil {
+		return err
+	}
+	if updated {
+		c.recordNormalEvent(eventReasonConfigUpdated, "updated the configuration in secret %s", current.Name)
+	}
+	return nil
 }
 
-// deletePod removes the specified pod from the current NATS cluster.
-// This function DOES NOT attempt to gracefully shutdown the "gnatsd" process.
-// Hence, it should only be used after having tried a gracefully shutdown.
-func (c *Cluster) deletePod(pod *v1.Pod) error {
-	// Establish a watch on the pod that will notify us when the pod is deleted.
-	// It may happen that deletion takes effect too soon after we make the call to "Delete()" (e.g. in cases when the pod has entered the "lame duck" mode and is already in the "Succeeded" phase).
-	// In these scenarios, we may easily lose the "DELETED" event since we haven't had the opportunity to establish the watch yet.
-	// Hence, we must establish the watch beforehand in a separate goroutine.
-	// We must also wait for the informer to acknowledge the pod (i.e. through an "ADDED" event) before proceeding to the actual deletion.
-	ackCh := make(chan struct{})
-	errCh := make(chan error)
-	go func() {
-		ctx, fn := context.WithTimeout(context.Background(), podDeletionTimeout)
-		defer fn()
-		err := kubernetesutil.WaitUntilPodCondition(ctx, c.config.KubeCli, pod, func(event watch.Event) (bool, error) {
-			switch event.Type {
-			case watch.Added:
-				// Signal that the informer has acknowledged the pod and keep watching.
-				close(ackCh)
-				return false, nil
-			case watch.Error:
-				// Abort watching and propagate an error.
-				return false, fmt.Errorf("got event of type %q for pod %q", event.Type, kubernetesutil.ResourceKey(pod))
-			default:
-				// Only stop watching if the event type is "DELETED".
-				return event.Type == watch.Deleted, nil
+// createPods creates the specified number of pods using the first available names, and records the creation as the pending operation.
+// Pod names are of the form "<natscluster-name>-<idx>", where "<idx>" is a base-16 integer.
+// It does not wait for the pods to become ready, as this is taken care of by subsequent reconcile iterations.
+func (c *Cluster) createPods(n int) error {
+	// Grab the list of existing pods.
+	running, waiting, deletable, err := c.pollPods()
+	if err != nil {
+		return err
+	}
+
+	// Grab a slice containing all existing pod names, regardless of the state of the pods.
+	podNames := make([]string, 0, len(running)+len(waiting)+len(deletable)+n)
+	for _, pods := range [][]*v1.Pod{running, waiting, deletable} {
+		for _, pod := range pods {
+			podNames = append(podNames, pod.Name)
+		}
+	}
+
+	// Record the creation of the pods so that we don't act upon the cluster again before the pod informer has observed all the new pods.
+	// This is done even if we fail to create some of them, so that the ones we did create are accounted for.
+	created := make([]string, 0, n)
+	defer func() {
+		if len(created) > 0 {
+			c.cluster.Status.SetPendingOperation(v1alpha2.ClusterOperationCreatePod, created...)
+		}
+	}()
+
+	// Keep track of the members in each topology domain so that the spread constraints are honored by the pods we create.
+	// This is only done once the first pod has been rendered, as only the nodes matching its node selector are taken into account.
+	var spread *topologySpread
+
+	for i := 0; i < n; i++ {
+		var (
+			name string
+		)
+
+		// Use the first name not found in the podNames slice.
+		for idx := 1; idx <= len(podNames)+1; idx++ {
+			name = fmt.Sprintf("%s-%x", c.cluster.Name, idx)
+			if !slice.ContainsString(podNames, name, nil) {
+				break
 			}
-		})
+		}
+
+		// Render the pod, running the desired version (which may differ from the one in the spec in case an upgrade has been rolled back or is partitioned).
+		spec := c.cluster.Spec
+		spec.Version = c.desiredVersionFor(name)
+		pod, err := kubernetesutil.NewNatsPodSpec(c.cluster.Namespace, name, c.cluster.Name, spec, c.cluster.AsOwner())
 		if err != nil {
-			errCh <- err
-		} else {
-			close(errCh)
+			return fmt.Errorf("failed to render pod %q: %v", name, err)
+		}
+
+		// Restrict the topology domains the pod may be scheduled in.
+		// In case no domain currently satisfies the spread constraints, creating the remaining pods is postponed until the ones we've already created are scheduled.
+		if spread == nil {
+			if spread, err = c.newTopologySpread(pod.Spec.NodeSelector, append(running, waiting...)); err != nil {
+				return err
+			}
+		}
+		if !spread.apply(pod) {
+			c.logger.Infof("postponing the creation of %d pod(s) as no topology domain currently satisfies the spread constraints", n-i)
+			return nil
+		}
+
+		// Make sure the persistent volume claim for the pod exists before creating it.
+		if c.cluster.Spec.Storage != nil {
+			if err := kubernetesutil.CreatePersistentVolumeClaim(c.config.KubeCli, name, c.cluster.Name, c.cluster.Namespace, c.cluster.Spec.Storage, c.cluster.AsOwner()); err != nil {
+				return fmt.Errorf("failed to create persistent volume claim for pod %q: %v", name, err)
+			}
 		}
-	}()
-	// Wait for the informer to acknowledge the pod before proceeding to the actual deletion.
-	<-ackCh
 
-	// Delete the specified pod.
+		// Create the pod.
+		pod, err = c.config.KubeCli.Pods(c.cluster.Namespace).Create(pod)
+		if err != nil {
+			return err
+		}
+		c.logger.Infof("created pod %q", kubernetesutil.ResourceKey(pod))
+		c.recordNormalEvent(eventReasonPodCreated, "created pod %s running version %s", pod.Name, spec.Version)
+		podNames = append(podNames, name)
+		created = append(created, name)
+	}
+	return nil
+}
+
+// deletePod removes the specified pod from the current NATS cluster.
+// This function DOES NOT attempt to gracefully shutdown the "gnatsd" process, and DOES NOT wait for the pod to be actually deleted.
+// Hence, it should only be used after having tried a gracefully shutdown.
+func (c *Cluster) deletePod(pod *v1.Pod) error {
 	err := c.config.KubeCli.Pods(pod.Namespace).Delete(pod.Name, metav1.NewDeleteOptions(podTerminationGracePeriod))
 	if err != nil {
 		if !kubernetesutil.IsKubernetesResourceNotFoundError(err) {
@@ -407,21 +561,17 @@ func (c *Cluster) deletePod(pod *v1.Pod) error {
 		if c.isDebugLoggerEnabled() {
 			c.debugLogger.LogMessage(fmt.Sprintf("pod %q not found while trying to delete it", pod.Name))
 		}
-		// Exit in order to avoid waiting for a pod that doesn't exist anymore.
 		return nil
 	}
-
-	// Wait until the specified pod has been deleted.
-	if err := <-errCh; err != nil {
-		return err
-	}
+	c.recordNormalEvent(eventReasonPodDeleted, "deleted pod %s", pod.Name)
 	if c.isDebugLoggerEnabled() {
 		c.debugLogger.LogPodDeletion(pod)
 	}
 	return nil
 }
 
-// pollPods lists pods belonging to the current NATS cluster, and returns the list of running and pending pods.
+// pollPods lists pods belonging to the current NATS cluster, and returns the list of running, "waiting" and deletable pods.
+// Pods are considered to be "waiting" if they are pending, being deleted, or running but not ready for less than "podReadinessTimeout".
 func (c *Cluster) pollPods() (running []*v1.Pod, waiting []*v1.Pod, deletable []*v1.Pod, err error) {
 	// List existing pods belonging to the current NATS cluster.
 	pods, err := c.config.PodLister.Pods(c.cluster.Namespace).List(kubernetesutil.LabelSelectorForCluster(c.cluster.Name))
@@ -441,9 +591,22 @@ func (c *Cluster) pollPods() (running []*v1.Pod, waiting []*v1.Pod, deletable []
 			c.logger.Warningf("ignoring pod %q with unexpected owner %q", pod.Name, pod.OwnerReferences[0].UID)
 			continue
 		}
+		// Pods which are being deleted are waited for regardless of their phase.
+		if pod.DeletionTimestamp != nil {
+			waiting = append(waiting, pod)
+			continue
+		}
 		// Add the current pod to the appropriate slice based on the current phase.
 		switch pod.Status.Phase {
 		case v1.PodRunning:
+			// Wait for running pods to become ready, but only for a limited amount of time so that a pod that fails to become ready doesn't prevent us from acting upon the cluster.
+			if !kubernetesutil.IsPodRunningAndReady(pod) {
+				if d := time.Since(notReadySince(pod)); d < podReadinessTimeout {
+					waiting = append(waiting, pod)
+					continue
+				}
+				c.logger.Warnf("pod %q has not been ready for %v", kubernetesutil.ResourceKey(pod), podReadinessTimeout)
+			}
 			running = append(running, pod)
 		case v1.PodPending:
 			fallthrough
@@ -478,24 +641,85 @@ func (c *Cluster) updateCluster() error {
 	err := c.updateConfigSecret()
 	if err != nil {
 		c.logger.Errorf("failed to update cluster secret: %v", err)
+		c.recordWarningEvent("ConfigSecretFailed", "failed to update config secret: %v", err)
+	}
+
+	if err := c.patchCluster(); err != nil {
+		return err
 	}
+	return c.updateStatus()
+}
 
-	if reflect.DeepEqual(c.originalCluster, c.cluster) {
+// patchCluster patches the metadata (e.g. annotations and finalizers) of the current NatsCluster resource, if it has changed.
+// The spec is never patched, as it belongs to the user and changing it would bump the generation of the resource.
+// Changes to ".status" are ignored by the main resource, so these must be persisted separately using the "/status" subresource.
+func (c *Cluster) patchCluster() error {
+	if reflect.DeepEqual(c.originalCluster.ObjectMeta, c.cluster.ObjectMeta) {
 		return nil
 	}
-	patchBytes, err := kubernetesutil.CreatePatch(c.originalCluster, c.cluster, &v1alpha2.NatsCluster{})
+	desired := c.originalCluster.DeepCopy()
+	desired.ObjectMeta = *c.cluster.ObjectMeta.DeepCopy()
+	patchBytes, err := kubernetesutil.CreatePatch(c.originalCluster, desired, &v1alpha2.NatsCluster{})
 	if err != nil {
 		return err
 	}
+	result, err := c.config.OperatorCli.NatsClusters(c.cluster.Namespace).Patch(c.cluster.Name, types.MergePatchType, patchBytes)
+	if err != nil {
+		return fmt.Errorf("failed to patch cluster: %v", err)
+	}
+	// Keep track of the new resource version so that a subsequent status update does not result in a conflict.
+	c.cluster.ResourceVersion = result.ResourceVersion
+	c.originalCluster.ObjectMeta = *c.cluster.ObjectMeta.DeepCopy()
+	return nil
+}
 
-	// Patch the NatsCluster resource.
-	_, err = c.config.OperatorCli.NatsClusters(c.cluster.Namespace).Patch(c.cluster.Name, types.MergePatchType, patchBytes)
+// updateStatus persists the status of the current NatsCluster resource using the "/status" subresource, if it has changed.
+func (c *Cluster) updateStatus() error {
+	if reflect.DeepEqual(c.originalCluster.Status, c.cluster.Status) {
+		return nil
+	}
+	result, err := c.config.OperatorCli.NatsClusters(c.cluster.Namespace).UpdateStatus(c.cluster)
 	if err != nil {
 		return fmt.Errorf("failed to update status: %v", err)
 	}
+	// Keep track of the status we've just persisted so that subsequent calls within the current iteration are no-ops unless something changes.
+	c.cluster.ResourceVersion = result.ResourceVersion
+	c.originalCluster.ResourceVersion = result.ResourceVersion
+	c.originalCluster.Status = *c.cluster.Status.DeepCopy()
 	return nil
 }
 
+// reportFailure marks the current NatsCluster resource as degraded with the specified reason and persists its status in a best-effort basis.
+// It returns the specified error so that it can be used directly in return statements.
+func (c *Cluster) reportFailure(reason string, err error) error {
+	c.recordWarningEvent(reason, "%v", err)
+	c.cluster.Status.SetDegradedCondition(reason, err.Error())
+	if err := c.updateStatus(); err != nil {
+		c.logger.Errorf("failed to report failure: %v", err)
+	}
+	return err
+}
+
+// requeue makes sure that the current NatsCluster resource is reconciled again after at most the specified amount of time.
+func (c *Cluster) requeue(d time.Duration) {
+	if d <= 0 {
+		d = time.Second
+	}
+	if c.requeueAfter == 0 || d < c.requeueAfter {
+		c.requeueAfter = d
+	}
+}
+
+// RequeueAfter returns the amount of time after which the current NatsCluster resource must be reconciled again, or zero if it only needs to be reconciled when something changes.
+func (c *Cluster) RequeueAfter() time.Duration {
+	return c.requeueAfter
+}
+
+// reportMetrics updates the per-cluster metrics based on the current status of the NatsCluster resource.
+func (c *Cluster) reportMetrics() {
+	reportClusterMetrics(c.cluster.Namespace, c.cluster.Name, c.cluster.Status.Size, c.cluster.Spec.Size, c.cluster.Status.CurrentVersion)
+}
+
 func (c *Cluster) name() string {
 	return c.cluster.GetName()
 }
@@ -519,10 +743,10 @@ func (c *Cluster) isDebugLoggerEnabled() bool {
 	return false
 }
 
-// enterLameDuckModeAndWaitTermination execs into the "nats" container of the specified pod and attempts to send the "ldm" signal to the "gnatsd" process.
-// In case this succeeds, the funcion blocks until the "nats" container reaches the "Terminated" state (indicating that the "lame duck" mode has been entered and NATS is ready to shutdown) or until a timeout is reached.
+// enterLameDuckMode execs into the "nats" container of the specified pod and attempts to send the "ldm" signal to the "gnatsd" process.
+// In case this succeeds, the "nats" container eventually reaches the "Terminated" state (indicating that NATS is ready to shutdown).
 // Otherwise, it returns an error which should be handled by the caller.
-func (c *Cluster) enterLameDuckModeAndWaitTermination(pod *v1.Pod) error {
+func (c *Cluster) enterLameDuckMode(pod *v1.Pod) error {
 	// Try to place NATS in "lame duck" mode by sending the "gnatsd" process the "ldm" signal.
 	// We wait for at most "podExecTimeout" for the "exec" command to return a result.
 	ctx, fn := context.WithTimeout(context.Background(), podExecTimeout)
@@ -537,39 +761,40 @@ func (c *Cluster) enterLameDuckModeAndWaitTermination(pod *v1.Pod) error {
 		// At this point, we were either explicitly successful at placing the NATS instance in "lame duck" mode, or the "exec" command has timed out and we don't know its result.
 		// In the latter case, it may still be possible that the NATS instance has been placed in "lame duck" mode.
 		// Hence, we should wait for the pod to reach the "Terminated" state in both scenarios.
-		return c.waitNatsContainerTermination(pod)
+		return nil
 	}
 	return fmt.Errorf("failed to place nats in \"lame duck\" mode: %v", err)
 }
 
-// waitNatsContainerTermination blocks until the "nats" container of the specified pod reaches the "Terminated" state, or until twice the value of ".spec.lameDuckDuration".
-func (c *Cluster) waitNatsContainerTermination(pod *v1.Pod) error {
+// lameDuckTimeout returns the maximum amount of time we wait for the "nats" container to terminate after placing it in "lame duck" mode.
+// This is twice the specified (or default) duration for the "lame duck" mode, so that we don't give up too early but also don't wait for too long.
+func (c *Cluster) lameDuckTimeout() time.Duration {
 	// If no value for the duration of the "lame duck" mode has been specified, we use the default.
 	var ldDuration int64
 	if c.cluster.Spec.LameDuckDurationSeconds == nil {
-		ldDuration = int64(defaultNatsLameDuckDuration.Seconds())
+		ldDuration = constants.DefaultLameDuckDurationSeconds
 	} else {
 		ldDuration = *c.cluster.Spec.LameDuckDurationSeconds
 	}
-	// Wait for at most twice the specified (or default) duration for the "lame duck" mode, so that we don't fail too early but also don't wait for too long.
-	ctx, fn := context.WithTimeout(context.Background(), time.Duration(2*ldDuration)*time.Second)
-	defer fn()
-	return kubernetesutil.WaitUntilPodCondition(ctx, c.config.KubeClient.CoreV1(), pod, func(event watch.Event) (bool, error) {
-		pod := event.Object.(*v1.Pod)
-		switch event.Type {
-		case watch.Deleted:
-			fallthrough
-		case watch.Error:
-			return false, fmt.Errorf("got event of unexpected type %q for pod %q while waiting termination", event.Type, kubernetesutil.ResourceKey(pod))
-		default:
-			for _, containerStatus := range pod.Status.ContainerStatuses {
-				if containerStatus.Name == constants.NatsContainerName {
-					if containerStatus.State.Terminated != nil {
-						return true, nil
-					}
-				}
-			}
-			return false, nil
+	return time.Duration(2*ldDuration) * time.Second
+}
+
+// isNatsContainerTerminated returns whether the "nats" container of the specified pod has reached the "Terminated" state.
+func isNatsContainerTerminated(pod *v1.Pod) bool {
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		if containerStatus.Name == constants.NatsContainerName {
+			return containerStatus.State.Terminated != nil
 		}
-	})
+	}
+	return false
+}
+
+// notReadySince returns the time since which the specified pod has not been ready.
+func notReadySince(pod *v1.Pod) time.Time {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodReady {
+			return condition.LastTransitionTime.Time
+		}
+	}
+	return pod.CreationTimestamp.Time
 }
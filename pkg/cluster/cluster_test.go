@@ -0,0 +1,284 @@
+// Copyright 2019 The nats-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	policyv1beta1listers "k8s.io/client-go/listers/policy/v1beta1"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/nats-io/nats-operator/pkg/apis/nats/v1alpha2"
+	operatorfake "github.com/nats-io/nats-operator/pkg/client/clientset/versioned/fake"
+	kubernetesutil "github.com/nats-io/nats-operator/pkg/util/kubernetes"
+)
+
+const (
+	// testNamespace is the namespace in which the test NATS cluster lives.
+	testNamespace = "nats"
+	// testClusterName is the name of the test NATS cluster.
+	testClusterName = "example-nats"
+)
+
+// newTestNatsCluster returns a NatsCluster resource with the specified spec.
+func newTestNatsCluster(spec v1alpha2.ClusterSpec) *v1alpha2.NatsCluster {
+	return &v1alpha2.NatsCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       testClusterName,
+			Namespace:  testNamespace,
+			UID:        types.UID("8d6a1f2b"),
+			Generation: 1,
+		},
+		Spec: spec,
+	}
+}
+
+// newTestCluster returns a reconciler for the specified NatsCluster resource, backed by a fake clientset containing the specified objects.
+// The listers are populated with the same objects, but (unlike real listers) they don't observe changes made through the clientset.
+func newTestCluster(t *testing.T, cl *v1alpha2.NatsCluster, objects ...runtime.Object) (*Cluster, *fake.Clientset) {
+	kubeClient := fake.NewSimpleClientset(objects...)
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, obj := range objects {
+		if err := indexer.Add(obj); err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+	}
+	c := New(Config{
+		KubeCli:                   kubeClient.CoreV1(),
+		KubeClient:                kubeClient,
+		PodLister:                 corev1listers.NewPodLister(indexer),
+		SecretLister:              corev1listers.NewSecretLister(indexer),
+		ServiceLister:             corev1listers.NewServiceLister(indexer),
+		PodDisruptionBudgetLister: policyv1beta1listers.NewPodDisruptionBudgetLister(indexer),
+		EventRecorder:             record.NewFakeRecorder(100),
+	}, cl)
+	return c, kubeClient
+}
+
+// newTestPod returns a running and ready pod with the specified name and version belonging to the specified NatsCluster resource.
+func newTestPod(t *testing.T, cl *v1alpha2.NatsCluster, name, version string) *v1.Pod {
+	spec := cl.Spec
+	spec.Version = version
+	pod, err := kubernetesutil.NewNatsPodSpec(cl.Namespace, name, cl.Name, spec, cl.AsOwner())
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	pod.Namespace = cl.Namespace
+	pod.UID = types.UID(name)
+	pod.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+	pod.Status = v1.PodStatus{
+		Phase: v1.PodRunning,
+		PodIP: "10.0.0.1",
+		Conditions: []v1.PodCondition{
+			{
+				Type:               v1.PodReady,
+				Status:             v1.ConditionTrue,
+				LastTransitionTime: pod.CreationTimestamp,
+			},
+		},
+	}
+	return pod
+}
+
+// listPodNames returns the sorted names of the pods that exist in the specified clientset.
+func listPodNames(t *testing.T, kubeClient *fake.Clientset) []string {
+	pods, err := kubeClient.CoreV1().Pods(testNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	names := make([]string, 0, len(pods.Items))
+	for _, pod := range pods.Items {
+		names = append(names, pod.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestCheckPodDisruptionBudget(t *testing.T) {
+	two, four := int32(2), int32(4)
+	cl := newTestNatsCluster(v1alpha2.ClusterSpec{Size: 3})
+	owned := func(minAvailable int32) *policyv1beta1.PodDisruptionBudget {
+		return kubernetesutil.NewPodDisruptionBudget(testClusterName, testNamespace, minAvailable, cl.AsOwner())
+	}
+	notOwned := func(minAvailable int32) *policyv1beta1.PodDisruptionBudget {
+		return kubernetesutil.NewPodDisruptionBudget(testClusterName, testNamespace, minAvailable, metav1.OwnerReference{Name: "other", UID: "other"})
+	}
+
+	tests := []struct {
+		name string
+		// size is the size of the cluster.
+		size int
+		// policy is the disruption budget policy of the cluster.
+		policy *v1alpha2.DisruptionBudgetPolicy
+		// current is the PodDisruptionBudget that currently exists, if any.
+		current *policyv1beta1.PodDisruptionBudget
+		// stuck indicates whether deleting the PodDisruptionBudget is accepted without it being removed (e.g. because of a finalizer).
+		stuck bool
+		// expected is the minimum number of available members required by the PodDisruptionBudget expected afterwards, if any.
+		expected *int32
+		// err indicates whether an error is expected.
+		err bool
+	}{
+		{
+			name: "disabled",
+			size: 3,
+		},
+		{
+			name:     "create",
+			size:     3,
+			policy:   &v1alpha2.DisruptionBudgetPolicy{},
+			expected: &two,
+		},
+		{
+			name:     "create with min available",
+			size:     3,
+			policy:   &v1alpha2.DisruptionBudgetPolicy{MinAvailable: &four},
+			expected: &four,
+		},
+		{
+			name:     "up-to-date",
+			size:     3,
+			policy:   &v1alpha2.DisruptionBudgetPolicy{},
+			current:  owned(2),
+			expected: &two,
+		},
+		{
+			name:     "resize",
+			size:     5,
+			policy:   &v1alpha2.DisruptionBudgetPolicy{},
+			current:  owned(2),
+			expected: &four,
+		},
+		{
+			name:     "resize while deletion is pending",
+			size:     5,
+			policy:   &v1alpha2.DisruptionBudgetPolicy{},
+			current:  owned(2),
+			stuck:    true,
+			expected: &two,
+			err:      true,
+		},
+		{
+			name:    "disable",
+			size:    3,
+			current: owned(2),
+		},
+		{
+			name:     "not owned",
+			size:     5,
+			policy:   &v1alpha2.DisruptionBudgetPolicy{},
+			current:  notOwned(2),
+			expected: &two,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			natsCluster := cl.DeepCopy()
+			natsCluster.Spec.Size = tt.size
+			natsCluster.Spec.DisruptionBudget = tt.policy
+			objects := []runtime.Object{}
+			if tt.current != nil {
+				objects = append(objects, tt.current)
+			}
+			c, kubeClient := newTestCluster(t, natsCluster, objects...)
+			if tt.stuck {
+				kubeClient.PrependReactor("delete", "poddisruptionbudgets", func(k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, nil
+				})
+			}
+
+			err := c.checkPodDisruptionBudget()
+			if tt.err && err == nil {
+				t.Errorf("Expected an error")
+			}
+			if !tt.err && err != nil {
+				t.Fatalf("Error: %s", err)
+			}
+
+			pdbs, err := kubeClient.PolicyV1beta1().PodDisruptionBudgets(testNamespace).List(metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("Error: %s", err)
+			}
+			if tt.expected == nil {
+				if len(pdbs.Items) > 0 {
+					t.Errorf("Expected no pod disruption budget, got: %+v", pdbs.Items)
+				}
+				return
+			}
+			if len(pdbs.Items) != 1 {
+				t.Fatalf("Expected a single pod disruption budget, got: %+v", pdbs.Items)
+			}
+			pdb := pdbs.Items[0]
+			if pdb.Spec.MinAvailable == nil || pdb.Spec.MinAvailable.IntValue() != int(*tt.expected) {
+				t.Errorf("Expected minAvailable to be %d, got: %v", *tt.expected, pdb.Spec.MinAvailable)
+			}
+			expectedSelector := &metav1.LabelSelector{MatchLabels: kubernetesutil.LabelsForCluster(testClusterName)}
+			if !reflect.DeepEqual(pdb.Spec.Selector, expectedSelector) {
+				t.Errorf("Expected %+v, got: %+v", expectedSelector, pdb.Spec.Selector)
+			}
+		})
+	}
+}
+
+func TestPatchClusterLeavesSpecUntouched(t *testing.T) {
+	cl := newTestNatsCluster(v1alpha2.ClusterSpec{Size: 3})
+	// The resource is created through the typed client rather than seeded, as the generated fake client uses "nats" rather than "nats.io" as the group of the resource.
+	operatorClient := operatorfake.NewSimpleClientset()
+	if _, err := operatorClient.NatsV1alpha2().NatsClusters(testNamespace).Create(cl); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	c, _ := newTestCluster(t, cl)
+	c.config.OperatorCli = operatorClient.NatsV1alpha2()
+
+	// Defaults are applied in memory by every reconcile iteration, and must not be persisted along with the finalizer.
+	c.cluster.Spec.SetDefaults()
+	c.cluster.AddFinalizer(v1alpha2.ClusterFinalizer)
+	if err := c.patchCluster(); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	res, err := operatorClient.NatsV1alpha2().NatsClusters(testNamespace).Get(testClusterName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if !res.HasFinalizer(v1alpha2.ClusterFinalizer) {
+		t.Errorf("Expected finalizer %q, got: %+v", v1alpha2.ClusterFinalizer, res.Finalizers)
+	}
+	expected := v1alpha2.ClusterSpec{Size: 3}
+	if !reflect.DeepEqual(res.Spec, expected) {
+		t.Errorf("Expected %+v, got: %+v", expected, res.Spec)
+	}
+
+	// Patching again without any change to the metadata must not hit the API.
+	operatorClient.ClearActions()
+	if err := c.patchCluster(); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if actions := operatorClient.Actions(); len(actions) > 0 {
+		t.Errorf("Expected no actions, got: %+v", actions)
+	}
+}
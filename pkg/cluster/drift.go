@@ -0,0 +1,69 @@
+// Copyright 2019 The nats-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	kubernetesutil "github.com/nats-io/nats-operator/pkg/util/kubernetes"
+)
+
+// applyService brings the specified service in line with the desired one, and returns whether it has been patched.
+func (c *Cluster) applyService(current, desired *v1.Service) (bool, error) {
+	return c.apply("service", current, desired, v1.Service{}, func(patch []byte) error {
+		_, err := c.config.KubeCli.Services(current.Namespace).Patch(current.Name, types.StrategicMergePatchType, patch)
+		return err
+	})
+}
+
+// applySecret brings the specified secret in line with the desired one, and returns whether it has been patched.
+func (c *Cluster) applySecret(current, desired *v1.Secret) (bool, error) {
+	return c.apply("secret", current, desired, v1.Secret{}, func(patch []byte) error {
+		_, err := c.config.KubeCli.Secrets(current.Namespace).Patch(current.Name, types.StrategicMergePatchType, patch)
+		return err
+	})
+}
+
+// apply brings the specified object owned by the cluster in line with the desired one using a three-way patch, so that fields not managed by nats-operator are preserved.
+// In case any managed fields have been changed by third parties since the object was last applied, the correction is logged and reported as an event.
+// Objects which are not owned by the cluster are left untouched.
+// It returns whether the object has been patched.
+func (c *Cluster) apply(kind string, current, desired metav1.Object, datastruct interface{}, patchFn func([]byte) error) (bool, error) {
+	if !metav1.IsControlledBy(current, c.cluster) {
+		c.logger.Warnf("ignoring %s %q not owned by the cluster", kind, kubernetesutil.ResourceKey(current))
+		return false, nil
+	}
+	patch, drift, err := kubernetesutil.CreateThreeWayPatch(current, desired, datastruct)
+	if err != nil {
+		return false, fmt.Errorf("failed to create patch for %s %q: %v", kind, kubernetesutil.ResourceKey(current), err)
+	}
+	if kubernetesutil.IsEmptyPatch(patch) {
+		return false, nil
+	}
+	if err := patchFn(patch); err != nil {
+		return false, fmt.Errorf("failed to patch %s %q: %v", kind, kubernetesutil.ResourceKey(current), err)
+	}
+	if len(drift) > 0 {
+		msg := fmt.Sprintf("reverted changes to %s of %s %q made outside of nats-operator", strings.Join(drift, ", "), kind, current.GetName())
+		c.logger.Warn(msg)
+		c.recordWarningEvent(eventReasonDriftCorrected, "%s", msg)
+	}
+	return true, nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2019 The nats-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"k8s.io/api/core/v1"
+)
+
+const (
+	// eventReasonPodCreated is used when a member of the cluster is created.
+	eventReasonPodCreated = "PodCreated"
+	// eventReasonPodDeleted is used when a member of the cluster is deleted.
+	eventReasonPodDeleted = "PodDeleted"
+	// eventReasonLameDuckMode is used when a member of the cluster is placed in "lame duck" mode before being removed.
+	eventReasonLameDuckMode = "LameDuckMode"
+	// eventReasonScalingUp is used when members are being added to the cluster.
+	eventReasonScalingUp = "ScalingUp"
+	// eventReasonScalingDown is used when members are being removed from the cluster.
+	eventReasonScalingDown = "ScalingDown"
+	// eventReasonRollingUpdate is used when a member of the cluster is replaced because its spec has drifted.
+	eventReasonRollingUpdate = "RollingUpdate"
+	// eventReasonUpgradeStarted is used when the cluster starts being upgraded to a different version.
+	eventReasonUpgradeStarted = "UpgradeStarted"
+	// eventReasonUpgradeHeld is used when the upgrade of the cluster is held until the canary members have soaked and the upgrade has been approved.
+	eventReasonUpgradeHeld = "UpgradeHeld"
+	// eventReasonUpgradeCompleted is used when all the members of the cluster have been upgraded.
+	eventReasonUpgradeCompleted = "UpgradeCompleted"
+	// eventReasonUpgradedPodFailed is used when an upgraded member of the cluster fails.
+	eventReasonUpgradedPodFailed = "UpgradedPodFailed"
+	// eventReasonUpgradeRolledBack is used when an upgrade has failed and is being rolled back.
+	eventReasonUpgradeRolledBack = "UpgradeRolledBack"
+	// eventReasonConfigUpdated is used when the configuration of the cluster is updated, which may cause its members to reload it.
+	eventReasonConfigUpdated = "ConfigUpdated"
+	// eventReasonAuthUpdated is used when the authentication data of the cluster changes.
+	eventReasonAuthUpdated = "AuthUpdated"
+	// eventReasonDriftCorrected is used when changes made by third parties to objects owned by the cluster are reverted.
+	eventReasonDriftCorrected = "DriftCorrected"
+	// eventReasonMeshDegraded is used when a member is replaced because the route mesh has been unhealthy for too long.
+	eventReasonMeshDegraded = "MeshDegraded"
+	// eventReasonTeardown is used when the members of the cluster start being removed because the NatsCluster resource is being deleted.
+	eventReasonTeardown = "Teardown"
+)
+
+// recordEvent records an event of the specified type concerning the current NatsCluster resource, if an event recorder has been configured.
+func (c *Cluster) recordEvent(eventType, reason, messageFmt string, args ...interface{}) {
+	if c.config.EventRecorder == nil {
+		return
+	}
+	c.config.EventRecorder.Eventf(c.cluster, eventType, reason, messageFmt, args...)
+}
+
+// recordNormalEvent records an event of type "Normal" concerning the current NatsCluster resource.
+func (c *Cluster) recordNormalEvent(reason, messageFmt string, args ...interface{}) {
+	c.recordEvent(v1.EventTypeNormal, reason, messageFmt, args...)
+}
+
+// recordWarningEvent records an event of type "Warning" concerning the current NatsCluster resource.
+func (c *Cluster) recordWarningEvent(reason, messageFmt string, args ...interface{}) {
+	c.recordEvent(v1.EventTypeWarning, reason, messageFmt, args...)
+}
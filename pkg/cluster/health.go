@@ -0,0 +1,308 @@
+// Copyright 2019 The nats-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/api/core/v1"
+
+	"github.com/nats-io/nats-operator/pkg/apis/nats/v1alpha2"
+	kubernetesutil "github.com/nats-io/nats-operator/pkg/util/kubernetes"
+)
+
+// meshMember describes a member of the cluster as seen through its monitoring endpoint.
+type meshMember struct {
+	// pod is the pod running the member.
+	pod *v1.Pod
+	// err is the error returned when querying the monitoring endpoint of the member, if any.
+	err error
+	// serverID is the ID of the NATS server run by the member.
+	serverID string
+	// routes is the list of routes reported by the member.
+	routes []routeInfo
+}
+
+// meshReport describes the problems found in the route mesh of the cluster.
+type meshReport struct {
+	// problems is the list of human-readable descriptions of the problems found.
+	problems []string
+	// offenders is the list of pods causing the problems, most disruptive first.
+	offenders []*v1.Pod
+}
+
+// addProblem records the specified problem, caused by the specified pods.
+func (r *meshReport) addProblem(problem string, offenders ...*v1.Pod) {
+	r.problems = append(r.problems, problem)
+	for _, pod := range offenders {
+		if !containsPod(r.offenders, pod) {
+			r.offenders = append(r.offenders, pod)
+		}
+	}
+}
+
+// merge appends the problems and offenders of the specified report to the current one.
+func (r *meshReport) merge(other *meshReport) {
+	r.problems = append(r.problems, other.problems...)
+	for _, pod := range other.offenders {
+		if !containsPod(r.offenders, pod) {
+			r.offenders = append(r.offenders, pod)
+		}
+	}
+}
+
+// checkMeshHealth checks the route mesh formed by the members of the cluster, recording the result in the status of the NatsCluster resource, and returns whether it is healthy.
+// If ".spec.meshHealth.restartUnhealthyMembers" is set and the mesh has been unhealthy for long enough, the member causing the most disruption is replaced.
+func (c *Cluster) checkMeshHealth() (bool, error) {
+	policy := c.cluster.Spec.MeshHealth
+	period := time.Duration(policy.PeriodSeconds) * time.Second
+
+	// Make sure the mesh is checked again after the configured period, regardless of any events concerning the cluster.
+	c.requeue(period)
+
+	pods, _, _, err := c.pollPods()
+	if err != nil {
+		return false, err
+	}
+	report := inspectMesh(c.queryMesh(pods))
+	c.cluster.Status.SetMeshHealth(report.problems)
+	if len(report.problems) == 0 {
+		return true, nil
+	}
+	c.logger.Warnf("route mesh is unhealthy: %s", strings.Join(report.problems, "; "))
+
+	if !policy.RestartUnhealthyMembers || len(report.offenders) == 0 {
+		return false, nil
+	}
+	restartAfter := time.Duration(policy.RestartAfterSeconds) * time.Second
+	if remaining := restartAfter - time.Since(c.cluster.Status.MeshHealth.UnhealthySince.Time); remaining > 0 {
+		c.requeue(remaining)
+		return false, nil
+	}
+
+	// Replace a single member at a time, and check the mesh again once it has been replaced.
+	pod := report.offenders[0]
+	c.logger.Infof("replacing pod %q as the route mesh has been unhealthy for more than %v", kubernetesutil.ResourceKey(pod), restartAfter)
+	c.recordWarningEvent(eventReasonMeshDegraded, "replacing pod %s as the route mesh has been unhealthy for more than %v", pod.Name, restartAfter)
+	return false, c.removePods([]*v1.Pod{pod}, v1alpha2.ClusterOperationReplacePod)
+}
+
+// queryMesh queries the monitoring endpoint of the specified pods for their server IDs and routes.
+// Pods are queried concurrently, so that unresponsive pods don't slow the check down.
+func (c *Cluster) queryMesh(pods []*v1.Pod) []*meshMember {
+	members := make([]*meshMember, len(pods))
+	var wg sync.WaitGroup
+	wg.Add(len(pods))
+	for i, pod := range pods {
+		go func(i int, pod *v1.Pod) {
+			defer wg.Done()
+			members[i] = c.getMeshMember(pod)
+		}(i, pod)
+	}
+	wg.Wait()
+	return members
+}
+
+// inspectMesh builds the route graph of the cluster from the specified members.
+// It reports members which are unresponsive or isolated, partitions of the cluster, missing routes, and routes pointing at unknown servers or at addresses other than the pod's.
+func inspectMesh(members []*meshMember) *meshReport {
+	report := &meshReport{}
+	pods := make([]*v1.Pod, 0, len(members))
+	for _, m := range members {
+		pods = append(pods, m.pod)
+	}
+
+	// Index the responsive members by server ID.
+	// If none of the members responds, the problem most likely lies with our ability to reach them rather than with the members themselves, so none of them is reported as an offender.
+	byID := make(map[string]*meshMember, len(members))
+	for _, m := range members {
+		if m.err == nil {
+			byID[m.serverID] = m
+		}
+	}
+	if len(byID) == 0 {
+		if len(members) > 0 {
+			report.addProblem(fmt.Sprintf("could not query the monitoring endpoint of any member: %v", members[0].err))
+		}
+		return report
+	}
+	// Problems with individual members or routes are reported after the ones affecting the connectivity of the cluster, as replacing isolated or partitioned members is the most effective remedy.
+	secondary := &meshReport{}
+	for _, m := range members {
+		if m.err != nil {
+			secondary.addProblem(fmt.Sprintf("member %s is not responding to monitoring requests: %v", m.pod.Name, m.err), m.pod)
+		}
+	}
+
+	// Build the (undirected) route graph, taking note of routes to unknown servers or to unexpected addresses.
+	peers := make(map[string]map[string]bool, len(byID))
+	for _, m := range byID {
+		peers[m.pod.Name] = make(map[string]bool)
+	}
+	for _, m := range members {
+		if m.err != nil {
+			continue
+		}
+		for _, route := range m.routes {
+			peer, ok := byID[route.RemoteID]
+			if !ok {
+				secondary.addProblem(fmt.Sprintf("member %s has a route to unknown server %s at %s", m.pod.Name, route.RemoteID, route.IP), m.pod)
+				continue
+			}
+			if route.IP != "" && peer.pod.Status.PodIP != "" && route.IP != peer.pod.Status.PodIP {
+				secondary.addProblem(fmt.Sprintf("member %s is reached by %s at %s instead of %s", peer.pod.Name, m.pod.Name, route.IP, peer.pod.Status.PodIP), peer.pod)
+			}
+			peers[m.pod.Name][peer.pod.Name] = true
+			peers[peer.pod.Name][m.pod.Name] = true
+		}
+	}
+	if len(peers) < 2 {
+		report.merge(secondary)
+		return report
+	}
+
+	// Find the connected components of the route graph, largest first.
+	components := connectedComponents(peers)
+	isolated := make([]string, 0)
+	for _, component := range components {
+		if len(component) == 1 {
+			isolated = append(isolated, component[0])
+		}
+	}
+	for _, name := range isolated {
+		report.addProblem(fmt.Sprintf("member %s is isolated from the rest of the cluster", name), podByName(pods, name))
+	}
+	if len(components)-len(isolated) > 1 {
+		groups := make([]string, 0, len(components))
+		for _, component := range components {
+			if len(component) > 1 {
+				groups = append(groups, fmt.Sprintf("[%s]", strings.Join(component, " ")))
+			}
+		}
+		offenders := make([]*v1.Pod, 0)
+		for _, component := range components[1:] {
+			for _, name := range component {
+				offenders = append(offenders, podByName(pods, name))
+			}
+		}
+		report.addProblem(fmt.Sprintf("cluster is partitioned into %d groups: %s", len(groups), strings.Join(groups, ", ")), offenders...)
+	}
+	report.merge(secondary)
+	if len(components) > 1 {
+		return report
+	}
+
+	// All the members are connected, but routes don't forward messages further than a single hop, so each member must have a route to every other member.
+	names := make([]string, 0, len(peers))
+	for name := range peers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	missing := make(map[string][]string, len(names))
+	for _, name := range names {
+		for _, other := range names {
+			if other != name && !peers[name][other] {
+				missing[name] = append(missing[name], other)
+			}
+		}
+	}
+	sort.SliceStable(names, func(i, j int) bool {
+		return len(missing[names[i]]) > len(missing[names[j]])
+	})
+	for _, name := range names {
+		if len(missing[name]) > 0 {
+			report.addProblem(fmt.Sprintf("member %s has no route to %s", name, strings.Join(missing[name], ", ")), podByName(pods, name))
+		}
+	}
+	return report
+}
+
+// getMeshMember queries the monitoring endpoint of the specified pod for its server ID and routes.
+func (c *Cluster) getMeshMember(pod *v1.Pod) *meshMember {
+	m := &meshMember{pod: pod}
+	vz := &varz{}
+	if m.err = c.getMonitoringEndpoint(pod, "/varz", vz); m.err != nil {
+		return m
+	}
+	rz := &routez{}
+	if m.err = c.getMonitoringEndpoint(pod, "/routez", rz); m.err != nil {
+		return m
+	}
+	m.serverID = vz.ServerID
+	m.routes = rz.Routes
+	return m
+}
+
+// connectedComponents returns the connected components of the specified graph, largest first.
+// The names within each component are sorted, as are components of the same size.
+func connectedComponents(graph map[string]map[string]bool) [][]string {
+	names := make([]string, 0, len(graph))
+	for name := range graph {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	visited := make(map[string]bool, len(names))
+	res := make([][]string, 0)
+	for _, name := range names {
+		if visited[name] {
+			continue
+		}
+		component := make([]string, 0)
+		queue := []string{name}
+		visited[name] = true
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			component = append(component, current)
+			for peer := range graph[current] {
+				if !visited[peer] {
+					visited[peer] = true
+					queue = append(queue, peer)
+				}
+			}
+		}
+		sort.Strings(component)
+		res = append(res, component)
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return len(res[i]) > len(res[j])
+	})
+	return res
+}
+
+// podByName returns the pod with the specified name among the specified pods, or nil if there is none.
+func podByName(pods []*v1.Pod, name string) *v1.Pod {
+	for _, pod := range pods {
+		if pod.Name == name {
+			return pod
+		}
+	}
+	return nil
+}
+
+// containsPod returns whether the specified pod is among the specified pods.
+func containsPod(pods []*v1.Pod, pod *v1.Pod) bool {
+	for _, p := range pods {
+		if p == pod {
+			return true
+		}
+	}
+	return false
+}
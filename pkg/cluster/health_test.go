@@ -0,0 +1,314 @@
+// Copyright 2019 The nats-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	kubernetesutil "github.com/nats-io/nats-operator/pkg/util/kubernetes"
+)
+
+// newMeshPods returns n pods named "example-nats-<idx>", each with a distinct IP.
+func newMeshPods(n int) []*v1.Pod {
+	pods := make([]*v1.Pod, 0, n)
+	for i := 1; i <= n; i++ {
+		pods = append(pods, &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("example-nats-%d", i)},
+			Status:     v1.PodStatus{PodIP: fmt.Sprintf("10.0.0.%d", i)},
+		})
+	}
+	return pods
+}
+
+// serverID returns the ID of the NATS server run by the specified pod.
+func serverID(pod *v1.Pod) string {
+	return "ID-" + pod.Name
+}
+
+// newMeshMember returns a responsive member running in the specified pod, with a route to each of the specified peers.
+func newMeshMember(pod *v1.Pod, peers ...*v1.Pod) *meshMember {
+	m := &meshMember{pod: pod, serverID: serverID(pod)}
+	for _, peer := range peers {
+		m.routes = append(m.routes, routeInfo{RemoteID: serverID(peer), IP: peer.Status.PodIP})
+	}
+	return m
+}
+
+// newUnresponsiveMeshMember returns a member running in the specified pod whose monitoring endpoint cannot be queried.
+func newUnresponsiveMeshMember(pod *v1.Pod) *meshMember {
+	return &meshMember{pod: pod, err: errors.New("connection refused")}
+}
+
+func TestInspectMesh(t *testing.T) {
+	p := newMeshPods(4)
+
+	tests := []struct {
+		name      string
+		members   []*meshMember
+		problems  []string
+		offenders []*v1.Pod
+	}{
+		{
+			name: "no members",
+		},
+		{
+			name: "full mesh",
+			members: []*meshMember{
+				newMeshMember(p[0], p[1], p[2]),
+				newMeshMember(p[1], p[0], p[2]),
+				newMeshMember(p[2], p[0], p[1]),
+			},
+		},
+		{
+			name: "routes reported by one side only",
+			members: []*meshMember{
+				newMeshMember(p[0], p[1], p[2]),
+				newMeshMember(p[1], p[2]),
+				newMeshMember(p[2]),
+			},
+		},
+		{
+			name: "single member",
+			members: []*meshMember{
+				newMeshMember(p[0]),
+			},
+		},
+		{
+			name: "all members unresponsive",
+			members: []*meshMember{
+				newUnresponsiveMeshMember(p[0]),
+				newUnresponsiveMeshMember(p[1]),
+				newUnresponsiveMeshMember(p[2]),
+			},
+			problems: []string{
+				"could not query the monitoring endpoint of any member: connection refused",
+			},
+		},
+		{
+			name: "unresponsive member",
+			members: []*meshMember{
+				newMeshMember(p[0], p[1]),
+				newMeshMember(p[1], p[0]),
+				newUnresponsiveMeshMember(p[2]),
+			},
+			problems: []string{
+				"member example-nats-3 is not responding to monitoring requests: connection refused",
+			},
+			offenders: []*v1.Pod{p[2]},
+		},
+		{
+			name: "single responsive member",
+			members: []*meshMember{
+				newMeshMember(p[0]),
+				newUnresponsiveMeshMember(p[1]),
+			},
+			problems: []string{
+				"member example-nats-2 is not responding to monitoring requests: connection refused",
+			},
+			offenders: []*v1.Pod{p[1]},
+		},
+		{
+			name: "isolated member",
+			members: []*meshMember{
+				newMeshMember(p[0], p[1]),
+				newMeshMember(p[1], p[0]),
+				newMeshMember(p[2]),
+			},
+			problems: []string{
+				"member example-nats-3 is isolated from the rest of the cluster",
+			},
+			offenders: []*v1.Pod{p[2]},
+		},
+		{
+			name: "isolated members",
+			members: []*meshMember{
+				newMeshMember(p[0]),
+				newMeshMember(p[1]),
+				newMeshMember(p[2]),
+			},
+			problems: []string{
+				"member example-nats-1 is isolated from the rest of the cluster",
+				"member example-nats-2 is isolated from the rest of the cluster",
+				"member example-nats-3 is isolated from the rest of the cluster",
+			},
+			offenders: []*v1.Pod{p[0], p[1], p[2]},
+		},
+		{
+			name: "partition",
+			members: []*meshMember{
+				newMeshMember(p[0], p[1]),
+				newMeshMember(p[1], p[0]),
+				newMeshMember(p[2], p[3]),
+				newMeshMember(p[3], p[2]),
+			},
+			problems: []string{
+				"cluster is partitioned into 2 groups: [example-nats-1 example-nats-2], [example-nats-3 example-nats-4]",
+			},
+			offenders: []*v1.Pod{p[2], p[3]},
+		},
+		{
+			name: "partition with an unresponsive member",
+			members: []*meshMember{
+				newMeshMember(p[0], p[1]),
+				newMeshMember(p[1], p[0]),
+				newMeshMember(p[2]),
+				newUnresponsiveMeshMember(p[3]),
+			},
+			problems: []string{
+				"member example-nats-3 is isolated from the rest of the cluster",
+				"member example-nats-4 is not responding to monitoring requests: connection refused",
+			},
+			offenders: []*v1.Pod{p[2], p[3]},
+		},
+		{
+			name: "missing route",
+			members: []*meshMember{
+				newMeshMember(p[0], p[1]),
+				newMeshMember(p[1], p[0], p[2]),
+				newMeshMember(p[2], p[1]),
+			},
+			problems: []string{
+				"member example-nats-1 has no route to example-nats-3",
+				"member example-nats-3 has no route to example-nats-1",
+			},
+			offenders: []*v1.Pod{p[0], p[2]},
+		},
+		{
+			name: "missing routes",
+			members: []*meshMember{
+				newMeshMember(p[0], p[1]),
+				newMeshMember(p[1], p[0], p[2], p[3]),
+				newMeshMember(p[2], p[1], p[3]),
+				newMeshMember(p[3], p[1], p[2]),
+			},
+			problems: []string{
+				"member example-nats-1 has no route to example-nats-3, example-nats-4",
+				"member example-nats-3 has no route to example-nats-1",
+				"member example-nats-4 has no route to example-nats-1",
+			},
+			offenders: []*v1.Pod{p[0], p[2], p[3]},
+		},
+		{
+			name: "route to the wrong ip",
+			members: []*meshMember{
+				{pod: p[0], serverID: serverID(p[0]), routes: []routeInfo{{RemoteID: serverID(p[1]), IP: "10.0.0.99"}}},
+				newMeshMember(p[1], p[0]),
+			},
+			problems: []string{
+				"member example-nats-2 is reached by example-nats-1 at 10.0.0.99 instead of 10.0.0.2",
+			},
+			offenders: []*v1.Pod{p[1]},
+		},
+		{
+			name: "route to an unknown server",
+			members: []*meshMember{
+				{pod: p[0], serverID: serverID(p[0]), routes: []routeInfo{{RemoteID: serverID(p[1]), IP: p[1].Status.PodIP}, {RemoteID: "ID-unknown", IP: "10.0.0.99"}}},
+				newMeshMember(p[1], p[0]),
+			},
+			problems: []string{
+				"member example-nats-1 has a route to unknown server ID-unknown at 10.0.0.99",
+			},
+			offenders: []*v1.Pod{p[0]},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := inspectMesh(tt.members)
+			if !reflect.DeepEqual(report.problems, tt.problems) {
+				t.Errorf("Expected problems %q, got: %q", tt.problems, report.problems)
+			}
+			if !reflect.DeepEqual(report.offenders, tt.offenders) {
+				t.Errorf("Expected offenders %v, got: %v", kubernetesutil.GetPodNames(tt.offenders), kubernetesutil.GetPodNames(report.offenders))
+			}
+		})
+	}
+}
+
+func TestConnectedComponents(t *testing.T) {
+	tests := []struct {
+		name     string
+		graph    map[string]map[string]bool
+		expected [][]string
+	}{
+		{
+			name:     "empty",
+			graph:    map[string]map[string]bool{},
+			expected: [][]string{},
+		},
+		{
+			name: "single node",
+			graph: map[string]map[string]bool{
+				"a": {},
+			},
+			expected: [][]string{{"a"}},
+		},
+		{
+			name: "connected",
+			graph: map[string]map[string]bool{
+				"c": {"b": true},
+				"b": {"a": true, "c": true},
+				"a": {"b": true},
+			},
+			expected: [][]string{{"a", "b", "c"}},
+		},
+		{
+			name: "isolated nodes",
+			graph: map[string]map[string]bool{
+				"c": {},
+				"a": {},
+				"b": {},
+			},
+			expected: [][]string{{"a"}, {"b"}, {"c"}},
+		},
+		{
+			name: "largest component first",
+			graph: map[string]map[string]bool{
+				"a": {},
+				"b": {"d": true},
+				"c": {"d": true, "e": true},
+				"d": {"b": true, "c": true},
+				"e": {"c": true},
+				"f": {"g": true},
+				"g": {"f": true},
+			},
+			expected: [][]string{{"b", "c", "d", "e"}, {"f", "g"}, {"a"}},
+		},
+		{
+			name: "components of the same size sorted by name",
+			graph: map[string]map[string]bool{
+				"d": {"c": true},
+				"c": {"d": true},
+				"b": {"a": true},
+				"a": {"b": true},
+			},
+			expected: [][]string{{"a", "b"}, {"c", "d"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if res := connectedComponents(tt.graph); !reflect.DeepEqual(res, tt.expected) {
+				t.Errorf("Expected %v, got: %v", tt.expected, res)
+			}
+		})
+	}
+}
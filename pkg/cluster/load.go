@@ -0,0 +1,113 @@
+// Copyright 2019 The nats-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"sort"
+	"sync"
+
+	"k8s.io/api/core/v1"
+
+	kubernetesutil "github.com/nats-io/nats-operator/pkg/util/kubernetes"
+)
+
+// connz encapsulates a response from the "/connz" endpoint of the NATS monitoring API.
+type connz struct {
+	Total int `json:"total"`
+}
+
+// varz encapsulates a response from the "/varz" endpoint of the NATS monitoring API.
+type varz struct {
+	ServerID      string `json:"server_id"`
+	Subscriptions int    `json:"subscriptions"`
+}
+
+// podLoad describes how disruptive removing a member of the cluster would be.
+type podLoad struct {
+	// pod is the pod running the member.
+	pod *v1.Pod
+	// priority is the scale-down priority of the pod, as set by the user.
+	priority int
+	// connections is the number of clients connected to the member.
+	connections int
+	// subscriptions is the number of subscriptions known to the member.
+	subscriptions int
+}
+
+// getPodLoad queries the monitoring endpoint of the specified pod in order to find out how loaded it is.
+// Pods whose monitoring endpoint cannot be queried are reported as having no load, as they are most likely not serving clients anyway.
+func (c *Cluster) getPodLoad(pod *v1.Pod) podLoad {
+	res := podLoad{pod: pod}
+	p, err := kubernetesutil.GetScaleDownPriority(pod)
+	if err != nil {
+		c.logger.Warnf("ignoring scale-down priority of pod %q: %v", kubernetesutil.ResourceKey(pod), err)
+	}
+	res.priority = p
+	cz := &connz{}
+	if err := c.getMonitoringEndpoint(pod, "/connz?limit=1", cz); err != nil {
+		c.logger.Debugf("failed to get connections for pod %q: %v", kubernetesutil.ResourceKey(pod), err)
+		return res
+	}
+	vz := &varz{}
+	if err := c.getMonitoringEndpoint(pod, "/varz", vz); err != nil {
+		c.logger.Debugf("failed to get subscriptions for pod %q: %v", kubernetesutil.ResourceKey(pod), err)
+		return res
+	}
+	res.connections = cz.Total
+	res.subscriptions = vz.Subscriptions
+	return res
+}
+
+// selectPodsForRemoval returns the n pods whose removal disrupts the fewest clients, as ranked by rankPodLoads.
+func (c *Cluster) selectPodsForRemoval(pods []*v1.Pod, n int) []*v1.Pod {
+	// Query the monitoring endpoint of all the pods concurrently, so that unresponsive pods don't slow the selection down.
+	loads := make([]podLoad, len(pods))
+	var wg sync.WaitGroup
+	wg.Add(len(pods))
+	for i, pod := range pods {
+		go func(i int, pod *v1.Pod) {
+			defer wg.Done()
+			loads[i] = c.getPodLoad(pod)
+		}(i, pod)
+	}
+	wg.Wait()
+
+	res := make([]*v1.Pod, 0, n)
+	for _, load := range rankPodLoads(loads)[:n] {
+		c.logger.Infof("selected pod %q for removal (priority: %d, connections: %d, subscriptions: %d)", kubernetesutil.ResourceKey(load.pod), load.priority, load.connections, load.subscriptions)
+		res = append(res, load.pod)
+	}
+	return res
+}
+
+// rankPodLoads returns the specified loads ordered by how disruptive removing the corresponding pods would be, least disruptive first.
+// Pods with a higher scale-down priority come first, followed by the pods with the fewest client connections and subscriptions.
+// Pods which are equally loaded are ordered by reverse order of their position in the specified slice.
+func rankPodLoads(loads []podLoad) []podLoad {
+	res := make([]podLoad, len(loads))
+	for i, load := range loads {
+		res[len(loads)-1-i] = load
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].priority != res[j].priority {
+			return res[i].priority > res[j].priority
+		}
+		if res[i].connections != res[j].connections {
+			return res[i].connections < res[j].connections
+		}
+		return res[i].subscriptions < res[j].subscriptions
+	})
+	return res
+}
@@ -0,0 +1,141 @@
+// Copyright 2019 The nats-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/nats-io/nats-operator/pkg/apis/nats/v1alpha2"
+	kubernetesutil "github.com/nats-io/nats-operator/pkg/util/kubernetes"
+)
+
+func TestRankPodLoads(t *testing.T) {
+	tests := []struct {
+		name string
+		// loads holds the priority, connections and subscriptions of each pod, in the order in which pods are listed.
+		loads [][3]int
+		// expected is the expected ranking, as indexes into loads.
+		expected []int
+	}{
+		{
+			name:     "priority first",
+			loads:    [][3]int{{0, 0, 0}, {5, 100, 100}, {1, 50, 50}},
+			expected: []int{1, 2, 0},
+		},
+		{
+			name:     "negative priority last",
+			loads:    [][3]int{{-1, 0, 0}, {0, 100, 100}},
+			expected: []int{1, 0},
+		},
+		{
+			name:     "then connections",
+			loads:    [][3]int{{0, 30, 0}, {0, 10, 100}, {0, 20, 0}},
+			expected: []int{1, 2, 0},
+		},
+		{
+			name:     "then subscriptions",
+			loads:    [][3]int{{0, 10, 30}, {0, 10, 10}, {0, 10, 20}},
+			expected: []int{1, 2, 0},
+		},
+		{
+			name:     "ties broken by reversed index",
+			loads:    [][3]int{{0, 10, 10}, {0, 10, 10}, {0, 10, 10}},
+			expected: []int{2, 1, 0},
+		},
+		{
+			name:     "partial ties broken by reversed index",
+			loads:    [][3]int{{0, 5, 5}, {0, 10, 10}, {0, 5, 5}, {0, 10, 10}},
+			expected: []int{2, 0, 3, 1},
+		},
+		{
+			name: "unresponsive pods have no load",
+			// The second and fourth pods didn't respond, and hence are reported as having no connections or subscriptions.
+			loads:    [][3]int{{0, 10, 10}, {0, 0, 0}, {0, 1, 0}, {0, 0, 0}},
+			expected: []int{3, 1, 2, 0},
+		},
+		{
+			name:     "empty",
+			loads:    [][3]int{},
+			expected: []int{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loads := make([]podLoad, len(tt.loads))
+			for i, l := range tt.loads {
+				loads[i] = podLoad{
+					pod:           &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: string('a' + rune(i))}},
+					priority:      l[0],
+					connections:   l[1],
+					subscriptions: l[2],
+				}
+			}
+			ranked := rankPodLoads(loads)
+			res := make([]int, len(ranked))
+			for i, load := range ranked {
+				res[i] = int(load.pod.Name[0] - 'a')
+			}
+			if !reflect.DeepEqual(res, tt.expected) {
+				t.Errorf("Expected %v, got: %v", tt.expected, res)
+			}
+		})
+	}
+}
+
+func TestGetPodLoadUnresponsive(t *testing.T) {
+	tests := []struct {
+		name     string
+		priority string
+		expected int
+	}{
+		{
+			name:     "no priority",
+			expected: 0,
+		},
+		{
+			name:     "priority",
+			priority: "5",
+			expected: 5,
+		},
+		{
+			name:     "invalid priority",
+			priority: "high",
+			expected: 0,
+		},
+	}
+
+	cl := newTestNatsCluster(v1alpha2.ClusterSpec{Size: 3, Version: "1.4.0"})
+	c, _ := newTestCluster(t, cl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Nothing serves the monitoring endpoint on the loopback interface, so the pod doesn't respond.
+			pod := newTestPod(t, cl, "example-nats-1", "1.4.0")
+			pod.Status.PodIP = "127.0.0.1"
+			if tt.priority != "" {
+				pod.Annotations[kubernetesutil.ScaleDownPriorityAnnotationKey] = tt.priority
+			}
+			load := c.getPodLoad(pod)
+			expected := podLoad{pod: pod, priority: tt.expected}
+			if !reflect.DeepEqual(load, expected) {
+				t.Errorf("Expected %+v, got: %+v", expected, load)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2019 The nats-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s.io/api/core/v1"
+
+	"github.com/nats-io/nats-operator/pkg/constants"
+)
+
+const (
+	// fullMeshPollInterval is the interval at which we check whether the full mesh has formed.
+	fullMeshPollInterval = 5 * time.Second
+	// monitoringRequestTimeout is the maximum amount of time we wait for the monitoring endpoint of a pod to respond.
+	monitoringRequestTimeout = 5 * time.Second
+)
+
+// routez encapsulates a response from the "/routez" endpoint of the NATS monitoring API.
+type routez struct {
+	Routes []routeInfo `json:"routes"`
+}
+
+// routeInfo encapsulates a single route in a response from the "/routez" endpoint of the NATS monitoring API.
+type routeInfo struct {
+	RemoteID string `json:"remote_id"`
+	IP       string `json:"ip"`
+}
+
+// getMonitoringEndpoint queries the specified path of the monitoring endpoint of the specified pod and decodes the response into v.
+func (c *Cluster) getMonitoringEndpoint(pod *v1.Pod, path string, v interface{}) error {
+	scheme := "http"
+	transport := &http.Transport{}
+	if c.cluster.Spec.TLS != nil && c.cluster.Spec.TLS.EnableHttps {
+		// The certificate presented by the monitoring endpoint is issued for the client-facing hostnames rather than for the pod's IP, so it cannot be verified.
+		// This is acceptable as we only ever read non-sensitive information from it.
+		scheme = "https"
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	client := &http.Client{
+		Timeout:   monitoringRequestTimeout,
+		Transport: transport,
+	}
+	r, err := client.Get(fmt.Sprintf("%s://%s:%d%s", scheme, pod.Status.PodIP, constants.MonitoringPort, path))
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return fmt.Errorf("got unexpected status code %d from %q", r.StatusCode, path)
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// hasFullMesh returns whether the specified number of pods are running and form a healthy route mesh, in which each of them has a route to every other one.
+// The pods are queried concurrently, so that unresponsive pods don't hold the current reconcile iteration for longer than a single request.
+func (c *Cluster) hasFullMesh(expectedSize int) (bool, error) {
+	pods, _, _, err := c.pollPods()
+	if err != nil {
+		return false, err
+	}
+	if len(pods) != expectedSize {
+		return false, nil
+	}
+	if report := inspectMesh(c.queryMesh(pods)); len(report.problems) > 0 {
+		c.logger.Debugf("route mesh has not fully formed: %s", strings.Join(report.problems, "; "))
+		return false, nil
+	}
+	return true, nil
+}
@@ -15,6 +15,8 @@
 package cluster
 
 import (
+	"sync"
+
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -37,7 +39,79 @@ var reconcileFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
 	[]string{"Reason"},
 )
 
+var clusterSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "nats_operator",
+	Subsystem: "cluster",
+	Name:      "size",
+	Help:      "Current number of members in the cluster",
+},
+	[]string{"Namespace", "ClusterName"},
+)
+
+var clusterDesiredSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "nats_operator",
+	Subsystem: "cluster",
+	Name:      "desired_size",
+	Help:      "Desired number of members in the cluster",
+},
+	[]string{"Namespace", "ClusterName"},
+)
+
+var clusterVersion = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "nats_operator",
+	Subsystem: "cluster",
+	Name:      "version",
+	Help:      "Current version of the cluster, as a label on a constant gauge",
+},
+	[]string{"Namespace", "ClusterName", "Version"},
+)
+
+var (
+	// reportedVersionsMu guards reportedVersions.
+	reportedVersionsMu sync.Mutex
+	// reportedVersions holds the version last reported for each cluster, keyed by "namespace/name".
+	// It allows for removing the stale series from clusterVersion when the version changes or the cluster is deleted.
+	reportedVersions = make(map[string]string)
+)
+
 func init() {
 	prometheus.MustRegister(reconcileHistogram)
 	prometheus.MustRegister(reconcileFailed)
+	prometheus.MustRegister(clusterSize)
+	prometheus.MustRegister(clusterDesiredSize)
+	prometheus.MustRegister(clusterVersion)
+}
+
+// reportClusterMetrics updates the per-cluster gauges for the NATS cluster with the specified namespace and name.
+func reportClusterMetrics(namespace, name string, size, desiredSize int, version string) {
+	clusterSize.WithLabelValues(namespace, name).Set(float64(size))
+	clusterDesiredSize.WithLabelValues(namespace, name).Set(float64(desiredSize))
+
+	key := namespace + "/" + name
+	reportedVersionsMu.Lock()
+	defer reportedVersionsMu.Unlock()
+	if v, ok := reportedVersions[key]; ok && v != version {
+		clusterVersion.DeleteLabelValues(namespace, name, v)
+	}
+	if version == "" {
+		delete(reportedVersions, key)
+		return
+	}
+	clusterVersion.WithLabelValues(namespace, name, version).Set(1)
+	reportedVersions[key] = version
+}
+
+// DeleteClusterMetrics removes the per-cluster series for the NATS cluster with the specified namespace and name.
+// It should be called whenever the corresponding NatsCluster resource is deleted.
+func DeleteClusterMetrics(namespace, name string) {
+	clusterSize.DeleteLabelValues(namespace, name)
+	clusterDesiredSize.DeleteLabelValues(namespace, name)
+
+	key := namespace + "/" + name
+	reportedVersionsMu.Lock()
+	defer reportedVersionsMu.Unlock()
+	if v, ok := reportedVersions[key]; ok {
+		clusterVersion.DeleteLabelValues(namespace, name, v)
+		delete(reportedVersions, key)
+	}
 }
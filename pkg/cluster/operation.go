@@ -0,0 +1,109 @@
+// Copyright 2019 The nats-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/api/core/v1"
+
+	"github.com/nats-io/nats-operator/pkg/apis/nats/v1alpha2"
+	kubernetesutil "github.com/nats-io/nats-operator/pkg/util/kubernetes"
+)
+
+// removePods starts gracefully removing the specified pods from the NATS cluster, and records the removal as the pending operation.
+// It does this by trying to make the "gnatsd" process in each pod enter the "lame duck" mode, and each pod is deleted by a subsequent reconcile iteration once its "nats" container terminates.
+// This is done in a best-effort basis, since the NATS version running in a pod may not support this mode.
+// For that reason, we just log any errors without actually failing and proceed to the deletion of the pod right away.
+// If t is "ReplacePod", new pods are created once the current ones have been deleted.
+func (c *Cluster) removePods(pods []*v1.Pod, t v1alpha2.ClusterOperationType) error {
+	c.cluster.Status.SetPendingOperation(t, kubernetesutil.GetPodNames(pods)...)
+	for _, pod := range pods {
+		if err := c.enterLameDuckMode(pod); err != nil {
+			c.logger.Warn(err)
+			if err := c.deletePod(pod); err != nil {
+				return err
+			}
+			continue
+		}
+		c.logger.Infof("placed pod %q in \"lame duck\" mode", kubernetesutil.ResourceKey(pod))
+		c.recordNormalEvent(eventReasonLameDuckMode, "placed pod %s in lame duck mode", pod.Name)
+		c.requeue(c.lameDuckTimeout())
+	}
+	return nil
+}
+
+// resumePendingOperation attempts to move the pending operation forward based on the current state of the target pods.
+// It returns whether the operation has completed, in which case it is cleared from the status of the NatsCluster resource.
+func (c *Cluster) resumePendingOperation() (bool, error) {
+	op := c.cluster.Status.PendingOperation
+
+	// Grab the current state of the pods targeted by the pending operation.
+	pods := make([]*v1.Pod, 0, len(op.Pods))
+	for _, name := range op.Pods {
+		pod, err := c.config.PodLister.Pods(c.cluster.Namespace).Get(name)
+		if err != nil {
+			if kubernetesutil.IsKubernetesResourceNotFoundError(err) {
+				continue
+			}
+			return false, err
+		}
+		pods = append(pods, pod)
+	}
+
+	switch op.Type {
+	case v1alpha2.ClusterOperationCreatePod:
+		// The operation completes as soon as the pod informer observes all the new pods.
+		// We give up waiting after "podObservationTimeout", as some of the pods may have been deleted in the meantime.
+		if len(pods) < len(op.Pods) {
+			if remaining := podObservationTimeout - time.Since(op.StartTime.Time); remaining > 0 {
+				c.requeue(remaining)
+				return false, nil
+			}
+			c.logger.Warnf("only %d out of %d pods were observed within %v", len(pods), len(op.Pods), podObservationTimeout)
+		}
+	case v1alpha2.ClusterOperationRemovePod, v1alpha2.ClusterOperationReplacePod:
+		if len(pods) > 0 {
+			// Delete each pod once its "nats" container has terminated (or the pod itself has), or once we've waited long enough for it to happen.
+			// Pods which are already being deleted are simply waited for.
+			remaining := c.lameDuckTimeout() - time.Since(op.StartTime.Time)
+			for _, pod := range pods {
+				if pod.DeletionTimestamp != nil {
+					continue
+				}
+				if isNatsContainerTerminated(pod) || pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed || remaining <= 0 {
+					if err := c.deletePod(pod); err != nil {
+						return false, err
+					}
+					continue
+				}
+				c.requeue(remaining)
+			}
+			return false, nil
+		}
+		// All the pods are gone, so we create their replacements if required.
+		if op.Type == v1alpha2.ClusterOperationReplacePod {
+			c.cluster.Status.ClearPendingOperation()
+			return false, c.createPods(len(op.Pods))
+		}
+	default:
+		return false, fmt.Errorf("unknown operation type %q", op.Type)
+	}
+
+	c.logger.Infof("%s operation on pods %v completed", op.Type, op.Pods)
+	c.cluster.Status.ClearPendingOperation()
+	return true, nil
+}
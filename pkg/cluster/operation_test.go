@@ -0,0 +1,271 @@
+// Copyright 2019 The nats-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/nats-io/nats-operator/pkg/apis/nats/v1alpha2"
+	"github.com/nats-io/nats-operator/pkg/constants"
+)
+
+// withNatsContainerTerminated marks the "nats" container of the specified pod as terminated.
+func withNatsContainerTerminated(pod *v1.Pod) *v1.Pod {
+	pod.Status.ContainerStatuses = []v1.ContainerStatus{
+		{
+			Name:  constants.NatsContainerName,
+			State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{}},
+		},
+	}
+	return pod
+}
+
+// withDeletionTimestamp marks the specified pod as being deleted.
+func withDeletionTimestamp(pod *v1.Pod) *v1.Pod {
+	now := metav1.Now()
+	pod.DeletionTimestamp = &now
+	return pod
+}
+
+func TestResumePendingOperation(t *testing.T) {
+	cl := newTestNatsCluster(v1alpha2.ClusterSpec{Size: 3, Version: "1.4.0"})
+	pod := func(name string) *v1.Pod {
+		return newTestPod(t, cl, name, "1.4.0")
+	}
+
+	tests := []struct {
+		name string
+		// op is the type of the pending operation.
+		op v1alpha2.ClusterOperationType
+		// opPods is the list of pods targeted by the pending operation.
+		opPods []string
+		// age is the amount of time since the pending operation started.
+		age time.Duration
+		// pods is the list of pods that currently exist.
+		pods []*v1.Pod
+		// done indicates whether the pending operation is expected to complete.
+		done bool
+		// pendingOp is the type of the pending operation expected afterwards, if any.
+		pendingOp v1alpha2.ClusterOperationType
+		// remainingPods is the list of pods expected to exist afterwards.
+		remainingPods []string
+		// requeue indicates whether the NatsCluster resource is expected to be requeued.
+		requeue bool
+		// err indicates whether an error is expected.
+		err bool
+	}{
+		{
+			name:          "all created pods observed",
+			op:            v1alpha2.ClusterOperationCreatePod,
+			opPods:        []string{"example-nats-1", "example-nats-2"},
+			pods:          []*v1.Pod{pod("example-nats-1"), pod("example-nats-2")},
+			done:          true,
+			remainingPods: []string{"example-nats-1", "example-nats-2"},
+		},
+		{
+			name:          "created pod not yet observed",
+			op:            v1alpha2.ClusterOperationCreatePod,
+			opPods:        []string{"example-nats-1", "example-nats-2"},
+			pods:          []*v1.Pod{pod("example-nats-1")},
+			pendingOp:     v1alpha2.ClusterOperationCreatePod,
+			remainingPods: []string{"example-nats-1"},
+			requeue:       true,
+		},
+		{
+			name:          "created pod not observed within the timeout",
+			op:            v1alpha2.ClusterOperationCreatePod,
+			opPods:        []string{"example-nats-1", "example-nats-2"},
+			age:           podObservationTimeout + time.Second,
+			pods:          []*v1.Pod{pod("example-nats-1")},
+			done:          true,
+			remainingPods: []string{"example-nats-1"},
+		},
+		{
+			name:          "removed pod in lame duck mode",
+			op:            v1alpha2.ClusterOperationRemovePod,
+			opPods:        []string{"example-nats-3"},
+			pods:          []*v1.Pod{pod("example-nats-1"), pod("example-nats-3")},
+			pendingOp:     v1alpha2.ClusterOperationRemovePod,
+			remainingPods: []string{"example-nats-1", "example-nats-3"},
+			requeue:       true,
+		},
+		{
+			name:          "removed pod with terminated nats container",
+			op:            v1alpha2.ClusterOperationRemovePod,
+			opPods:        []string{"example-nats-3"},
+			pods:          []*v1.Pod{pod("example-nats-1"), withNatsContainerTerminated(pod("example-nats-3"))},
+			pendingOp:     v1alpha2.ClusterOperationRemovePod,
+			remainingPods: []string{"example-nats-1"},
+		},
+		{
+			name:          "removed pod past the lame duck timeout",
+			op:            v1alpha2.ClusterOperationRemovePod,
+			opPods:        []string{"example-nats-2", "example-nats-3"},
+			age:           2*constants.DefaultLameDuckDurationSeconds*time.Second + time.Second,
+			pods:          []*v1.Pod{pod("example-nats-1"), pod("example-nats-2"), pod("example-nats-3")},
+			pendingOp:     v1alpha2.ClusterOperationRemovePod,
+			remainingPods: []string{"example-nats-1"},
+		},
+		{
+			name:          "removed pod being deleted",
+			op:            v1alpha2.ClusterOperationRemovePod,
+			opPods:        []string{"example-nats-3"},
+			pods:          []*v1.Pod{pod("example-nats-1"), withDeletionTimestamp(pod("example-nats-3"))},
+			pendingOp:     v1alpha2.ClusterOperationRemovePod,
+			remainingPods: []string{"example-nats-1", "example-nats-3"},
+		},
+		{
+			name:          "removed pods gone",
+			op:            v1alpha2.ClusterOperationRemovePod,
+			opPods:        []string{"example-nats-2", "example-nats-3"},
+			pods:          []*v1.Pod{pod("example-nats-1")},
+			done:          true,
+			remainingPods: []string{"example-nats-1"},
+		},
+		{
+			name:          "replaced pod with terminated nats container",
+			op:            v1alpha2.ClusterOperationReplacePod,
+			opPods:        []string{"example-nats-2"},
+			pods:          []*v1.Pod{pod("example-nats-1"), withNatsContainerTerminated(pod("example-nats-2"))},
+			pendingOp:     v1alpha2.ClusterOperationReplacePod,
+			remainingPods: []string{"example-nats-1"},
+		},
+		{
+			name:          "replaced pods gone",
+			op:            v1alpha2.ClusterOperationReplacePod,
+			opPods:        []string{"example-nats-2", "example-nats-3"},
+			pods:          []*v1.Pod{pod("example-nats-1")},
+			pendingOp:     v1alpha2.ClusterOperationCreatePod,
+			remainingPods: []string{"example-nats-1", "example-nats-2", "example-nats-3"},
+		},
+		{
+			name:   "unknown operation",
+			op:     v1alpha2.ClusterOperationType("Unknown"),
+			opPods: []string{"example-nats-1"},
+			pods:   []*v1.Pod{pod("example-nats-1")},
+			err:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects := make([]runtime.Object, 0, len(tt.pods))
+			for _, pod := range tt.pods {
+				objects = append(objects, pod)
+			}
+			c, kubeClient := newTestCluster(t, cl.DeepCopy(), objects...)
+			c.cluster.Status.SetPendingOperation(tt.op, tt.opPods...)
+			c.cluster.Status.PendingOperation.StartTime = metav1.NewTime(time.Now().Add(-tt.age))
+
+			done, err := c.resumePendingOperation()
+			if tt.err {
+				if err == nil {
+					t.Fatalf("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error: %s", err)
+			}
+			if done != tt.done {
+				t.Errorf("Expected done to be %t, got: %t", tt.done, done)
+			}
+			if op := c.cluster.Status.PendingOperation; tt.pendingOp == "" && op != nil {
+				t.Errorf("Expected no pending operation, got: %+v", op)
+			} else if tt.pendingOp != "" && (op == nil || op.Type != tt.pendingOp) {
+				t.Errorf("Expected pending operation %q, got: %+v", tt.pendingOp, op)
+			}
+			if names := listPodNames(t, kubeClient); !reflect.DeepEqual(names, tt.remainingPods) {
+				t.Errorf("Expected pods %v, got: %v", tt.remainingPods, names)
+			}
+			if requeued := c.RequeueAfter() > 0; requeued != tt.requeue {
+				t.Errorf("Expected requeue to be %t, got: %t (%v)", tt.requeue, requeued, c.RequeueAfter())
+			}
+		})
+	}
+}
+
+func TestResumePendingOperationReplacementCreatesPods(t *testing.T) {
+	cl := newTestNatsCluster(v1alpha2.ClusterSpec{Size: 3, Version: "1.4.1"})
+	c, kubeClient := newTestCluster(t, cl, newTestPod(t, cl, "example-nats-1", "1.4.1"))
+	c.cluster.Status.SetPendingOperation(v1alpha2.ClusterOperationReplacePod, "example-nats-2", "example-nats-3")
+
+	if _, err := c.resumePendingOperation(); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	// The replacements take the first available names, and the pending operation now waits for them to be observed.
+	expected := []string{"example-nats-2", "example-nats-3"}
+	if op := c.cluster.Status.PendingOperation; op == nil || op.Type != v1alpha2.ClusterOperationCreatePod || !reflect.DeepEqual(op.Pods, expected) {
+		t.Errorf("Expected %q operation on pods %v, got: %+v", v1alpha2.ClusterOperationCreatePod, expected, op)
+	}
+	for _, name := range expected {
+		pod, err := kubeClient.CoreV1().Pods(testNamespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+		if !metav1.IsControlledBy(pod, cl) {
+			t.Errorf("Expected pod %q to be owned by the cluster", name)
+		}
+	}
+}
+
+func TestRemoveAllPodsFinishesPendingOperation(t *testing.T) {
+	tests := []struct {
+		name string
+		op   v1alpha2.ClusterOperationType
+	}{
+		{
+			name: "create",
+			op:   v1alpha2.ClusterOperationCreatePod,
+		},
+		{
+			name: "remove",
+			op:   v1alpha2.ClusterOperationRemovePod,
+		},
+		{
+			name: "replace",
+			op:   v1alpha2.ClusterOperationReplacePod,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cl := newTestNatsCluster(v1alpha2.ClusterSpec{Size: 3, Version: "1.4.0"})
+			c, kubeClient := newTestCluster(t, cl)
+			c.cluster.Status.SetPendingOperation(tt.op, "example-nats-1", "example-nats-2")
+
+			// All the targeted pods are gone, so the cluster is fully torn down without any pods being created.
+			done, err := c.removeAllPods()
+			if err != nil {
+				t.Fatalf("Error: %s", err)
+			}
+			if !done {
+				t.Errorf("Expected all pods to be removed")
+			}
+			if op := c.cluster.Status.PendingOperation; op != nil {
+				t.Errorf("Expected no pending operation, got: %+v", op)
+			}
+			if names := listPodNames(t, kubeClient); len(names) > 0 {
+				t.Errorf("Expected no pods to be created, got: %v", names)
+			}
+		})
+	}
+}
@@ -15,91 +15,193 @@
 package cluster
 
 import (
+	"fmt"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/nats-io/nats-operator/pkg/apis/nats/v1alpha2"
 	kubernetesutil "github.com/nats-io/nats-operator/pkg/util/kubernetes"
 )
 
-// checkPods reconciles the number and the version of pods belonging to the current NATS cluster.
-func (c *Cluster) checkPods() error {
-	if err := c.reconcileSize(); err != nil {
-		return err
+// checkPods reconciles the number, the version and the spec of pods belonging to the current NATS cluster.
+// It returns whether the pods have converged to the desired state, or an operation has been started in order to drive them towards it.
+func (c *Cluster) checkPods() (bool, error) {
+	if converged, err := c.reconcileSize(); !converged || err != nil {
+		return converged, err
+	}
+	if converged, err := c.reconcileVersion(); !converged || err != nil {
+		return converged, err
 	}
-	return c.reconcileVersion()
+	return c.reconcilePodSpec()
 }
 
 // reconcileSize reconciles the size of the NATS cluster.
-func (c *Cluster) reconcileSize() error {
+// Pods are created or removed in batches whose maximum size is given by ".spec.scaling" (one pod at a time by default).
+func (c *Cluster) reconcileSize() (bool, error) {
 	// Grab an up-to-date list of pods that are currently running.
 	// Pending pods may be ignored safely as we have previously made sure no pods are in pending state.
 	pods, _, _, err := c.pollPods()
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	// Grab the current and desired size of the NATS cluster.
 	currentSize := len(pods)
 	desiredSize := c.cluster.Spec.Size
 
+	// Update the reported size before returning.
+	c.cluster.Status.SetSize(currentSize)
+
 	if currentSize > desiredSize {
-		// Report that we are scaling the cluster down.
-		c.cluster.Status.AppendScalingDownCondition(currentSize, desiredSize)
-		// Remove extra pods as required in order to meet the desired size.
-		// As we remove each pod, we must update the config secret so that routes are re-computed.
-		for idx := currentSize - 1; idx >= desiredSize; idx-- {
-			if err := c.tryGracefulPodDeletion(pods[idx]); err != nil {
-				return err
-			}
-			if err := c.updateConfigSecret(); err != nil {
-				return err
-			}
-		}
+		// Report that we are scaling the cluster down, and remove the next batch of extra pods, picking the least loaded ones.
+		c.cluster.Status.SetScalingDownCondition(currentSize, desiredSize)
+		n := min(currentSize-desiredSize, c.cluster.Spec.Scaling.GetMaxParallelDeletions())
+		c.recordNormalEvent(eventReasonScalingDown, "scaling cluster from %d to %d members, removing %d", currentSize, desiredSize, n)
+		return false, c.removePods(c.selectPodsForRemoval(pods, n), v1alpha2.ClusterOperationRemovePod)
 	}
 
 	if currentSize < desiredSize {
-		// Report that we are scaling the cluster up.
-		c.cluster.Status.AppendScalingUpCondition(currentSize, desiredSize)
-		// Create pods as required in order to meet the desired size.
-		// As we create each pod, we must update the config secret so that routes are re-computed.
-		for idx := currentSize; idx < desiredSize; idx++ {
-			if _, err := c.createPod(); err != nil {
-				return err
-			}
-			if err := c.updateConfigSecret(); err != nil {
-				return err
-			}
-		}
+		// Report that we are scaling the cluster up, and create the next batch of pods.
+		c.cluster.Status.SetScalingUpCondition(currentSize, desiredSize)
+		n := min(desiredSize-currentSize, c.cluster.Spec.Scaling.GetMaxParallelCreations())
+		c.recordNormalEvent(eventReasonScalingUp, "scaling cluster from %d to %d members, adding %d", currentSize, desiredSize, n)
+		return false, c.createPods(n)
 	}
 
-	// Update the reported size before returning.
-	c.cluster.Status.SetSize(desiredSize)
-	return nil
+	return true, nil
 }
 
 // reconcileVersion reconciles the version of pods belonging to the NATS cluster.
-func (c *Cluster) reconcileVersion() error {
+// At most one pod is upgraded (or rolled back, in case the upgrade has failed) at a time, and only the pods selected by ".spec.upgradeStrategy" are upgraded.
+func (c *Cluster) reconcileVersion() (bool, error) {
 	// Grab an up-to-date list of pods that are currently running.
 	// Pending pods may be ignored safely as we have previously made sure no pods are in pending state.
 	pods, _, _, err := c.pollPods()
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	// Grab the current and desired version of the NATS cluster.
 	currentVersion := c.cluster.Status.CurrentVersion
-	desiredVersion := c.cluster.Spec.Version
+	desiredVersion := c.desiredVersion()
+	rollingBack := desiredVersion != c.cluster.Spec.Version
 
-	if currentVersion != "" && currentVersion != desiredVersion {
-		// Report that we are upgrading the cluster's version.
-		c.cluster.Status.AppendUpgradingCondition(currentVersion, desiredVersion)
-		// Iterate over pods, upgrading them as necessary.
+	if currentVersion != "" {
+		// Record the start of an upgrade, unless it is already in progress.
+		if u := c.cluster.Status.Upgrade; currentVersion != desiredVersion && (u == nil || u.ToVersion != desiredVersion || u.RolledBack) {
+			c.cluster.Status.StartUpgrade(currentVersion, desiredVersion)
+			c.recordNormalEvent(eventReasonUpgradeStarted, "upgrading cluster from %s to %s", currentVersion, desiredVersion)
+		}
+		// Look for a pod which isn't running the desired version yet.
+		heldBack := false
 		for _, pod := range pods {
-			if kubernetesutil.GetNATSVersion(pod) != c.cluster.Spec.Version {
-				c.maybeUpgradeMgmtService()
-				return c.upgradePod(pod)
+			podVersion := kubernetesutil.GetNATSVersion(pod)
+			if podVersion == desiredVersion {
+				continue
+			}
+			// Pods held back by the "Partition" upgrade strategy keep running the current version.
+			if podVersion == c.desiredVersionFor(pod.Name) {
+				heldBack = true
+				continue
 			}
+			// Report that we are upgrading (or rolling back) the cluster's version, and replace the current pod.
+			if rollingBack {
+				c.cluster.Status.SetRollingBackCondition(podVersion, desiredVersion)
+			} else {
+				if c.holdForCanary(pods) {
+					return false, nil
+				}
+				c.cluster.Status.SetUpgradingCondition(currentVersion, desiredVersion)
+			}
+			if err := c.maybeUpgradeMgmtService(); err != nil {
+				c.logger.Warn(err)
+			}
+			return false, c.upgradePod(pod)
+		}
+		// The cluster keeps reporting the current version until all of its members have been upgraded.
+		if heldBack {
+			return true, nil
 		}
 	}
 
+	// All pods are running the desired version, so any upgrade in progress has completed.
+	if u := c.cluster.Status.Upgrade; u != nil && !u.RolledBack {
+		c.recordNormalEvent(eventReasonUpgradeCompleted, "upgraded cluster from %s to %s", u.FromVersion, u.ToVersion)
+		c.cluster.Status.CompleteUpgrade()
+	}
 	// Update the reported cluster version before returning.
 	c.cluster.Status.SetCurrentVersion(desiredVersion)
+	return true, nil
+}
+
+// reconcilePodSpec replaces pods whose spec has drifted from the one rendered for the current NatsCluster resource.
+// At most one pod is replaced at a time, and we wait for the full mesh to form before replacing the next one.
+func (c *Cluster) reconcilePodSpec() (bool, error) {
+	// Grab an up-to-date list of pods that are currently running.
+	// Pending pods may be ignored safely as we have previously made sure no pods are in pending state.
+	pods, _, _, err := c.pollPods()
+	if err != nil {
+		return false, err
+	}
+
+	desiredHash, err := kubernetesutil.PodSpecHash(c.cluster.Name, c.cluster.Spec)
+	if err != nil {
+		return false, err
+	}
+	for _, pod := range pods {
+		currentHash := kubernetesutil.GetPodSpecHash(pod)
+		if currentHash == desiredHash {
+			continue
+		}
+		// Pods created by previous versions of the operator don't have a hash.
+		// Adopt these as they are instead of replacing them, as we can't tell whether their spec has drifted.
+		if currentHash == "" {
+			if err := c.adoptPodSpecHash(pod, desiredHash); err != nil {
+				return false, err
+			}
+			continue
+		}
+
+		// Make sure that the full mesh has formed (e.g. after replacing the previous pod) before replacing the current one.
+		fullMesh, err := c.hasFullMesh(len(pods))
+		if err != nil {
+			return false, err
+		}
+		if !fullMesh {
+			c.logger.Infof("waiting for the full mesh to form before replacing pod %q", kubernetesutil.ResourceKey(pod))
+			c.cluster.Status.SetRollingUpdateCondition(pod.Name)
+			c.requeue(fullMeshPollInterval)
+			return false, nil
+		}
+
+		// Report that we are replacing the current pod, and gracefully remove it from the NATS cluster.
+		c.logger.Infof("replacing pod %q as its spec has drifted", kubernetesutil.ResourceKey(pod))
+		c.recordNormalEvent(eventReasonRollingUpdate, "replacing pod %s as its spec has drifted", pod.Name)
+		c.cluster.Status.SetRollingUpdateCondition(pod.Name)
+		return false, c.removePods([]*v1.Pod{pod}, v1alpha2.ClusterOperationReplacePod)
+	}
+	return true, nil
+}
+
+// adoptPodSpecHash sets the hash of the spec of the specified pod to the specified value.
+func (c *Cluster) adoptPodSpecHash(pod *v1.Pod, hash string) error {
+	newPod := pod.DeepCopy()
+	kubernetesutil.SetPodSpecHash(newPod, hash)
+	patchBytes, err := kubernetesutil.CreatePatch(pod, newPod, v1.Pod{})
+	if err != nil {
+		return fmt.Errorf("error creating patch: %v", err)
+	}
+	if _, err := c.config.KubeCli.Pods(pod.Namespace).Patch(pod.Name, types.StrategicMergePatchType, patchBytes); err != nil {
+		return fmt.Errorf("failed to set the spec hash of pod %q: %v", kubernetesutil.ResourceKey(pod), err)
+	}
 	return nil
 }
+
+// min returns the smallest of the specified integers.
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
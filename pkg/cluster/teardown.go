@@ -0,0 +1,138 @@
+// Copyright 2019 The nats-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/nats-io/nats-operator/pkg/apis/nats/v1alpha2"
+	kubernetesutil "github.com/nats-io/nats-operator/pkg/util/kubernetes"
+)
+
+const (
+	// teardownTimeout is the maximum amount of time we spend gracefully removing the members of a NatsCluster resource which is being deleted.
+	// Once it elapses, any remaining pods are left for the garbage collector to delete.
+	teardownTimeout = 10 * time.Minute
+)
+
+// teardown gracefully removes the members of the NatsCluster resource being deleted, placing them in "lame duck" mode in batches of at most "spec.scaling.maxParallelDeletions" pods.
+// Once all of them are gone (or "teardownTimeout" has elapsed), it deletes the bound-token secrets issued for the cluster and removes the finalizer, allowing the resource to be deleted.
+func (c *Cluster) teardown() error {
+	if !c.cluster.HasFinalizer(v1alpha2.ClusterFinalizer) {
+		return nil
+	}
+	if c.cluster.Status.Phase != v1alpha2.ClusterPhaseTerminating {
+		c.logger.Info("tearing down cluster")
+		c.recordNormalEvent(eventReasonTeardown, "removing all members of the cluster")
+		c.cluster.Status.SetPhase(v1alpha2.ClusterPhaseTerminating)
+	}
+
+	if remaining := teardownTimeout - time.Since(c.cluster.DeletionTimestamp.Time); remaining > 0 {
+		done, err := c.removeAllPods()
+		if err != nil {
+			return c.reportFailure("TeardownFailed", fmt.Errorf("failed to remove pods: %v", err))
+		}
+		if !done {
+			c.requeue(remaining)
+			return c.updateCluster()
+		}
+	} else {
+		c.logger.Warnf("gave up waiting for pods to be removed after %v", teardownTimeout)
+	}
+
+	// All the members are gone, so the bound-token secrets issued for the cluster are no longer needed.
+	if err := c.deleteBoundTokenSecrets(); err != nil {
+		return c.reportFailure("TeardownFailed", fmt.Errorf("failed to delete bound-token secrets: %v", err))
+	}
+
+	// Remove the finalizer so that the NatsCluster resource (and the resources it owns) can be deleted.
+	// The status is not updated as the resource is about to disappear.
+	c.cluster.RemoveFinalizer(v1alpha2.ClusterFinalizer)
+	if err := c.patchCluster(); err != nil {
+		return err
+	}
+	c.logger.Info("cluster torn down")
+	return nil
+}
+
+// removeAllPods moves the graceful removal of the members of the cluster forward, and returns whether all of them are gone.
+func (c *Cluster) removeAllPods() (bool, error) {
+	// Finish any operation in flight before removing further pods, making sure that no pods are created in the meantime.
+	if op := c.cluster.Status.PendingOperation; op != nil {
+		switch op.Type {
+		case v1alpha2.ClusterOperationCreatePod:
+			c.cluster.Status.ClearPendingOperation()
+		case v1alpha2.ClusterOperationReplacePod:
+			op.Type = v1alpha2.ClusterOperationRemovePod
+		}
+	}
+	if c.cluster.Status.PendingOperation != nil {
+		done, err := c.resumePendingOperation()
+		if err != nil || !done {
+			return false, err
+		}
+	}
+
+	pods, err := c.config.PodLister.Pods(c.cluster.Namespace).List(kubernetesutil.LabelSelectorForCluster(c.cluster.Name))
+	if err != nil {
+		return false, err
+	}
+	if len(pods) == 0 {
+		return true, nil
+	}
+
+	// Pods which are already being deleted are simply waited for.
+	removable := make([]*v1.Pod, 0, len(pods))
+	for _, pod := range pods {
+		if pod.DeletionTimestamp == nil {
+			removable = append(removable, pod)
+		}
+	}
+	if len(removable) == 0 {
+		return false, nil
+	}
+
+	// Remove pods in a predictable order.
+	sort.Slice(removable, func(i, j int) bool {
+		return removable[i].Name < removable[j].Name
+	})
+	n := min(len(removable), c.cluster.Spec.Scaling.GetMaxParallelDeletions())
+	c.logger.Infof("removing %d out of %d remaining pods", n, len(pods))
+	return false, c.removePods(removable[:n], v1alpha2.ClusterOperationRemovePod)
+}
+
+// deleteBoundTokenSecrets deletes the secrets holding the tokens issued to the service accounts mapped to the NatsServiceRole resources of the cluster.
+// These are owned by the NatsServiceRole resources rather than by the NatsCluster resource, and hence would otherwise outlive it.
+func (c *Cluster) deleteBoundTokenSecrets() error {
+	secrets, err := c.config.SecretLister.Secrets(c.cluster.Namespace).List(kubernetesutil.LabelSelectorForCluster(c.cluster.Name))
+	if err != nil {
+		return err
+	}
+	for _, secret := range secrets {
+		if ownerRef := metav1.GetControllerOf(secret); ownerRef == nil || ownerRef.Kind != v1alpha2.ServiceRoleCRDResourceKind {
+			continue
+		}
+		if err := c.config.KubeCli.Secrets(secret.Namespace).Delete(secret.Name, &metav1.DeleteOptions{}); err != nil && !kubernetesutil.IsKubernetesResourceNotFoundError(err) {
+			return err
+		}
+		c.logger.Infof("deleted bound-token secret %q", kubernetesutil.ResourceKey(secret))
+	}
+	return nil
+}
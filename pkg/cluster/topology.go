@@ -0,0 +1,174 @@
+// Copyright 2019 The nats-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"fmt"
+	"sort"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/nats-io/nats-operator/pkg/apis/nats/v1alpha2"
+	kubernetesutil "github.com/nats-io/nats-operator/pkg/util/kubernetes"
+)
+
+// domainCounts holds bounds on the number of members of the cluster in each domain of a topology key.
+// Members which haven't been scheduled yet may end up in any of the domains they are allowed in, so they are accounted for in the upper bound of each of these domains, and in the lower bound only if there is a single one.
+type domainCounts struct {
+	// lower holds the minimum number of members in each domain.
+	lower map[string]int
+	// upper holds the maximum number of members in each domain.
+	upper map[string]int
+}
+
+// newDomainCounts counts the specified pods in each domain of the specified topology key, as defined by the labels of the specified nodes.
+// Pods scheduled on nodes without the topology key are ignored.
+func newDomainCounts(topologyKey string, nodes []v1.Node, pods []*v1.Pod) *domainCounts {
+	d := &domainCounts{
+		lower: make(map[string]int),
+		upper: make(map[string]int),
+	}
+	nodeDomains := make(map[string]string, len(nodes))
+	for _, node := range nodes {
+		if domain, ok := node.Labels[topologyKey]; ok {
+			nodeDomains[node.Name] = domain
+			d.lower[domain] = 0
+			d.upper[domain] = 0
+		}
+	}
+	for _, pod := range pods {
+		if pod.Spec.NodeName != "" {
+			if domain, ok := nodeDomains[pod.Spec.NodeName]; ok {
+				d.add([]string{domain})
+			}
+			continue
+		}
+		// Pods which haven't been scheduled yet may end up in any domain, unless they have been restricted to some of them.
+		domains := kubernetesutil.GetTopologyDomains(pod, topologyKey)
+		if len(domains) == 0 {
+			domains = d.domains()
+		}
+		d.add(domains)
+	}
+	return d
+}
+
+// domains returns the sorted list of known domains.
+func (d *domainCounts) domains() []string {
+	res := make([]string, 0, len(d.upper))
+	for domain := range d.upper {
+		res = append(res, domain)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// add accounts for a member which may end up in any of the specified domains.
+// Unknown domains are ignored.
+func (d *domainCounts) add(domains []string) {
+	known := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		if _, ok := d.upper[domain]; ok {
+			known = append(known, domain)
+		}
+	}
+	for _, domain := range known {
+		d.upper[domain]++
+	}
+	if len(known) == 1 {
+		d.lower[known[0]]++
+	}
+}
+
+// allowed returns the sorted list of domains in which a new member may be placed without the difference between the number of members in any two domains exceeding the specified maximum.
+// Domains are only allowed if this holds no matter where the members which haven't been scheduled yet end up.
+func (d *domainCounts) allowed(maxSkew int32) []string {
+	min := -1
+	for _, n := range d.lower {
+		if min < 0 || n < min {
+			min = n
+		}
+	}
+	res := make([]string, 0, len(d.upper))
+	for _, domain := range d.domains() {
+		if d.upper[domain]+1-min <= int(maxSkew) {
+			res = append(res, domain)
+		}
+	}
+	return res
+}
+
+// topologySpread enforces the spread constraints of the current NatsCluster resource on the pods being created.
+type topologySpread struct {
+	// constraints is the list of spread constraints to enforce.
+	constraints []v1alpha2.TopologySpreadConstraint
+	// counts holds the number of members in each domain of the topology key of each constraint.
+	counts []*domainCounts
+}
+
+// newTopologySpread counts the specified pods in each domain of the topology keys of the spread constraints of the current NatsCluster resource.
+// Only the nodes matching the specified node selector are taken into account, as new pods cannot be placed anywhere else.
+func (c *Cluster) newTopologySpread(nodeSelector map[string]string, pods []*v1.Pod) (*topologySpread, error) {
+	s := &topologySpread{}
+	if p := c.cluster.Spec.Pod; p != nil && p.Topology != nil {
+		s.constraints = p.Topology.SpreadConstraints
+	}
+	if len(s.constraints) == 0 {
+		return s, nil
+	}
+	nodes, err := c.config.KubeCli.Nodes().List(metav1.ListOptions{LabelSelector: labels.SelectorFromSet(nodeSelector).String()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %v", err)
+	}
+	// Pods which are being deleted are about to leave their domain.
+	members := make([]*v1.Pod, 0, len(pods))
+	for _, pod := range pods {
+		if pod.DeletionTimestamp == nil {
+			members = append(members, pod)
+		}
+	}
+	for _, constraint := range s.constraints {
+		s.counts = append(s.counts, newDomainCounts(constraint.TopologyKey, nodes.Items, members))
+	}
+	return s, nil
+}
+
+// apply restricts the domains in which the specified pod may be scheduled according to the spread constraints, and accounts for the pod.
+// It returns false, leaving the pod untouched, if no domain satisfies one of the "DoNotSchedule" constraints, which may happen when the pods created previously haven't been scheduled yet.
+// Pods are required to be scheduled on a node with the topology key of a "DoNotSchedule" constraint, so they are left pending if there is no such node.
+func (s *topologySpread) apply(pod *v1.Pod) bool {
+	allowed := make([][]string, len(s.constraints))
+	for i, constraint := range s.constraints {
+		allowed[i] = s.counts[i].allowed(constraint.MaxSkew)
+		if len(allowed[i]) == 0 && len(s.counts[i].upper) > 0 && constraint.WhenUnsatisfiable != v1alpha2.UnsatisfiableConstraintActionScheduleAnyway {
+			return false
+		}
+	}
+	for i, constraint := range s.constraints {
+		required := constraint.WhenUnsatisfiable != v1alpha2.UnsatisfiableConstraintActionScheduleAnyway
+		domains := allowed[i]
+		if required || len(domains) > 0 {
+			kubernetesutil.PodWithTopologyDomains(pod, constraint.TopologyKey, domains, required)
+		}
+		// Pods which are merely preferred to be placed in some domains may still end up in any of them.
+		if !required || len(domains) == 0 {
+			domains = s.counts[i].domains()
+		}
+		s.counts[i].add(domains)
+	}
+	return true
+}
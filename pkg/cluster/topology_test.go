@@ -0,0 +1,220 @@
+// Copyright 2019 The nats-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/nats-io/nats-operator/pkg/apis/nats/v1alpha2"
+	kubernetesutil "github.com/nats-io/nats-operator/pkg/util/kubernetes"
+)
+
+// newTestNode returns a node with the specified name and labels.
+func newTestNode(name string, labels map[string]string) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: labels,
+		},
+	}
+}
+
+// newTestSpreadPod returns a pod which has either been scheduled on the specified node, or restricted to the specified zones.
+func newTestSpreadPod(nodeName string, zones ...string) *v1.Pod {
+	pod := &v1.Pod{Spec: v1.PodSpec{NodeName: nodeName}}
+	if len(zones) > 0 {
+		kubernetesutil.PodWithTopologyDomains(pod, "zone", zones, true)
+	}
+	return pod
+}
+
+func TestDomainCounts(t *testing.T) {
+	nodes := []v1.Node{
+		*newTestNode("node-a", map[string]string{"zone": "a"}),
+		*newTestNode("node-b", map[string]string{"zone": "b"}),
+		*newTestNode("node-c", map[string]string{"zone": "c"}),
+		*newTestNode("node-x", map[string]string{}),
+	}
+
+	tests := []struct {
+		name    string
+		pods    []*v1.Pod
+		maxSkew int32
+		lower   map[string]int
+		upper   map[string]int
+		allowed []string
+	}{
+		{
+			name:    "no pods",
+			maxSkew: 1,
+			lower:   map[string]int{"a": 0, "b": 0, "c": 0},
+			upper:   map[string]int{"a": 0, "b": 0, "c": 0},
+			allowed: []string{"a", "b", "c"},
+		},
+		{
+			name:    "scheduled pods",
+			pods:    []*v1.Pod{newTestSpreadPod("node-a"), newTestSpreadPod("node-a"), newTestSpreadPod("node-b"), newTestSpreadPod("node-x")},
+			maxSkew: 1,
+			lower:   map[string]int{"a": 2, "b": 1, "c": 0},
+			upper:   map[string]int{"a": 2, "b": 1, "c": 0},
+			allowed: []string{"c"},
+		},
+		{
+			name:    "scheduled pods with a larger skew",
+			pods:    []*v1.Pod{newTestSpreadPod("node-a"), newTestSpreadPod("node-a"), newTestSpreadPod("node-b")},
+			maxSkew: 2,
+			lower:   map[string]int{"a": 2, "b": 1, "c": 0},
+			upper:   map[string]int{"a": 2, "b": 1, "c": 0},
+			allowed: []string{"b", "c"},
+		},
+		{
+			name:    "pending pods restricted to some zones",
+			pods:    []*v1.Pod{newTestSpreadPod("node-a"), newTestSpreadPod("", "b", "c"), newTestSpreadPod("", "c")},
+			maxSkew: 2,
+			lower:   map[string]int{"a": 1, "b": 0, "c": 1},
+			upper:   map[string]int{"a": 1, "b": 1, "c": 2},
+			allowed: []string{"a", "b"},
+		},
+		{
+			name:    "pending pods restricted to unknown zones",
+			pods:    []*v1.Pod{newTestSpreadPod("", "d"), newTestSpreadPod("", "c", "d")},
+			maxSkew: 1,
+			lower:   map[string]int{"a": 0, "b": 0, "c": 1},
+			upper:   map[string]int{"a": 0, "b": 0, "c": 1},
+			allowed: []string{"a", "b"},
+		},
+		{
+			name:    "unrestricted pending pod",
+			pods:    []*v1.Pod{newTestSpreadPod("")},
+			maxSkew: 1,
+			lower:   map[string]int{"a": 0, "b": 0, "c": 0},
+			upper:   map[string]int{"a": 1, "b": 1, "c": 1},
+			allowed: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newDomainCounts("zone", nodes, tt.pods)
+			if !reflect.DeepEqual(d.lower, tt.lower) {
+				t.Errorf("Expected lower bounds %v, got: %v", tt.lower, d.lower)
+			}
+			if !reflect.DeepEqual(d.upper, tt.upper) {
+				t.Errorf("Expected upper bounds %v, got: %v", tt.upper, d.upper)
+			}
+			if allowed := d.allowed(tt.maxSkew); !reflect.DeepEqual(allowed, tt.allowed) {
+				t.Errorf("Expected allowed domains %v, got: %v", tt.allowed, allowed)
+			}
+		})
+	}
+}
+
+func TestCreatePodsTopologySpread(t *testing.T) {
+	// zones holds the zone of each node.
+	zones := map[string]string{
+		"node-a": "a",
+		"node-b": "b",
+		"node-c": "c",
+	}
+
+	tests := []struct {
+		name              string
+		whenUnsatisfiable v1alpha2.UnsatisfiableConstraintAction
+		nodes             map[string]string
+		expectedPods      []string
+		expectedRequired  map[string][]string
+		expectedPreferred map[string][]string
+	}{
+		{
+			name:              "do not schedule",
+			whenUnsatisfiable: v1alpha2.UnsatisfiableConstraintActionDoNotSchedule,
+			nodes:             zones,
+			// The second pod must wait for the first one to be scheduled, as it could otherwise end up in the same zone.
+			expectedPods:     []string{"example-nats-1", "example-nats-2"},
+			expectedRequired: map[string][]string{"example-nats-2": {"b", "c"}},
+		},
+		{
+			name:              "schedule anyway",
+			whenUnsatisfiable: v1alpha2.UnsatisfiableConstraintActionScheduleAnyway,
+			nodes:             zones,
+			expectedPods:      []string{"example-nats-1", "example-nats-2", "example-nats-3", "example-nats-4"},
+			expectedPreferred: map[string][]string{"example-nats-2": {"b", "c"}},
+		},
+		{
+			name:              "no eligible nodes",
+			whenUnsatisfiable: v1alpha2.UnsatisfiableConstraintActionDoNotSchedule,
+			// Pods are created but cannot be scheduled until a node with the topology key shows up.
+			expectedPods:     []string{"example-nats-1", "example-nats-2", "example-nats-3", "example-nats-4"},
+			expectedRequired: map[string][]string{"example-nats-2": nil, "example-nats-3": nil, "example-nats-4": nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cl := newTestNatsCluster(v1alpha2.ClusterSpec{
+				Size:    4,
+				Version: "1.4.0",
+				Pod: &v1alpha2.PodPolicy{Topology: &v1alpha2.TopologyPolicy{SpreadConstraints: []v1alpha2.TopologySpreadConstraint{
+					{TopologyKey: "zone", MaxSkew: 1, WhenUnsatisfiable: tt.whenUnsatisfiable},
+				}}},
+			})
+			cl.Spec.SetDefaults()
+			// The existing member has been scheduled in zone "a".
+			pod := newTestPod(t, cl, "example-nats-1", "1.4.0")
+			pod.Spec.NodeName = "node-a"
+			objects := []runtime.Object{pod}
+			for name, zone := range tt.nodes {
+				objects = append(objects, newTestNode(name, map[string]string{"zone": zone}))
+			}
+			c, kubeClient := newTestCluster(t, cl, objects...)
+
+			if err := c.createPods(3); err != nil {
+				t.Fatalf("Error: %s", err)
+			}
+			if names := listPodNames(t, kubeClient); !reflect.DeepEqual(names, tt.expectedPods) {
+				t.Fatalf("Expected pods %v, got: %v", tt.expectedPods, names)
+			}
+			for name, expected := range tt.expectedRequired {
+				created, err := kubeClient.CoreV1().Pods(testNamespace).Get(name, metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("Error: %s", err)
+				}
+				if domains := kubernetesutil.GetTopologyDomains(created, "zone"); !reflect.DeepEqual(domains, expected) {
+					t.Errorf("Expected pod %q to be restricted to %v, got: %v", name, expected, domains)
+				}
+				if created.Spec.Affinity == nil || created.Spec.Affinity.NodeAffinity == nil || created.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+					t.Errorf("Expected pod %q to have a required node affinity", name)
+				}
+			}
+			for name, expected := range tt.expectedPreferred {
+				created, err := kubeClient.CoreV1().Pods(testNamespace).Get(name, metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("Error: %s", err)
+				}
+				if created.Spec.Affinity == nil || created.Spec.Affinity.NodeAffinity == nil || len(created.Spec.Affinity.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution) != 1 {
+					t.Fatalf("Expected pod %q to have a preferred node affinity", name)
+				}
+				if domains := created.Spec.Affinity.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution[0].Preference.MatchExpressions[0].Values; !reflect.DeepEqual(domains, expected) {
+					t.Errorf("Expected pod %q to prefer %v, got: %v", name, expected, domains)
+				}
+			}
+		})
+	}
+}
@@ -15,99 +15,150 @@
 package cluster
 
 import (
-	"context"
 	"fmt"
+	"time"
 
+	"github.com/nats-io/nats-operator/pkg/apis/nats/v1alpha2"
 	kubernetesutil "github.com/nats-io/nats-operator/pkg/util/kubernetes"
 
 	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/kubernetes/pkg/util/slice"
 )
 
-// upgradePod upgrades the specified pod to the desired version for the current NATS cluster.
-// In order to do that, we first try to make NATS enter the "lame duck" mode.
-// If we succeed, we adopt a special upgrade procedure since the pod (or at least its "nats" container) will have been terminated and can't be upgraded directly.
-// If we fail, we stick to the usual method of upgrading the container's "image" field to the desired version.
-func (c *Cluster) upgradePod(pod *v1.Pod) error {
-	if err := c.enterLameDuckModeAndWaitTermination(pod); err != nil {
-		c.logger.Warn(err)
-		return c.upgradeRunningPod(pod)
+// desiredVersion returns the version the pods of the current NATS cluster should be running.
+// This is the version in the spec, unless an upgrade to it has failed and been rolled back, in which case it is the last known-good version until the spec changes again.
+func (c *Cluster) desiredVersion() string {
+	if u := c.cluster.Status.Upgrade; u != nil && u.RolledBack && u.ToVersion == c.cluster.Spec.Version && u.RolledBackGeneration == c.cluster.Generation {
+		return u.FromVersion
 	}
-	return c.upgradeTerminatedPod(pod)
+	return c.cluster.Spec.Version
 }
 
-func (c *Cluster) upgradeRunningPod(oldPod *v1.Pod) error {
-	ns := c.cluster.Namespace
-
-	pod, err := c.config.KubeCli.Pods(ns).Get(oldPod.GetName(), metav1.GetOptions{})
+// desiredVersionFor returns the version the pod with the specified name should be running.
+// This is the desired version of the current NATS cluster, unless the "Partition" upgrade strategy holds the pod back at the current version.
+func (c *Cluster) desiredVersionFor(podName string) string {
+	currentVersion := c.cluster.Status.CurrentVersion
+	desiredVersion := c.desiredVersion()
+	s := c.cluster.Spec.UpgradeStrategy
+	if currentVersion == "" || currentVersion == desiredVersion || s == nil || s.Type != v1alpha2.UpgradeStrategyTypePartition || s.Partition == nil {
+		return desiredVersion
+	}
+	ordinal, err := kubernetesutil.PodOrdinal(c.cluster.Name, podName)
 	if err != nil {
-		return fmt.Errorf("fail to get pod %q: %v", kubernetesutil.ResourceKey(oldPod), err)
+		c.logger.Warn(err)
+		return desiredVersion
 	}
-	oldpod := pod.DeepCopy()
+	if ordinal < s.Partition.Ordinal {
+		return currentVersion
+	}
+	return desiredVersion
+}
 
-	c.logger.Infof("upgrading the NATS member %q from %s to %s", kubernetesutil.ResourceKey(oldPod), kubernetesutil.GetNATSVersion(pod), c.cluster.Spec.Version)
-	pod.Spec.Containers[0].Image = kubernetesutil.MakeNATSImage(c.cluster.Spec.Version, c.cluster.Spec.ServerImage)
-	kubernetesutil.SetNATSVersion(pod, c.cluster.Spec.Version)
+// holdForCanary returns whether the upgrade in progress must be held because the "Canary" upgrade strategy is used and the canary members have been upgraded.
+// The upgrade is held until the canary members have soaked for long enough and the upgrade has been approved using the "nats.io/approve-upgrade" annotation.
+func (c *Cluster) holdForCanary(pods []*v1.Pod) bool {
+	s := c.cluster.Spec.UpgradeStrategy
+	u := c.cluster.Status.Upgrade
+	if s == nil || s.Type != v1alpha2.UpgradeStrategyTypeCanary || s.Canary == nil || u == nil {
+		return false
+	}
 
-	patchdata, err := kubernetesutil.CreatePatch(oldpod, pod, v1.Pod{})
-	if err != nil {
-		return fmt.Errorf("error creating patch: %v", err)
+	// Count the members that have already been upgraded.
+	upgraded := 0
+	for _, pod := range pods {
+		if kubernetesutil.GetNATSVersion(pod) == u.ToVersion {
+			upgraded++
+		}
+	}
+	if upgraded < s.Canary.Replicas {
+		return false
 	}
 
-	_, err = c.config.KubeCli.Pods(ns).Patch(pod.GetName(), types.StrategicMergePatchType, patchdata)
-	if err != nil {
-		return fmt.Errorf("fail to update the NATS member %q: %v", kubernetesutil.ResourceKey(oldPod), err)
+	// Take note of the time at which the canary members finished being upgraded, and wait for them to soak.
+	if u.CanaryTime == nil {
+		c.logger.Infof("%d canary members have been upgraded to %s", upgraded, u.ToVersion)
+		c.recordNormalEvent(eventReasonUpgradeHeld, "holding upgrade to %s as %d canary members have been upgraded", u.ToVersion, upgraded)
+		now := metav1.Now()
+		u.CanaryTime = &now
+	}
+	soak := time.Duration(s.Canary.SoakSeconds) * time.Second
+	if remaining := soak - time.Since(u.CanaryTime.Time); remaining > 0 {
+		c.cluster.Status.SetUpgradeHeldCondition(fmt.Sprintf("waiting for %d canary members running %s to soak for %v", upgraded, u.ToVersion, soak))
+		c.requeue(remaining)
+		return true
 	}
 
-	// Wait for the pod to be running and ready.
-	c.logger.Infof("waiting for pod %q to become ready", kubernetesutil.ResourceKey(pod))
-	ctx, fn := context.WithTimeout(context.Background(), podReadinessTimeout)
-	defer fn()
-	if err := kubernetesutil.WaitUntilPodReady(ctx, c.config.KubeCli, pod); err != nil {
-		return err
-	}
-	c.logger.Infof("pod %q became ready", kubernetesutil.ResourceKey(pod))
+	// Wait for the upgrade to be approved.
+	if c.cluster.GetApprovedUpgradeVersion() != u.ToVersion {
+		c.cluster.Status.SetUpgradeHeldCondition(fmt.Sprintf("waiting for the upgrade to %s to be approved", u.ToVersion))
+		return true
+	}
+	return false
+}
 
-	c.logger.Infof("finished upgrading the NATS member %q", kubernetesutil.ResourceKey(pod))
-	return nil
+// upgradePod upgrades the specified pod to the desired version for the current NATS cluster.
+// It does this by gracefully removing the pod from the NATS cluster and replacing it with a new one running the desired version.
+func (c *Cluster) upgradePod(pod *v1.Pod) error {
+	c.logger.Infof("upgrading the NATS member %q from %s to %s", kubernetesutil.ResourceKey(pod), kubernetesutil.GetNATSVersion(pod), c.desiredVersionFor(pod.Name))
+	return c.removePods([]*v1.Pod{pod}, v1alpha2.ClusterOperationReplacePod)
 }
 
-// upgradeTerminatedPod upgrades the version of a pod for which one of the containers has already terminated.
-// It does this by deleting and re-creating the pod.
-func (c *Cluster) upgradeTerminatedPod(pod *v1.Pod) error {
-	if err := c.deletePod(pod); err != nil {
-		return err
+// trackUpgrade takes note of the upgraded pods which have failed, and rolls the upgrade in progress back once there are too many of them according to ".spec.upgrade".
+// Upgraded pods fail when they terminate with an error, or when they don't become ready in time (in which case they are deleted here).
+// Pods in terminal phases are left for the caller to delete.
+func (c *Cluster) trackUpgrade(pods []*v1.Pod) error {
+	u := c.cluster.Status.Upgrade
+	policy := c.cluster.Spec.Upgrade
+	if u == nil || u.RolledBack || policy == nil {
+		return nil
 	}
-	if _, err := c.createPod(); err != nil {
-		return err
+
+	timeout := time.Duration(policy.TimeoutSeconds) * time.Second
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil || kubernetesutil.GetNATSVersion(pod) != u.ToVersion || slice.ContainsString(u.FailedPods, string(pod.UID), nil) {
+			continue
+		}
+		switch {
+		case pod.Status.Phase == v1.PodFailed:
+			c.logger.Warnf("upgraded pod %q has failed", kubernetesutil.ResourceKey(pod))
+			c.recordWarningEvent(eventReasonUpgradedPodFailed, "upgraded pod %s has failed", pod.Name)
+		case pod.Status.Phase != v1.PodSucceeded && !kubernetesutil.IsPodRunningAndReady(pod):
+			if d := time.Since(notReadySince(pod)); d < timeout {
+				c.requeue(timeout - d)
+				continue
+			}
+			c.logger.Warnf("upgraded pod %q has not become ready within %v", kubernetesutil.ResourceKey(pod), timeout)
+			c.recordWarningEvent(eventReasonUpgradedPodFailed, "upgraded pod %s has not become ready within %v", pod.Name, timeout)
+			if err := c.deletePod(pod); err != nil {
+				return err
+			}
+		default:
+			continue
+		}
+		u.FailedPods = append(u.FailedPods, string(pod.UID))
+	}
+
+	if len(u.FailedPods) >= policy.FailureThreshold {
+		c.logger.Warnf("rolling back upgrade from %s to %s as %d upgraded pods have failed", u.FromVersion, u.ToVersion, len(u.FailedPods))
+		c.recordWarningEvent(eventReasonUpgradeRolledBack, "rolling back upgrade from %s to %s as %d upgraded pods have failed", u.FromVersion, u.ToVersion, len(u.FailedPods))
+		c.cluster.Status.RollbackUpgrade(c.cluster.Generation, fmt.Sprintf("%d upgraded pods have failed", len(u.FailedPods)))
 	}
 	return nil
 }
 
+// maybeUpgradeMgmtService makes the management service select the pods running the desired version of the cluster.
 func (c *Cluster) maybeUpgradeMgmtService() error {
-	ns := c.cluster.Namespace
-	sn := kubernetesutil.ManagementServiceName(c.cluster.Name)
-
-	svc, err := c.config.KubeCli.Services(ns).Get(sn, metav1.GetOptions{})
+	desired := kubernetesutil.NewMgmtService(c.cluster.Name, c.desiredVersion(), c.cluster.Namespace, c.cluster.AsOwner())
+	svc, err := c.config.KubeCli.Services(desired.Namespace).Get(desired.Name, metav1.GetOptions{})
 	if err != nil {
-		return fmt.Errorf("failed to get service \"%s/%s\": %v", ns, sn, err)
+		return fmt.Errorf("failed to get service \"%s/%s\": %v", desired.Namespace, desired.Name, err)
 	}
-	if svc.Spec.Selector[kubernetesutil.LabelClusterVersionKey] == c.cluster.Spec.Version {
-		c.logger.Infof("NATS management service %q has already been updated to %s", kubernetesutil.ResourceKey(svc), c.cluster.Spec.Version)
+	if svc.Spec.Selector[kubernetesutil.LabelClusterVersionKey] == c.desiredVersion() {
+		c.logger.Infof("NATS management service %q has already been updated to %s", kubernetesutil.ResourceKey(svc), c.desiredVersion())
 		return nil
 	}
-	oldsvc := svc.DeepCopy()
-
-	svc.Spec.Selector[kubernetesutil.LabelClusterVersionKey] = c.cluster.Spec.Version
-
-	patchdata, err := kubernetesutil.CreatePatch(oldsvc, svc, v1.Service{})
-	if err != nil {
-		return fmt.Errorf("error creating patch: %v", err)
-	}
-
-	_, err = c.config.KubeCli.Services(ns).Patch(svc.GetName(), types.StrategicMergePatchType, patchdata)
-	if err != nil {
+	if _, err := c.applyService(svc, desired); err != nil {
 		return fmt.Errorf("fail to update the NATS management service %q: %v", kubernetesutil.ResourceKey(svc), err)
 	}
 	c.logger.Infof("finished upgrading the NATS management service %q", kubernetesutil.ResourceKey(svc))
@@ -0,0 +1,506 @@
+// Copyright 2019 The nats-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/nats-io/nats-operator/pkg/apis/nats/v1alpha2"
+)
+
+// withPhase sets the phase of the specified pod.
+func withPhase(pod *v1.Pod, phase v1.PodPhase) *v1.Pod {
+	pod.Status.Phase = phase
+	return pod
+}
+
+// withNotReadyFor marks the specified pod as running but not ready for the specified amount of time.
+func withNotReadyFor(pod *v1.Pod, d time.Duration) *v1.Pod {
+	pod.Status.Conditions = []v1.PodCondition{
+		{
+			Type:               v1.PodReady,
+			Status:             v1.ConditionFalse,
+			LastTransitionTime: metav1.NewTime(time.Now().Add(-d)),
+		},
+	}
+	return pod
+}
+
+func TestDesiredVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		version  string
+		upgrade  *v1alpha2.UpgradeStatus
+		expected string
+	}{
+		{
+			name:     "no upgrade",
+			version:  "1.4.1",
+			expected: "1.4.1",
+		},
+		{
+			name:     "upgrade in progress",
+			version:  "1.4.1",
+			upgrade:  &v1alpha2.UpgradeStatus{FromVersion: "1.4.0", ToVersion: "1.4.1"},
+			expected: "1.4.1",
+		},
+		{
+			name:     "rolled back",
+			version:  "1.4.1",
+			upgrade:  &v1alpha2.UpgradeStatus{FromVersion: "1.4.0", ToVersion: "1.4.1", RolledBack: true, RolledBackGeneration: 2},
+			expected: "1.4.0",
+		},
+		{
+			name:     "rolled back at a previous generation",
+			version:  "1.4.1",
+			upgrade:  &v1alpha2.UpgradeStatus{FromVersion: "1.4.0", ToVersion: "1.4.1", RolledBack: true, RolledBackGeneration: 1},
+			expected: "1.4.1",
+		},
+		{
+			name:     "rolled back from a different version",
+			version:  "2.0.0",
+			upgrade:  &v1alpha2.UpgradeStatus{FromVersion: "1.4.0", ToVersion: "1.4.1", RolledBack: true, RolledBackGeneration: 2},
+			expected: "2.0.0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cl := newTestNatsCluster(v1alpha2.ClusterSpec{Size: 3, Version: tt.version})
+			cl.Generation = 2
+			cl.Status.Upgrade = tt.upgrade
+			c, _ := newTestCluster(t, cl)
+			if v := c.desiredVersion(); v != tt.expected {
+				t.Errorf("Expected %q, got: %q", tt.expected, v)
+			}
+		})
+	}
+}
+
+func TestTrackUpgrade(t *testing.T) {
+	timeout := time.Duration(60) * time.Second
+	cl := newTestNatsCluster(v1alpha2.ClusterSpec{
+		Size:    3,
+		Version: "1.4.1",
+		Upgrade: &v1alpha2.UpgradePolicy{FailureThreshold: 2, TimeoutSeconds: 60},
+	})
+	cl.Generation = 2
+	oldPod := func(name string) *v1.Pod {
+		return newTestPod(t, cl, name, "1.4.0")
+	}
+	newPod := func(name string) *v1.Pod {
+		return newTestPod(t, cl, name, "1.4.1")
+	}
+
+	tests := []struct {
+		name string
+		// noPolicy indicates whether the upgrade policy of the cluster is unset.
+		noPolicy bool
+		// upgrade is the status of the upgrade in progress.
+		upgrade *v1alpha2.UpgradeStatus
+		// pods is the list of pods that currently exist.
+		pods []*v1.Pod
+		// failedPods is the list of UIDs of the failed pods expected afterwards.
+		failedPods []string
+		// rolledBack indicates whether the upgrade is expected to be rolled back.
+		rolledBack bool
+		// remainingPods is the list of pods expected to exist afterwards.
+		remainingPods []string
+		// requeue indicates whether the NatsCluster resource is expected to be requeued.
+		requeue bool
+	}{
+		{
+			name:          "no upgrade",
+			pods:          []*v1.Pod{withPhase(newPod("example-nats-1"), v1.PodFailed)},
+			remainingPods: []string{"example-nats-1"},
+		},
+		{
+			name:          "no policy",
+			noPolicy:      true,
+			upgrade:       &v1alpha2.UpgradeStatus{FromVersion: "1.4.0", ToVersion: "1.4.1"},
+			pods:          []*v1.Pod{withPhase(newPod("example-nats-1"), v1.PodFailed)},
+			remainingPods: []string{"example-nats-1"},
+		},
+		{
+			name:          "already rolled back",
+			upgrade:       &v1alpha2.UpgradeStatus{FromVersion: "1.4.0", ToVersion: "1.4.1", RolledBack: true, RolledBackGeneration: 2},
+			pods:          []*v1.Pod{withPhase(newPod("example-nats-1"), v1.PodFailed)},
+			rolledBack:    true,
+			remainingPods: []string{"example-nats-1"},
+		},
+		{
+			name:          "healthy upgraded pods",
+			upgrade:       &v1alpha2.UpgradeStatus{FromVersion: "1.4.0", ToVersion: "1.4.1"},
+			pods:          []*v1.Pod{newPod("example-nats-1"), oldPod("example-nats-2")},
+			remainingPods: []string{"example-nats-1", "example-nats-2"},
+		},
+		{
+			name:          "failed upgraded pod",
+			upgrade:       &v1alpha2.UpgradeStatus{FromVersion: "1.4.0", ToVersion: "1.4.1"},
+			pods:          []*v1.Pod{withPhase(newPod("example-nats-1"), v1.PodFailed), oldPod("example-nats-2")},
+			failedPods:    []string{"example-nats-1"},
+			remainingPods: []string{"example-nats-1", "example-nats-2"},
+		},
+		{
+			name:          "failed pod running the previous version",
+			upgrade:       &v1alpha2.UpgradeStatus{FromVersion: "1.4.0", ToVersion: "1.4.1"},
+			pods:          []*v1.Pod{withPhase(oldPod("example-nats-1"), v1.PodFailed)},
+			remainingPods: []string{"example-nats-1"},
+		},
+		{
+			name:          "failed upgraded pod being deleted",
+			upgrade:       &v1alpha2.UpgradeStatus{FromVersion: "1.4.0", ToVersion: "1.4.1"},
+			pods:          []*v1.Pod{withDeletionTimestamp(withPhase(newPod("example-nats-1"), v1.PodFailed))},
+			remainingPods: []string{"example-nats-1"},
+		},
+		{
+			name:          "failed upgraded pod already accounted for",
+			upgrade:       &v1alpha2.UpgradeStatus{FromVersion: "1.4.0", ToVersion: "1.4.1", FailedPods: []string{"example-nats-1"}},
+			pods:          []*v1.Pod{withPhase(newPod("example-nats-1"), v1.PodFailed)},
+			failedPods:    []string{"example-nats-1"},
+			remainingPods: []string{"example-nats-1"},
+		},
+		{
+			name:          "upgraded pod not ready yet",
+			upgrade:       &v1alpha2.UpgradeStatus{FromVersion: "1.4.0", ToVersion: "1.4.1"},
+			pods:          []*v1.Pod{withNotReadyFor(newPod("example-nats-1"), timeout/2)},
+			remainingPods: []string{"example-nats-1"},
+			requeue:       true,
+		},
+		{
+			name:          "upgraded pod not ready within the timeout",
+			upgrade:       &v1alpha2.UpgradeStatus{FromVersion: "1.4.0", ToVersion: "1.4.1"},
+			pods:          []*v1.Pod{withNotReadyFor(newPod("example-nats-1"), timeout+time.Second), newPod("example-nats-2")},
+			failedPods:    []string{"example-nats-1"},
+			remainingPods: []string{"example-nats-2"},
+		},
+		{
+			name:    "threshold reached",
+			upgrade: &v1alpha2.UpgradeStatus{FromVersion: "1.4.0", ToVersion: "1.4.1", FailedPods: []string{"example-nats-1"}},
+			pods: []*v1.Pod{
+				withPhase(newPod("example-nats-1"), v1.PodFailed),
+				withNotReadyFor(newPod("example-nats-2"), timeout+time.Second),
+				oldPod("example-nats-3"),
+			},
+			failedPods:    []string{"example-nats-1", "example-nats-2"},
+			rolledBack:    true,
+			remainingPods: []string{"example-nats-1", "example-nats-3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			natsCluster := cl.DeepCopy()
+			if tt.noPolicy {
+				natsCluster.Spec.Upgrade = nil
+			}
+			natsCluster.Status.Upgrade = tt.upgrade
+			objects := make([]runtime.Object, 0, len(tt.pods))
+			for _, pod := range tt.pods {
+				objects = append(objects, pod)
+			}
+			c, kubeClient := newTestCluster(t, natsCluster, objects...)
+
+			if err := c.trackUpgrade(tt.pods); err != nil {
+				t.Fatalf("Error: %s", err)
+			}
+			if names := listPodNames(t, kubeClient); !reflect.DeepEqual(names, tt.remainingPods) {
+				t.Errorf("Expected pods %v, got: %v", tt.remainingPods, names)
+			}
+			if requeued := c.RequeueAfter() > 0; requeued != tt.requeue {
+				t.Errorf("Expected requeue to be %t, got: %t (%v)", tt.requeue, requeued, c.RequeueAfter())
+			}
+			u := c.cluster.Status.Upgrade
+			if u == nil {
+				return
+			}
+			var failedPods []string
+			if len(u.FailedPods) > 0 {
+				failedPods = u.FailedPods
+			}
+			if !reflect.DeepEqual(failedPods, tt.failedPods) {
+				t.Errorf("Expected failed pods %v, got: %v", tt.failedPods, failedPods)
+			}
+			if u.RolledBack != tt.rolledBack {
+				t.Errorf("Expected rolled back to be %t, got: %t", tt.rolledBack, u.RolledBack)
+			}
+			if tt.rolledBack && !tt.upgrade.RolledBack {
+				if u.RolledBackGeneration != natsCluster.Generation {
+					t.Errorf("Expected rolled back generation %d, got: %d", natsCluster.Generation, u.RolledBackGeneration)
+				}
+				if !c.cluster.Status.IsConditionTrue(v1alpha2.ClusterConditionUpgradeFailed) {
+					t.Errorf("Expected the %q condition to be true", v1alpha2.ClusterConditionUpgradeFailed)
+				}
+				if v := c.desiredVersion(); v != u.FromVersion {
+					t.Errorf("Expected desired version %q, got: %q", u.FromVersion, v)
+				}
+			}
+		})
+	}
+}
+
+func TestDesiredVersionFor(t *testing.T) {
+	partition := &v1alpha2.UpgradeStrategy{
+		Type:      v1alpha2.UpgradeStrategyTypePartition,
+		Partition: &v1alpha2.PartitionUpgradeStrategy{Ordinal: 11},
+	}
+
+	tests := []struct {
+		name           string
+		currentVersion string
+		strategy       *v1alpha2.UpgradeStrategy
+		podName        string
+		expected       string
+	}{
+		{
+			name:           "no strategy",
+			currentVersion: "1.4.0",
+			podName:        "example-nats-1",
+			expected:       "1.4.1",
+		},
+		{
+			name:           "below the partition",
+			currentVersion: "1.4.0",
+			strategy:       partition,
+			podName:        "example-nats-a",
+			expected:       "1.4.0",
+		},
+		{
+			name:           "at the partition",
+			currentVersion: "1.4.0",
+			strategy:       partition,
+			podName:        "example-nats-b",
+			expected:       "1.4.1",
+		},
+		{
+			name:           "above the partition",
+			currentVersion: "1.4.0",
+			strategy:       partition,
+			podName:        "example-nats-c",
+			expected:       "1.4.1",
+		},
+		{
+			name: "hexadecimal ordinal above the partition",
+			// "10" is 16 in base 16, which is above the partition even though 10 (in base 10) isn't.
+			currentVersion: "1.4.0",
+			strategy:       partition,
+			podName:        "example-nats-10",
+			expected:       "1.4.1",
+		},
+		{
+			name:           "decimal-looking ordinal below the partition",
+			currentVersion: "1.4.0",
+			strategy:       partition,
+			podName:        "example-nats-9",
+			expected:       "1.4.0",
+		},
+		{
+			name:           "invalid ordinal",
+			currentVersion: "1.4.0",
+			strategy:       partition,
+			podName:        "example-nats-zz",
+			expected:       "1.4.1",
+		},
+		{
+			name:           "nil partition",
+			currentVersion: "1.4.0",
+			strategy:       &v1alpha2.UpgradeStrategy{Type: v1alpha2.UpgradeStrategyTypePartition},
+			podName:        "example-nats-1",
+			expected:       "1.4.1",
+		},
+		{
+			name:           "canary strategy",
+			currentVersion: "1.4.0",
+			strategy:       &v1alpha2.UpgradeStrategy{Type: v1alpha2.UpgradeStrategyTypeCanary, Canary: &v1alpha2.CanaryUpgradeStrategy{Replicas: 1}},
+			podName:        "example-nats-1",
+			expected:       "1.4.1",
+		},
+		{
+			name:     "unknown current version",
+			strategy: partition,
+			podName:  "example-nats-1",
+			expected: "1.4.1",
+		},
+		{
+			name:           "no upgrade in progress",
+			currentVersion: "1.4.1",
+			strategy:       partition,
+			podName:        "example-nats-1",
+			expected:       "1.4.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cl := newTestNatsCluster(v1alpha2.ClusterSpec{Size: 3, Version: "1.4.1", UpgradeStrategy: tt.strategy})
+			cl.Status.CurrentVersion = tt.currentVersion
+			c, _ := newTestCluster(t, cl)
+			if v := c.desiredVersionFor(tt.podName); v != tt.expected {
+				t.Errorf("Expected %q, got: %q", tt.expected, v)
+			}
+		})
+	}
+}
+
+func TestHoldForCanary(t *testing.T) {
+	canary := &v1alpha2.UpgradeStrategy{
+		Type:   v1alpha2.UpgradeStrategyTypeCanary,
+		Canary: &v1alpha2.CanaryUpgradeStrategy{Replicas: 2, SoakSeconds: 60},
+	}
+	since := func(d time.Duration) *metav1.Time {
+		ts := metav1.NewTime(time.Now().Add(-d))
+		return &ts
+	}
+
+	tests := []struct {
+		name string
+		// strategy is the upgrade strategy of the cluster.
+		strategy *v1alpha2.UpgradeStrategy
+		// noUpgrade indicates whether there is no upgrade in progress.
+		noUpgrade bool
+		// upgraded is the number of members (out of three) that have already been upgraded.
+		upgraded int
+		// canaryTime is the amount of time since the canary members finished being upgraded, if they have.
+		canaryTime *metav1.Time
+		// approvedVersion is the version to which the upgrade has been approved, if any.
+		approvedVersion string
+		// hold indicates whether the upgrade is expected to be held.
+		hold bool
+		// requeue indicates whether the NatsCluster resource is expected to be requeued.
+		requeue bool
+	}{
+		{
+			name:     "no strategy",
+			upgraded: 2,
+		},
+		{
+			name:     "partition strategy",
+			strategy: &v1alpha2.UpgradeStrategy{Type: v1alpha2.UpgradeStrategyTypePartition, Partition: &v1alpha2.PartitionUpgradeStrategy{Ordinal: 1}},
+			upgraded: 2,
+		},
+		{
+			name:     "nil canary",
+			strategy: &v1alpha2.UpgradeStrategy{Type: v1alpha2.UpgradeStrategyTypeCanary},
+			upgraded: 2,
+		},
+		{
+			name:      "no upgrade in progress",
+			strategy:  canary,
+			noUpgrade: true,
+			upgraded:  2,
+		},
+		{
+			name:     "canary members not upgraded yet",
+			strategy: canary,
+			upgraded: 1,
+		},
+		{
+			name:     "canary members just upgraded",
+			strategy: canary,
+			upgraded: 2,
+			hold:     true,
+			requeue:  true,
+		},
+		{
+			name:       "canary members soaking",
+			strategy:   canary,
+			upgraded:   2,
+			canaryTime: since(30 * time.Second),
+			hold:       true,
+			requeue:    true,
+		},
+		{
+			name:            "canary members soaking with approval",
+			strategy:        canary,
+			upgraded:        2,
+			canaryTime:      since(30 * time.Second),
+			approvedVersion: "1.4.1",
+			hold:            true,
+			requeue:         true,
+		},
+		{
+			name:       "canary members soaked without approval",
+			strategy:   canary,
+			upgraded:   2,
+			canaryTime: since(2 * time.Minute),
+			hold:       true,
+		},
+		{
+			name:            "approval for the wrong version",
+			strategy:        canary,
+			upgraded:        2,
+			canaryTime:      since(2 * time.Minute),
+			approvedVersion: "1.4.2",
+			hold:            true,
+		},
+		{
+			name:            "approved",
+			strategy:        canary,
+			upgraded:        2,
+			canaryTime:      since(2 * time.Minute),
+			approvedVersion: "1.4.1",
+		},
+		{
+			name:            "approved without soak time",
+			strategy:        &v1alpha2.UpgradeStrategy{Type: v1alpha2.UpgradeStrategyTypeCanary, Canary: &v1alpha2.CanaryUpgradeStrategy{Replicas: 2}},
+			upgraded:        3,
+			approvedVersion: "1.4.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cl := newTestNatsCluster(v1alpha2.ClusterSpec{Size: 3, Version: "1.4.1", UpgradeStrategy: tt.strategy})
+			if !tt.noUpgrade {
+				cl.Status.StartUpgrade("1.4.0", "1.4.1")
+				cl.Status.Upgrade.CanaryTime = tt.canaryTime
+			}
+			if tt.approvedVersion != "" {
+				cl.Annotations = map[string]string{"nats.io/approve-upgrade": tt.approvedVersion}
+			}
+			pods := make([]*v1.Pod, 0, 3)
+			for i := 1; i <= 3; i++ {
+				version := "1.4.0"
+				if i <= tt.upgraded {
+					version = "1.4.1"
+				}
+				pods = append(pods, newTestPod(t, cl, fmt.Sprintf("%s-%x", testClusterName, i), version))
+			}
+			c, _ := newTestCluster(t, cl)
+
+			if hold := c.holdForCanary(pods); hold != tt.hold {
+				t.Errorf("Expected hold to be %t, got: %t", tt.hold, hold)
+			}
+			if requeued := c.RequeueAfter() > 0; requeued != tt.requeue {
+				t.Errorf("Expected requeue to be %t, got: %t (%v)", tt.requeue, requeued, c.RequeueAfter())
+			}
+			if tt.hold {
+				if u := c.cluster.Status.Upgrade; u.CanaryTime == nil {
+					t.Errorf("Expected the canary time to be set")
+				}
+				if cond := c.cluster.Status.GetCondition(v1alpha2.ClusterConditionUpgrading); cond == nil || cond.Reason != v1alpha2.ClusterReasonUpgradeHeld {
+					t.Errorf("Expected the %q condition to have reason %q, got: %+v", v1alpha2.ClusterConditionUpgrading, v1alpha2.ClusterReasonUpgradeHeld, cond)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package natsconf
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// confTagDuration marks string fields holding a duration (e.g. "2m"), which may also be specified as a number of seconds.
+// Durations are checked when decoding, and written as any other string.
+const confTagDuration = "duration"
+
+// decode stores the specified value, as returned by Parse, into the specified settable value.
+// Keys of blocks are matched against the keys under which fields are written, ignoring case as the NATS server does, and keys without a matching field are stored in the "extra" field of the struct.
+// Blocks are merged into the structs and maps already present in v, while arrays and scalars replace the existing values.
+func decode(value interface{}, v reflect.Value, tag string) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() && v.Elem().Kind() == reflect.Struct {
+			return decode(value, v.Elem(), tag)
+		}
+		elem := reflect.New(v.Type().Elem())
+		if err := decode(value, elem.Elem(), tag); err != nil {
+			return err
+		}
+		v.Set(elem)
+	case reflect.Interface:
+		v.Set(reflect.ValueOf(value))
+	case reflect.Struct:
+		block, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expecting a block, found %s", describe(value))
+		}
+		return decodeStruct(block, v)
+	case reflect.Map:
+		block, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expecting a block, found %s", describe(value))
+		}
+		if v.Type().Elem().Kind() == reflect.Interface {
+			v.Set(reflect.ValueOf(block))
+			return nil
+		}
+		return decodeMap(block, v, tag)
+	case reflect.Slice:
+		values, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("expecting an array, found %s", describe(value))
+		}
+		res := reflect.MakeSlice(v.Type(), len(values), len(values))
+		for i, elem := range values {
+			if err := decode(elem, res.Index(i), tag); err != nil {
+				return fmt.Errorf("element %d: %v", i, err)
+			}
+		}
+		v.Set(res)
+	case reflect.String:
+		switch value := value.(type) {
+		case string:
+			if tag == confTagDuration {
+				if _, err := time.ParseDuration(value); err != nil {
+					return fmt.Errorf("invalid duration %q", value)
+				}
+			}
+			v.SetString(value)
+		case int64:
+			// Durations may be specified as a number of seconds.
+			if tag != confTagDuration {
+				return fmt.Errorf("expecting a string, found %s", describe(value))
+			}
+			v.SetString(fmt.Sprintf("%ds", value))
+		default:
+			return fmt.Errorf("expecting a string, found %s", describe(value))
+		}
+	case reflect.Bool:
+		b, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("expecting a boolean, found %s", describe(value))
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch value := value.(type) {
+		case int64:
+			v.SetInt(value)
+		case float64:
+			if value != float64(int64(value)) {
+				return fmt.Errorf("expecting an integer, found %v", value)
+			}
+			v.SetInt(int64(value))
+		default:
+			return fmt.Errorf("expecting an integer, found %s", describe(value))
+		}
+	case reflect.Float32, reflect.Float64:
+		switch value := value.(type) {
+		case int64:
+			v.SetFloat(float64(value))
+		case float64:
+			v.SetFloat(value)
+		default:
+			return fmt.Errorf("expecting a number, found %s", describe(value))
+		}
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// decodeStruct stores the keys of the specified block into the fields of the specified struct.
+func decodeStruct(block map[string]interface{}, v reflect.Value) error {
+	t := v.Type()
+	fields := make(map[string]int, t.NumField())
+	extra := -1
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Tag.Get("conf") == confTagExtra {
+			extra = i
+			continue
+		}
+		if name, _ := parseJSONTag(f); name != "" {
+			fields[strings.ToLower(name)] = i
+		}
+	}
+
+	for key, value := range block {
+		i, ok := fields[strings.ToLower(key)]
+		if !ok {
+			if extra < 0 {
+				return fmt.Errorf("unknown key %q", key)
+			}
+			fv := v.Field(extra)
+			if fv.IsNil() {
+				fv.Set(reflect.MakeMap(fv.Type()))
+			}
+			m := fv.Interface().(map[string]interface{})
+			m[key] = mergeValues(m[key], value)
+			continue
+		}
+		if err := decode(value, v.Field(i), t.Field(i).Tag.Get("conf")); err != nil {
+			return fmt.Errorf("%s: %v", key, err)
+		}
+	}
+	return nil
+}
+
+// decodeMap stores the keys of the specified block into the specified map of typed values (e.g. accounts), merging them into the entries already present.
+func decodeMap(block map[string]interface{}, v reflect.Value, tag string) error {
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(v.Type()))
+	}
+	for key, value := range block {
+		k := reflect.ValueOf(key)
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if existing := v.MapIndex(k); existing.IsValid() {
+			elem.Set(existing)
+		}
+		if err := decode(value, elem, tag); err != nil {
+			return fmt.Errorf("%s: %v", key, err)
+		}
+		v.SetMapIndex(k, elem)
+	}
+	return nil
+}
+
+// mergeValues returns the result of merging the specified value on top of the specified existing one.
+// Blocks are merged recursively, while any other value replaces the existing one.
+func mergeValues(existing, value interface{}) interface{} {
+	existingBlock, ok := existing.(map[string]interface{})
+	if !ok {
+		return value
+	}
+	block, ok := value.(map[string]interface{})
+	if !ok {
+		return value
+	}
+	res := make(map[string]interface{}, len(existingBlock)+len(block))
+	for k, v := range existingBlock {
+		res[k] = v
+	}
+	for k, v := range block {
+		res[k] = mergeValues(res[k], v)
+	}
+	return res
+}
+
+// describe returns a short description of the type of the specified value, as returned by Parse, for use in error messages.
+func describe(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "a block"
+	case []interface{}:
+		return "an array"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	case int64:
+		return "an integer"
+	case float64:
+		return "a floating-point number"
+	}
+	return fmt.Sprintf("%T", value)
+}
@@ -0,0 +1,298 @@
+package natsconf
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	// indentation is the string used to indent the contents of blocks and arrays.
+	indentation = "  "
+
+	// confTagSize marks integer fields holding a number of bytes, which are written using size suffixes (e.g. "1MB") where possible.
+	confTagSize = "size"
+	// confTagInclude marks string fields holding the path to a file which is written as an "include" directive rather than as a key.
+	confTagInclude = "include"
+	// confTagExtra marks the map field holding the keys of a block which have no matching field.
+	confTagExtra = "extra"
+)
+
+// sizeSuffixes are the size suffixes understood by the NATS server, largest first.
+var sizeSuffixes = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+}
+
+// encoder writes a configuration in the NATS configuration format.
+// Keys are written in the order in which the fields are declared (or in lexicographical order in the case of maps), so the output for a given configuration is always the same.
+type encoder struct {
+	buf bytes.Buffer
+}
+
+// writeComment writes the specified text as a comment, one line at a time.
+func (e *encoder) writeComment(text string) {
+	for _, line := range strings.Split(text, "\n") {
+		e.buf.WriteString(strings.TrimRight("# "+line, " "))
+		e.buf.WriteByte('\n')
+	}
+}
+
+// writeEntries writes the fields of the specified struct or the entries of the specified map, one per line.
+func (e *encoder) writeEntries(v reflect.Value, depth int) error {
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		var extra reflect.Value
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.Tag.Get("conf") == confTagExtra {
+				extra = v.Field(i)
+				continue
+			}
+			name, omitEmpty := parseJSONTag(f)
+			if name == "" {
+				continue
+			}
+			fv := v.Field(i)
+			if omitEmpty && isEmptyValue(fv) {
+				continue
+			}
+			if err := e.writeEntry(name, fv, f.Tag.Get("conf"), depth); err != nil {
+				return err
+			}
+		}
+		if extra.IsValid() && extra.Len() > 0 {
+			return e.writeEntries(extra, depth)
+		}
+	case reflect.Map:
+		keys := make([]string, 0, v.Len())
+		values := make(map[string]reflect.Value, v.Len())
+		for _, k := range v.MapKeys() {
+			if k.Kind() != reflect.String {
+				return fmt.Errorf("unsupported map key type %s", k.Type())
+			}
+			keys = append(keys, k.String())
+			values[k.String()] = v.MapIndex(k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			// Include directives which could not be resolved when parsing a configuration are kept under the "include" key.
+			tag := ""
+			if k == includeKey && indirect(values[k]).Kind() == reflect.String {
+				tag = confTagInclude
+			}
+			if err := e.writeEntry(k, values[k], tag, depth); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported block type %s", v.Type())
+	}
+	return nil
+}
+
+// writeEntry writes the specified key and value at the specified depth.
+// Structs and maps are written as blocks, and nil values are skipped altogether.
+func (e *encoder) writeEntry(key string, v reflect.Value, tag string, depth int) error {
+	v = indirect(v)
+	if !v.IsValid() {
+		return nil
+	}
+	e.writeIndent(depth)
+	if tag == confTagInclude {
+		if v.Kind() != reflect.String {
+			return fmt.Errorf("unsupported include type %s", v.Type())
+		}
+		e.buf.WriteString("include ")
+		e.buf.WriteString(quote(v.String()))
+		e.buf.WriteByte('\n')
+		return nil
+	}
+	e.buf.WriteString(formatKey(key))
+	switch v.Kind() {
+	case reflect.Struct, reflect.Map:
+		e.buf.WriteByte(' ')
+	default:
+		e.buf.WriteString(": ")
+	}
+	if err := e.writeValue(v, tag, depth); err != nil {
+		return fmt.Errorf("failed to write %q: %v", key, err)
+	}
+	e.buf.WriteByte('\n')
+	return nil
+}
+
+// writeValue writes the specified value, with nested lines indented past the specified depth.
+func (e *encoder) writeValue(v reflect.Value, tag string, depth int) error {
+	v = indirect(v)
+	if !v.IsValid() {
+		return fmt.Errorf("unsupported nil value")
+	}
+	switch v.Kind() {
+	case reflect.Struct, reflect.Map:
+		if v.Kind() == reflect.Map && v.Len() == 0 {
+			e.buf.WriteString("{}")
+			return nil
+		}
+		e.buf.WriteString("{\n")
+		if err := e.writeEntries(v, depth+1); err != nil {
+			return err
+		}
+		e.writeIndent(depth)
+		e.buf.WriteByte('}')
+	case reflect.Slice, reflect.Array:
+		if v.Len() == 0 {
+			e.buf.WriteString("[]")
+			return nil
+		}
+		e.buf.WriteString("[\n")
+		for i := 0; i < v.Len(); i++ {
+			e.writeIndent(depth + 1)
+			if err := e.writeValue(v.Index(i), tag, depth+1); err != nil {
+				return err
+			}
+			e.buf.WriteByte('\n')
+		}
+		e.writeIndent(depth)
+		e.buf.WriteByte(']')
+	case reflect.String:
+		e.buf.WriteString(quote(v.String()))
+	case reflect.Bool:
+		e.buf.WriteString(strconv.FormatBool(v.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.buf.WriteString(formatInt(v.Int(), tag == confTagSize))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		e.buf.WriteString(formatInt(int64(v.Uint()), tag == confTagSize))
+	case reflect.Float32, reflect.Float64:
+		e.buf.WriteString(strconv.FormatFloat(v.Float(), 'f', -1, 64))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// writeIndent indents the current line to the specified depth.
+func (e *encoder) writeIndent(depth int) {
+	e.buf.WriteString(strings.Repeat(indentation, depth))
+}
+
+// parseJSONTag returns the key under which the specified field is written and whether it is omitted when empty, based on its "json" tag.
+// An empty key is returned for fields which are never written.
+func parseJSONTag(f reflect.StructField) (string, bool) {
+	if f.PkgPath != "" {
+		return "", false
+	}
+	parts := strings.Split(f.Tag.Get("json"), ",")
+	name := parts[0]
+	if name == "-" {
+		return "", false
+	}
+	if name == "" {
+		name = f.Name
+	}
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			return name, true
+		}
+	}
+	return name, false
+}
+
+// indirect dereferences the specified value until it is neither a pointer nor an interface, returning the zero Value if it is nil.
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// isEmptyValue returns whether the specified value is considered empty, following the rules of "omitempty" in "encoding/json".
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// formatKey returns the specified key, quoted unless it only contains characters which are safe in unquoted keys.
+func formatKey(key string) string {
+	if key == "" {
+		return quote(key)
+	}
+	for _, r := range key {
+		if !isBareKeyChar(r) {
+			return quote(key)
+		}
+	}
+	return key
+}
+
+// isBareKeyChar returns whether the specified character may appear in an unquoted key.
+func isBareKeyChar(r rune) bool {
+	return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-')
+}
+
+// formatInt returns the representation of the specified integer.
+// Sizes which are a multiple of a kilobyte, megabyte or gigabyte are written using the matching suffix.
+func formatInt(n int64, size bool) string {
+	if size && n > 0 {
+		for _, s := range sizeSuffixes {
+			if n%s.bytes == 0 {
+				return strconv.FormatInt(n/s.bytes, 10) + s.suffix
+			}
+		}
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// quote returns the specified string as a double-quoted string, using only the escape sequences understood by the NATS server.
+// Strings are always quoted, including durations, as the NATS server reads unquoted values such as "2m" as sizes rather than durations.
+func quote(s string) string {
+	var buf bytes.Buffer
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if unicode.IsPrint(r) || r > 0xffff {
+				buf.WriteRune(r)
+			} else {
+				fmt.Fprintf(&buf, `\u%04x`, r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
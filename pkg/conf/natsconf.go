@@ -1,9 +1,8 @@
-// natsconf is a package for producing NATS config programmatically.
+// natsconf is a package for producing and reading NATS config programmatically.
 package natsconf
 
 import (
-	"bytes"
-	"encoding/json"
+	"reflect"
 )
 
 type ServerConfig struct {
@@ -16,81 +15,161 @@ type ServerConfig struct {
 	Debug            bool                 `json:"debug,omitempty"`
 	Trace            bool                 `json:"trace,omitempty"`
 	Logtime          bool                 `json:"logtime"`
-	WriteDeadline    string               `json:"write_deadline,omitempty"`
+	WriteDeadline    string               `json:"write_deadline,omitempty" conf:"duration"`
 	MaxConnections   int                  `json:"max_connections,omitempty"`
-	MaxControlLine   int                  `json:"max_control_line,omitempty"`
-	MaxPayload       int                  `json:"max_payload,omitempty"`
-	MaxPending       int                  `json:"max_pending,omitempty"`
+	MaxControlLine   int                  `json:"max_control_line,omitempty" conf:"size"`
+	MaxPayload       int                  `json:"max_payload,omitempty" conf:"size"`
+	MaxPending       int                  `json:"max_pending,omitempty" conf:"size"`
 	MaxSubscriptions int                  `json:"max_subscriptions,omitempty"`
 	Authorization    *AuthorizationConfig `json:"authorization,omitempty"`
-	LameDuckDuration string               `json:"lame_duck_duration,omitempty"`
-	Include          string               `json:"include,omitempty"`
+	Accounts         map[string]*Account  `json:"accounts,omitempty"`
+	SystemAccount    string               `json:"system_account,omitempty"`
+	LameDuckDuration string               `json:"lame_duck_duration,omitempty" conf:"duration"`
+	Include          string               `json:"include,omitempty" conf:"include"`
+
+	// Extra holds the keys which have no matching field, such as
+	// settings which nats-operator doesn't manage. These are written
+	// after all other keys, in lexicographical order.
+	Extra map[string]interface{} `json:"-" conf:"extra"`
 }
 
 type ClusterConfig struct {
-	Port          int                  `json:"port,omitempty"`
-	Routes        []string             `json:"routes,omitempty"`
-	TLS           *TLSConfig           `json:"tls,omitempty"`
-	Authorization *AuthorizationConfig `json:"authorization,omitempty"`
+	Port          int                    `json:"port,omitempty"`
+	Routes        []string               `json:"routes,omitempty"`
+	TLS           *TLSConfig             `json:"tls,omitempty"`
+	Authorization *AuthorizationConfig   `json:"authorization,omitempty"`
+	Extra         map[string]interface{} `json:"-" conf:"extra"`
 }
 
 type TLSConfig struct {
-	CAFile           string   `json:"ca_file,omitempty"`
-	CertFile         string   `json:"cert_file,omitempty"`
-	KeyFile          string   `json:"key_file,omitempty"`
-	Verify           bool     `json:"verify,omitempty"`
-	CipherSuites     []string `json:"cipher_suites,omitempty"`
-	CurvePreferences []string `json:"curve_preferences,omitempty"`
-	Timeout          float64  `json:"timeout,omitempty"`
-	VerifyAndMap     bool     `json:"verify_and_map,omitempty"`
+	CAFile           string                 `json:"ca_file,omitempty"`
+	CertFile         string                 `json:"cert_file,omitempty"`
+	KeyFile          string                 `json:"key_file,omitempty"`
+	Verify           bool                   `json:"verify,omitempty"`
+	CipherSuites     []string               `json:"cipher_suites,omitempty"`
+	CurvePreferences []string               `json:"curve_preferences,omitempty"`
+	Timeout          float64                `json:"timeout,omitempty"`
+	VerifyAndMap     bool                   `json:"verify_and_map,omitempty"`
+	Extra            map[string]interface{} `json:"-" conf:"extra"`
 }
 
 type AuthorizationConfig struct {
-	Username           string       `json:"username,omitempty"`
-	Password           string       `json:"password,omitempty"`
-	Token              string       `json:"token,omitempty"`
-	Timeout            int          `json:"timeout,omitempty"`
-	Users              []*User      `json:"users,omitempty"`
-	DefaultPermissions *Permissions `json:"default_permissions,omitempty"`
+	Username           string                 `json:"username,omitempty"`
+	Password           string                 `json:"password,omitempty"`
+	Token              string                 `json:"token,omitempty"`
+	Timeout            int                    `json:"timeout,omitempty"`
+	Users              []*User                `json:"users,omitempty"`
+	DefaultPermissions *Permissions           `json:"default_permissions,omitempty"`
+	Extra              map[string]interface{} `json:"-" conf:"extra"`
 }
 
 type User struct {
-	User        string       `json:"username,omitempty"`
-	Password    string       `json:"password,omitempty"`
-	Permissions *Permissions `json:"permissions,omitempty"`
+	User        string                 `json:"username,omitempty"`
+	Password    string                 `json:"password,omitempty"`
+	Permissions *Permissions           `json:"permissions,omitempty"`
+	Extra       map[string]interface{} `json:"-" conf:"extra"`
 }
 
 // Permissions are the allowed subjects on a per
 // publish or subscribe basis.
 type Permissions struct {
 	// Can be either a map with allow/deny or an array.
-	Publish   interface{} `json:"publish,omitempty"`
-	Subscribe interface{} `json:"subscribe,omitempty"`
+	Publish   interface{}            `json:"publish,omitempty"`
+	Subscribe interface{}            `json:"subscribe,omitempty"`
+	Extra     map[string]interface{} `json:"-" conf:"extra"`
+}
+
+// Account is an isolated subject namespace (NATS 2.x), along with the
+// users belonging to it and the subjects it shares with other accounts.
+type Account struct {
+	Users   []*User                `json:"users,omitempty"`
+	Exports []*Export              `json:"exports,omitempty"`
+	Imports []*Import              `json:"imports,omitempty"`
+	Extra   map[string]interface{} `json:"-" conf:"extra"`
+}
+
+// Export makes either a stream or a service of an account available
+// to the specified accounts, or to all accounts if none is specified.
+type Export struct {
+	Stream   string                 `json:"stream,omitempty"`
+	Service  string                 `json:"service,omitempty"`
+	Accounts []string               `json:"accounts,omitempty"`
+	Extra    map[string]interface{} `json:"-" conf:"extra"`
+}
+
+// Import brings either a stream or a service exported by another
+// account into an account. Streams may be imported under a prefix,
+// while services may be mapped to a different subject.
+type Import struct {
+	Stream  *ImportSource          `json:"stream,omitempty"`
+	Service *ImportSource          `json:"service,omitempty"`
+	Prefix  string                 `json:"prefix,omitempty"`
+	To      string                 `json:"to,omitempty"`
+	Extra   map[string]interface{} `json:"-" conf:"extra"`
+}
+
+// ImportSource is the account and subject from which an import is made.
+type ImportSource struct {
+	Account string                 `json:"account,omitempty"`
+	Subject string                 `json:"subject,omitempty"`
+	Extra   map[string]interface{} `json:"-" conf:"extra"`
 }
 
 // Marshal takes a server configuration and returns its
-// JSON representation in bytes.
+// representation in the NATS configuration format.
 func Marshal(conf *ServerConfig) ([]byte, error) {
-	buf := &bytes.Buffer{}
-	encoder := json.NewEncoder(buf)
-	encoder.SetEscapeHTML(false)
-	err := encoder.Encode(conf)
+	return MarshalWithComment(conf, "")
+}
+
+// MarshalWithComment is like Marshal, but starts the configuration
+// with the specified comment (if any).
+func MarshalWithComment(conf *ServerConfig, comment string) ([]byte, error) {
+	e := &encoder{}
+	if comment != "" {
+		e.writeComment(comment)
+		e.buf.WriteByte('\n')
+	}
+	if err := e.writeEntries(reflect.ValueOf(*conf), 0); err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
+
+// Unmarshal attempts to parse the specified byte array, in either the NATS
+// configuration format or JSON, as a ServerConfig object.
+// Keys without a matching field are kept in the Extra field of the enclosing
+// block. The path of an include directive is stored in the Include field, as
+// there is no file relative to which it could be resolved.
+func Unmarshal(conf []byte) (*ServerConfig, error) {
+	m, err := Parse(conf)
 	if err != nil {
 		return nil, err
 	}
-	buf2 := &bytes.Buffer{}
-	err = json.Indent(buf2, buf.Bytes(), "", "  ")
+	return fromMap(m)
+}
+
+// UnmarshalFile is like Unmarshal, but reads the specified file, resolving
+// include directives relative to the directory containing it.
+func UnmarshalFile(path string) (*ServerConfig, error) {
+	m, err := ParseFile(path)
 	if err != nil {
 		return nil, err
 	}
+	return fromMap(m)
+}
 
-	return buf2.Bytes(), nil
+// Merge deep-merges the specified configuration, as returned by Parse, on
+// top of the specified ServerConfig object. Blocks are merged recursively,
+// while arrays and any other values replace the existing ones.
+func Merge(conf *ServerConfig, overlay map[string]interface{}) error {
+	return decode(overlay, reflect.ValueOf(conf).Elem(), "")
 }
 
-// Unmarshal attempts to parse the specified byte array as JSON as a ServerConfig object.
-func Unmarshal(conf []byte) (*ServerConfig, error) {
+// fromMap converts the specified configuration, as returned by Parse, into a
+// ServerConfig object.
+func fromMap(m map[string]interface{}) (*ServerConfig, error) {
 	res := &ServerConfig{}
-	if err := json.Unmarshal(conf, res); err != nil {
+	if err := decode(m, reflect.ValueOf(res).Elem(), ""); err != nil {
 		return nil, err
 	}
 	return res, nil
@@ -1,145 +1,487 @@
 package natsconf
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
 
-func TestConfMarshal(t *testing.T) {
-	tests := []struct {
-		input  *ServerConfig
-		output string
-		err    error
-	}{
-		{
-			input: &ServerConfig{},
-			output: `{
-  "logtime": false
-}`,
-			err: nil,
+// confTests holds configurations along with their representation in the NATS configuration format.
+var confTests = []struct {
+	input  *ServerConfig
+	output string
+	err    error
+}{
+	{
+		input:  &ServerConfig{},
+		output: `logtime: false`,
+		err:    nil,
+	},
+	{
+		input: &ServerConfig{
+			HTTPPort: 8222,
 		},
-		{
-			input: &ServerConfig{
-				HTTPPort: 8222,
+		output: `http_port: 8222
+logtime: false`,
+		err: nil,
+	},
+	{
+		input: &ServerConfig{
+			Port: 4222,
+		},
+		output: `port: 4222
+logtime: false`,
+		err: nil,
+	},
+	{
+		input: &ServerConfig{
+			Port:     4222,
+			HTTPPort: 8222,
+		},
+		output: `port: 4222
+http_port: 8222
+logtime: false`,
+		err: nil,
+	},
+	{
+		input: &ServerConfig{
+			LameDuckDuration: "2m",
+			Logtime:          true,
+		},
+		output: `logtime: true
+lame_duck_duration: "2m"`,
+	},
+	{
+		input: &ServerConfig{
+			Port:     4222,
+			HTTPPort: 8222,
+			Cluster: &ClusterConfig{
+				Port: 6222,
 			},
-			output: `{
-  "http_port": 8222,
-  "logtime": false
-}`,
-			err: nil,
 		},
-		{
-			input: &ServerConfig{
-				Port: 4222,
+		output: `port: 4222
+http_port: 8222
+cluster {
+  port: 6222
+}
+logtime: false`,
+		err: nil,
+	},
+	{
+		input: &ServerConfig{
+			Port:     4222,
+			HTTPPort: 8222,
+			Cluster: &ClusterConfig{
+				Port: 6222,
+				Routes: []string{
+					"nats://nats-1.default.svc:6222",
+					"nats://nats-2.default.svc:6222",
+					"nats://nats-3.default.svc:6222",
+				},
 			},
-			output: `{
-  "port": 4222,
-  "logtime": false
-}`,
-			err: nil,
 		},
-		{
-			input: &ServerConfig{
-				Port:     4222,
-				HTTPPort: 8222,
+		output: `port: 4222
+http_port: 8222
+cluster {
+  port: 6222
+  routes: [
+    "nats://nats-1.default.svc:6222"
+    "nats://nats-2.default.svc:6222"
+    "nats://nats-3.default.svc:6222"
+  ]
+}
+logtime: false`,
+		err: nil,
+	},
+	{
+		input: &ServerConfig{
+			Port:     4222,
+			HTTPPort: 8222,
+			Debug:    true,
+			Trace:    true,
+			Cluster: &ClusterConfig{
+				Port: 6222,
+				Routes: []string{
+					"nats://nats-1.default.svc:6222",
+					"nats://nats-2.default.svc:6222",
+					"nats://nats-3.default.svc:6222",
+				},
 			},
-			output: `{
-  "port": 4222,
-  "http_port": 8222,
-  "logtime": false
-}`,
-			err: nil,
 		},
-		{
-			input: &ServerConfig{
-				LameDuckDuration: "2m",
-				Logtime:          true,
+		output: `port: 4222
+http_port: 8222
+cluster {
+  port: 6222
+  routes: [
+    "nats://nats-1.default.svc:6222"
+    "nats://nats-2.default.svc:6222"
+    "nats://nats-3.default.svc:6222"
+  ]
+}
+debug: true
+trace: true
+logtime: false`,
+		err: nil,
+	},
+	{
+		input: &ServerConfig{
+			Port:     4222,
+			HTTPPort: 8222,
+			Cluster: &ClusterConfig{
+				Port: 6222,
+				Routes: []string{
+					"nats://nats-1.default.svc:6222",
+					"nats://nats-2.default.svc:6222",
+					"nats://nats-3.default.svc:6222",
+				},
+				TLS: &TLSConfig{
+					CAFile:   "/etc/nats-tls/ca.pem",
+					CertFile: "/etc/nats-tls/server.pem",
+					KeyFile:  "/etc/nats-tls/server-key.pem",
+				},
 			},
-			output: `{
-  "logtime": true,
-  "lame_duck_duration": "2m"
-}`,
 		},
-		{
-			input: &ServerConfig{
-				Port:     4222,
-				HTTPPort: 8222,
-				Cluster: &ClusterConfig{
-					Port: 6222,
+		output: `port: 4222
+http_port: 8222
+cluster {
+  port: 6222
+  routes: [
+    "nats://nats-1.default.svc:6222"
+    "nats://nats-2.default.svc:6222"
+    "nats://nats-3.default.svc:6222"
+  ]
+  tls {
+    ca_file: "/etc/nats-tls/ca.pem"
+    cert_file: "/etc/nats-tls/server.pem"
+    key_file: "/etc/nats-tls/server-key.pem"
+  }
+}
+logtime: false`,
+		err: nil,
+	},
+	{
+		input: &ServerConfig{
+			Port:     4222,
+			HTTPPort: 8222,
+			Authorization: &AuthorizationConfig{
+				DefaultPermissions: &Permissions{
+					Publish:   []string{"PUBLISH.>"},
+					Subscribe: []string{"PUBLISH.*"},
 				},
 			},
-			output: `{
-  "port": 4222,
-  "http_port": 8222,
-  "cluster": {
-    "port": 6222
-  },
-  "logtime": false
+		},
+		output: `port: 4222
+http_port: 8222
+logtime: false
+authorization {
+  default_permissions {
+    publish: [
+      "PUBLISH.>"
+    ]
+    subscribe: [
+      "PUBLISH.*"
+    ]
+  }
 }`,
-			err: nil,
+		err: nil,
+	},
+	{
+		input: &ServerConfig{
+			Authorization: &AuthorizationConfig{
+				DefaultPermissions: &Permissions{
+					Publish: map[string][]string{
+						"allow": []string{"hello", "world"},
+						"deny":  []string{"foo.*", "bar.>"},
+					},
+					Subscribe: map[string][]string{
+						"allow": []string{"hi", "everyone"},
+					},
+				},
+			},
 		},
-		{
-			input: &ServerConfig{
-				Port:     4222,
-				HTTPPort: 8222,
-				Cluster: &ClusterConfig{
-					Port: 6222,
-					Routes: []string{
-						"nats://nats-1.default.svc:6222",
-						"nats://nats-2.default.svc:6222",
-						"nats://nats-3.default.svc:6222",
+		output: `logtime: false
+authorization {
+  default_permissions {
+    publish {
+      allow: [
+        "hello"
+        "world"
+      ]
+      deny: [
+        "foo.*"
+        "bar.>"
+      ]
+    }
+    subscribe {
+      allow: [
+        "hi"
+        "everyone"
+      ]
+    }
+  }
+}`,
+
+		err: nil,
+	},
+	{
+		input: &ServerConfig{
+			Port:           4222,
+			WriteDeadline:  "10s",
+			MaxControlLine: 4096,
+			MaxPayload:     1048576,
+			MaxPending:     1000,
+			Include:        "./advertise/client_advertise.conf",
+		},
+		output: `port: 4222
+logtime: false
+write_deadline: "10s"
+max_control_line: 4KB
+max_payload: 1MB
+max_pending: 1000
+include "./advertise/client_advertise.conf"`,
+		err: nil,
+	},
+	{
+		input: &ServerConfig{
+			Authorization: &AuthorizationConfig{
+				Users: []*User{
+					{
+						User:     "foo",
+						Password: "$2a$11$\"quoted\"\\",
+						Permissions: &Permissions{
+							Publish: []string{">"},
+						},
+					},
+					{
+						User:     "bar",
+						Password: "bar",
 					},
 				},
 			},
-			output: `{
+		},
+		output: `logtime: false
+authorization {
+  users: [
+    {
+      username: "foo"
+      password: "$2a$11$\"quoted\"\\"
+      permissions {
+        publish: [
+          ">"
+        ]
+      }
+    }
+    {
+      username: "bar"
+      password: "bar"
+    }
+  ]
+}`,
+		err: nil,
+	},
+	{
+		input: &ServerConfig{
+			Accounts: map[string]*Account{
+				"SYS": {
+					Users: []*User{
+						{User: "admin", Password: "admin"},
+					},
+				},
+				"B": {
+					Users: []*User{
+						{User: "bar", Password: "bar"},
+					},
+					Imports: []*Import{
+						{Stream: &ImportSource{Account: "A", Subject: "public.>"}, Prefix: "from_a"},
+						{Service: &ImportSource{Account: "A", Subject: "req.time"}, To: "time"},
+					},
+				},
+				"A": {
+					Users: []*User{
+						{User: "foo", Password: "foo"},
+					},
+					Exports: []*Export{
+						{Stream: "public.>"},
+						{Service: "req.time", Accounts: []string{"B"}},
+					},
+				},
+			},
+			SystemAccount: "SYS",
+		},
+		output: `logtime: false
+accounts {
+  A {
+    users: [
+      {
+        username: "foo"
+        password: "foo"
+      }
+    ]
+    exports: [
+      {
+        stream: "public.>"
+      }
+      {
+        service: "req.time"
+        accounts: [
+          "B"
+        ]
+      }
+    ]
+  }
+  B {
+    users: [
+      {
+        username: "bar"
+        password: "bar"
+      }
+    ]
+    imports: [
+      {
+        stream {
+          account: "A"
+          subject: "public.>"
+        }
+        prefix: "from_a"
+      }
+      {
+        service {
+          account: "A"
+          subject: "req.time"
+        }
+        to: "time"
+      }
+    ]
+  }
+  SYS {
+    users: [
+      {
+        username: "admin"
+        password: "admin"
+      }
+    ]
+  }
+}
+system_account: "SYS"`,
+		err: nil,
+	},
+}
+
+func TestConfMarshal(t *testing.T) {
+	for _, tt := range confTests {
+		t.Run("config", func(t *testing.T) {
+			res, err := Marshal(tt.input)
+			if err != nil && tt.err == nil {
+				t.Errorf("Error: %s", err)
+			}
+			o := strings.TrimSpace(string(res))
+			if o != tt.output {
+				t.Errorf("Expected %+v, got: %+v", tt.output, o)
+			}
+		})
+	}
+}
+
+func TestConfMarshalWithComment(t *testing.T) {
+	res, err := MarshalWithComment(&ServerConfig{Port: 4222}, "Generated by nats-operator.\nDo not edit.")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	expected := `# Generated by nats-operator.
+# Do not edit.
+
+port: 4222
+logtime: false
+`
+	if string(res) != expected {
+		t.Errorf("Expected %+v, got: %+v", expected, string(res))
+	}
+}
+
+func TestConfMarshalDurations(t *testing.T) {
+	conf := &ServerConfig{
+		WriteDeadline:    "1m30s",
+		LameDuckDuration: "2m",
+	}
+	res, err := Marshal(conf)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	// Durations must be quoted, as the NATS server reads unquoted durations in minutes as sizes.
+	expected := `logtime: false
+write_deadline: "1m30s"
+lame_duck_duration: "2m"`
+	if o := strings.TrimSpace(string(res)); o != expected {
+		t.Errorf("Expected %+v, got: %+v", expected, o)
+	}
+	parsed, err := Unmarshal(res)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if !reflect.DeepEqual(parsed, conf) {
+		t.Errorf("Expected %+v, got: %+v", conf, parsed)
+	}
+	unquoted, err := Unmarshal([]byte("lame_duck_duration: 2m"))
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if unquoted.LameDuckDuration == "2m" {
+		t.Errorf("Expected an unquoted duration in minutes to be read as a size, got: %q", unquoted.LameDuckDuration)
+	}
+}
+
+func TestConfUnmarshal(t *testing.T) {
+	tests := []struct {
+		input  string
+		output *ServerConfig
+	}{
+		{
+			// Configurations produced by previous versions of nats-operator.
+			input: `{
   "port": 4222,
   "http_port": 8222,
   "cluster": {
     "port": 6222,
     "routes": [
       "nats://nats-1.default.svc:6222",
-      "nats://nats-2.default.svc:6222",
-      "nats://nats-3.default.svc:6222"
+      "nats://nats-2.default.svc:6222"
     ]
   },
-  "logtime": false
+  "logtime": true,
+  "max_payload": 1048576
 }`,
-			err: nil,
-		},
-		{
-			input: &ServerConfig{
+			output: &ServerConfig{
 				Port:     4222,
 				HTTPPort: 8222,
-				Debug:    true,
-				Trace:    true,
 				Cluster: &ClusterConfig{
 					Port: 6222,
 					Routes: []string{
 						"nats://nats-1.default.svc:6222",
 						"nats://nats-2.default.svc:6222",
-						"nats://nats-3.default.svc:6222",
 					},
 				},
+				Logtime:    true,
+				MaxPayload: 1048576,
 			},
-			output: `{
-  "port": 4222,
-  "http_port": 8222,
-  "cluster": {
-    "port": 6222,
-    "routes": [
-      "nats://nats-1.default.svc:6222",
-      "nats://nats-2.default.svc:6222",
-      "nats://nats-3.default.svc:6222"
-    ]
-  },
-  "debug": true,
-  "trace": true,
-  "logtime": false
-}`,
-			err: nil,
 		},
 		{
-			input: &ServerConfig{
+			input: `# Comments are ignored.
+port = 4222
+http_port 8222 // So are trailing ones.
+cluster {
+  port: 6222, routes: [nats://nats-1.default.svc:6222, 'nats://nats-2.default.svc:6222']
+}
+logtime: yes
+max_payload: 1MB
+max_pending: 2K
+lame_duck_duration: "30s"
+tls { timeout: 0.5 }
+include ./advertise/client_advertise.conf
+`,
+			output: &ServerConfig{
 				Port:     4222,
 				HTTPPort: 8222,
 				Cluster: &ClusterConfig{
@@ -147,110 +489,43 @@ func TestConfMarshal(t *testing.T) {
 					Routes: []string{
 						"nats://nats-1.default.svc:6222",
 						"nats://nats-2.default.svc:6222",
-						"nats://nats-3.default.svc:6222",
-					},
-					TLS: &TLSConfig{
-						CAFile:   "/etc/nats-tls/ca.pem",
-						CertFile: "/etc/nats-tls/server.pem",
-						KeyFile:  "/etc/nats-tls/server-key.pem",
 					},
 				},
-			},
-			output: `{
-  "port": 4222,
-  "http_port": 8222,
-  "cluster": {
-    "port": 6222,
-    "routes": [
-      "nats://nats-1.default.svc:6222",
-      "nats://nats-2.default.svc:6222",
-      "nats://nats-3.default.svc:6222"
-    ],
-    "tls": {
-      "ca_file": "/etc/nats-tls/ca.pem",
-      "cert_file": "/etc/nats-tls/server.pem",
-      "key_file": "/etc/nats-tls/server-key.pem"
-    }
-  },
-  "logtime": false
-}`,
-			err: nil,
-		},
-		{
-			input: &ServerConfig{
-				Port:     4222,
-				HTTPPort: 8222,
-				Authorization: &AuthorizationConfig{
-					DefaultPermissions: &Permissions{
-						Publish:   []string{"PUBLISH.>"},
-						Subscribe: []string{"PUBLISH.*"},
-					},
-				},
-			},
-			output: `{
-  "port": 4222,
-  "http_port": 8222,
-  "logtime": false,
-  "authorization": {
-    "default_permissions": {
-      "publish": [
-        "PUBLISH.>"
-      ],
-      "subscribe": [
-        "PUBLISH.*"
-      ]
-    }
-  }
-}`,
-			err: nil,
-		},
-		{
-			input: &ServerConfig{
-				Authorization: &AuthorizationConfig{
-					DefaultPermissions: &Permissions{
-						Publish: map[string][]string{
-							"allow": []string{"hello", "world"},
-							"deny":  []string{"foo.*", "bar.>"},
-						},
-						Subscribe: map[string][]string{
-							"allow": []string{"hi", "everyone"},
-						},
-					},
+				Logtime:          true,
+				MaxPayload:       1048576,
+				MaxPending:       2000,
+				LameDuckDuration: "30s",
+				TLS: &TLSConfig{
+					Timeout: 0.5,
 				},
+				Include: "./advertise/client_advertise.conf",
 			},
-			output: `{
-  "logtime": false,
-  "authorization": {
-    "default_permissions": {
-      "publish": {
-        "allow": [
-          "hello",
-          "world"
-        ],
-        "deny": [
-          "foo.*",
-          "bar.>"
-        ]
-      },
-      "subscribe": {
-        "allow": [
-          "hi",
-          "everyone"
-        ]
-      }
-    }
-  }
-}`,
-
-			err: nil,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run("config", func(t *testing.T) {
-			res, err := Marshal(tt.input)
-			if err != nil && tt.err == nil {
-				t.Errorf("Error: %s", err)
+			res, err := Unmarshal([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("Error: %s", err)
+			}
+			if !reflect.DeepEqual(res, tt.output) {
+				t.Errorf("Expected %+v, got: %+v", tt.output, res)
+			}
+		})
+	}
+}
+
+func TestConfRoundTrip(t *testing.T) {
+	for _, tt := range confTests {
+		t.Run("config", func(t *testing.T) {
+			conf, err := Unmarshal([]byte(tt.output))
+			if err != nil {
+				t.Fatalf("Error: %s", err)
+			}
+			res, err := Marshal(conf)
+			if err != nil {
+				t.Fatalf("Error: %s", err)
 			}
 			o := strings.TrimSpace(string(res))
 			if o != tt.output {
@@ -259,3 +534,297 @@ func TestConfMarshal(t *testing.T) {
 		})
 	}
 }
+
+func TestConfUnmarshalErrors(t *testing.T) {
+	tests := []string{
+		"port: ",
+		"cluster {\n  port: 6222\n",
+		"routes: [\"nats://nats-1.default.svc:6222\"",
+		"host: \"unterminated",
+		"host: \"invalid \\x escape\"",
+		"{\"port\": 4222} extra",
+		"port: \"4222\"",
+		"cluster: [6222]",
+		"debug: 1",
+		"write_deadline: \"ten seconds\"",
+		"port: $UNDEFINED_NATS_CONF_VARIABLE",
+		"include a.conf\ninclude b.conf",
+	}
+	for _, input := range tests {
+		if _, err := Unmarshal([]byte(input)); err == nil {
+			t.Errorf("Expected error when parsing %q", input)
+		}
+	}
+}
+
+func TestConfUnmarshalExtra(t *testing.T) {
+	input := `port: 4222
+server_name: "nats-1"
+write_deadline: 10
+cluster {
+  port: 6222
+  no_advertise: true
+}
+accounts {
+  A {
+    users: [
+      {
+        user: "a"
+        password: "a"
+      }
+    ]
+  }
+}
+`
+	conf, err := Unmarshal([]byte(input))
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if conf.Port != 4222 || conf.WriteDeadline != "10s" || conf.Cluster == nil || conf.Cluster.Port != 6222 {
+		t.Errorf("Unexpected configuration: %+v", conf)
+	}
+	if conf.Extra["server_name"] != "nats-1" || conf.Cluster.Extra["no_advertise"] != true {
+		t.Errorf("Expected unknown keys to be kept, got: %+v and %+v", conf.Extra, conf.Cluster.Extra)
+	}
+
+	// Unknown keys are written after all other keys, in lexicographical order.
+	res, err := Marshal(conf)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	expected := `port: 4222
+cluster {
+  port: 6222
+  no_advertise: true
+}
+logtime: false
+write_deadline: "10s"
+accounts {
+  A {
+    users: [
+      {
+        password: "a"
+        user: "a"
+      }
+    ]
+  }
+}
+server_name: "nats-1"
+`
+	if string(res) != expected {
+		t.Errorf("Expected %+v, got: %+v", expected, string(res))
+	}
+}
+
+func TestConfParseVariables(t *testing.T) {
+	os.Setenv("NATS_CONF_TEST_PASSWORD", "s3cr3t")
+	defer os.Unsetenv("NATS_CONF_TEST_PASSWORD")
+
+	input := `
+TIMEOUT: 2
+user = {user: "admin", password: $NATS_CONF_TEST_PASSWORD}
+authorization {
+  TIMEOUT: 5
+  timeout: $TIMEOUT
+  users: [$user, {user: "bcrypt", password: $2a$11$W2zko751KUvVy59mUTWmpOdWjpEm5qhcCZRd05GjI/sSOT.xtiHyG}]
+}
+cluster {
+  timeout: $TIMEOUT
+  literal: "$TIMEOUT"
+}
+`
+	res, err := Parse([]byte(input))
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	expected := map[string]interface{}{
+		"TIMEOUT": int64(2),
+		"user": map[string]interface{}{
+			"user":     "admin",
+			"password": "s3cr3t",
+		},
+		"authorization": map[string]interface{}{
+			"TIMEOUT": int64(5),
+			"timeout": int64(5),
+			"users": []interface{}{
+				map[string]interface{}{
+					"user":     "admin",
+					"password": "s3cr3t",
+				},
+				map[string]interface{}{
+					"user":     "bcrypt",
+					"password": "$2a$11$W2zko751KUvVy59mUTWmpOdWjpEm5qhcCZRd05GjI/sSOT.xtiHyG",
+				},
+			},
+		},
+		"cluster": map[string]interface{}{
+			"timeout": int64(2),
+			"literal": "$TIMEOUT",
+		},
+	}
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("Expected %+v, got: %+v", expected, res)
+	}
+}
+
+func TestConfParseFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "natsconf-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"nats.conf": `port: 4222
+include ./advertise/client_advertise.conf
+authorization {
+  include "auth.conf"
+}
+`,
+		"advertise/client_advertise.conf": `client_advertise = "10.0.0.1:4222"
+`,
+		"auth.conf": `# Users are defined separately.
+users: [{username: $TEST_USER_NAME, password: "bar"}]
+TEST_USER_NAME: "ignored"
+`,
+		"loop.conf": `include loop.conf
+`,
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Variables defined in the including file are visible from included files.
+	if _, err := UnmarshalFile(filepath.Join(dir, "nats.conf")); err == nil {
+		t.Errorf("Expected error when referencing a variable defined after its use")
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "nats.conf"), []byte("TEST_USER_NAME: \"foo\"\n"+files["nats.conf"]), 0644); err != nil {
+		t.Fatal(err)
+	}
+	conf, err := UnmarshalFile(filepath.Join(dir, "nats.conf"))
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	expected := &ServerConfig{
+		Port: 4222,
+		Authorization: &AuthorizationConfig{
+			Users: []*User{
+				{User: "foo", Password: "bar"},
+			},
+			Extra: map[string]interface{}{
+				"TEST_USER_NAME": "ignored",
+			},
+		},
+		Extra: map[string]interface{}{
+			"TEST_USER_NAME":   "foo",
+			"client_advertise": "10.0.0.1:4222",
+		},
+	}
+	if !reflect.DeepEqual(conf, expected) {
+		t.Errorf("Expected %+v, got: %+v", expected, conf)
+	}
+
+	if _, err := ParseFile(filepath.Join(dir, "loop.conf")); err == nil {
+		t.Errorf("Expected error when parsing circular includes")
+	}
+}
+
+func TestConfMerge(t *testing.T) {
+	conf := &ServerConfig{
+		Port: 4222,
+		Cluster: &ClusterConfig{
+			Port:   6222,
+			Routes: []string{"nats://nats-1.default.svc:6222"},
+		},
+		Logtime: true,
+		Extra: map[string]interface{}{
+			"websocket": map[string]interface{}{
+				"port":   int64(8080),
+				"no_tls": true,
+			},
+		},
+	}
+	overlay, err := Parse([]byte(`
+debug: true
+ping_interval: "1m"
+cluster {
+  no_advertise: true
+  tls { timeout: 2 }
+}
+websocket {
+  port: 8443
+}
+`))
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if err := Merge(conf, overlay); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	expected := &ServerConfig{
+		Port: 4222,
+		Cluster: &ClusterConfig{
+			Port:   6222,
+			Routes: []string{"nats://nats-1.default.svc:6222"},
+			TLS: &TLSConfig{
+				Timeout: 2,
+			},
+			Extra: map[string]interface{}{
+				"no_advertise": true,
+			},
+		},
+		Debug:   true,
+		Logtime: true,
+		Extra: map[string]interface{}{
+			"ping_interval": "1m",
+			"websocket": map[string]interface{}{
+				"port":   int64(8443),
+				"no_tls": true,
+			},
+		},
+	}
+	if !reflect.DeepEqual(conf, expected) {
+		t.Errorf("Expected %+v, got: %+v", expected, conf)
+	}
+
+	// Accounts which are already present are merged into, while new ones are added.
+	conf = &ServerConfig{
+		Accounts: map[string]*Account{
+			"A": {Users: []*User{{User: "foo", Password: "foo"}}},
+		},
+	}
+	overlay, err = Parse([]byte(`
+accounts {
+  A { exports: [{ stream: "public.>" }] }
+  B { users: [{ username: "bar", password: "bar" }] }
+}
+`))
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if err := Merge(conf, overlay); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	expected = &ServerConfig{
+		Accounts: map[string]*Account{
+			"A": {
+				Users:   []*User{{User: "foo", Password: "foo"}},
+				Exports: []*Export{{Stream: "public.>"}},
+			},
+			"B": {Users: []*User{{User: "bar", Password: "bar"}}},
+		},
+	}
+	if !reflect.DeepEqual(conf, expected) {
+		t.Errorf("Expected %+v, got: %+v", expected, conf)
+	}
+
+	if err := Merge(conf, map[string]interface{}{"cluster": "nats-1"}); err == nil {
+		t.Errorf("Expected error when merging a value of the wrong type")
+	}
+}
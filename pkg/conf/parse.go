@@ -0,0 +1,434 @@
+package natsconf
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// includeKey is the keyword introducing include directives, under which these are recorded when they cannot be resolved.
+	includeKey = "include"
+	// maxIncludeDepth is the maximum nesting level of included files, past which includes are assumed to be circular.
+	maxIncludeDepth = 10
+)
+
+// parser reads a configuration in the NATS configuration format into a tree of maps, slices and scalars.
+// As the format is a superset of JSON, configurations produced by previous versions of nats-operator can be read as well.
+type parser struct {
+	// data is the configuration being read.
+	data string
+	// pos is the offset of the next character to be read.
+	pos int
+	// line is the line of the next character to be read.
+	line int
+	// file is the path to the file being read, if any.
+	// Include directives are only resolved when reading a file, relative to the directory containing it.
+	file string
+	// depth is the nesting level of the file being read.
+	depth int
+	// scopes holds the blocks enclosing the current position, innermost last, in which variables are looked up.
+	scopes []map[string]interface{}
+}
+
+// Parse reads the specified configuration into a tree of maps, slices and scalars, resolving variable references along the way.
+// Integers (including sizes such as "1MB") are returned as int64, and durations (such as "2m") as strings.
+// As there is no file relative to which included files could be found, include directives are kept under the "include" key of the enclosing block.
+func Parse(data []byte) (map[string]interface{}, error) {
+	p := &parser{data: string(data), line: 1}
+	return p.parse()
+}
+
+// ParseFile reads the specified configuration file into a tree of maps, slices and scalars, resolving variable references and include directives along the way.
+// The keys of included files are merged into the block containing the include directive.
+func ParseFile(path string) (map[string]interface{}, error) {
+	return parseFile(path, 0, nil)
+}
+
+// parseFile reads the specified configuration file at the specified nesting level, with the specified enclosing blocks.
+func parseFile(path string, depth int, scopes []map[string]interface{}) (map[string]interface{}, error) {
+	if depth > maxIncludeDepth {
+		return nil, fmt.Errorf("%s: too many nested includes", path)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{data: string(b), line: 1, file: path, depth: depth, scopes: scopes}
+	return p.parse()
+}
+
+// parse reads the whole configuration.
+func (p *parser) parse() (map[string]interface{}, error) {
+	res := make(map[string]interface{})
+	p.skipSpace(true)
+	// Configurations in JSON are wrapped in a single top-level block.
+	if p.peek() == '{' {
+		p.next()
+		if err := p.parseEntries(res, '}'); err != nil {
+			return nil, err
+		}
+		p.skipSpace(true)
+		if !p.eof() {
+			return nil, p.errorf("unexpected %q after top-level block", p.peek())
+		}
+		return res, nil
+	}
+	if err := p.parseEntries(res, 0); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// parseEntries reads key-value pairs into the specified block until the specified closing character (or the end of the configuration if it is 0) is found.
+func (p *parser) parseEntries(block map[string]interface{}, end rune) error {
+	p.scopes = append(p.scopes, block)
+	defer func() {
+		p.scopes = p.scopes[:len(p.scopes)-1]
+	}()
+
+	for {
+		p.skipSpace(true)
+		for p.peek() == ',' || p.peek() == ';' {
+			p.next()
+			p.skipSpace(true)
+		}
+		switch {
+		case p.eof() && end == 0:
+			return nil
+		case p.eof():
+			return p.errorf("unexpected end of configuration, expecting %q", end)
+		case end != 0 && p.peek() == end:
+			p.next()
+			return nil
+		}
+
+		quoted := p.peek() == '"' || p.peek() == '\''
+		key, err := p.parseKey()
+		if err != nil {
+			return err
+		}
+		p.skipSpace(false)
+		if key == includeKey && !quoted && p.peek() != ':' && p.peek() != '=' {
+			if err := p.parseInclude(block); err != nil {
+				return err
+			}
+			continue
+		}
+		if p.peek() == ':' || p.peek() == '=' {
+			p.next()
+			p.skipSpace(false)
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return fmt.Errorf("%v (in %q)", err, key)
+		}
+		block[key] = value
+	}
+}
+
+// parseInclude reads an include directive, whose keyword has already been read, and merges the keys of the included file into the specified block.
+// When not reading a file, the path of the included file is recorded under the "include" key instead.
+func (p *parser) parseInclude(block map[string]interface{}) error {
+	path := ""
+	if r := p.peek(); r == '"' || r == '\'' {
+		var err error
+		if path, err = p.parseQuoted(); err != nil {
+			return err
+		}
+	} else {
+		path = p.parseBare()
+	}
+	if path == "" {
+		return p.errorf("expecting the path of the file to include")
+	}
+
+	if p.file == "" {
+		if _, ok := block[includeKey]; ok {
+			return p.errorf("multiple include directives in the same block can only be resolved when reading a file")
+		}
+		block[includeKey] = path
+		return nil
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(p.file), path)
+	}
+	included, err := parseFile(path, p.depth+1, p.scopes)
+	if err != nil {
+		return p.errorf("failed to include %q: %v", path, err)
+	}
+	for k, v := range included {
+		block[k] = v
+	}
+	return nil
+}
+
+// parseKey reads a quoted or unquoted key.
+func (p *parser) parseKey() (string, error) {
+	if r := p.peek(); r == '"' || r == '\'' {
+		return p.parseQuoted()
+	}
+	start := p.pos
+	for !p.eof() {
+		r := p.peek()
+		if unicode.IsSpace(r) || r == ':' || r == '=' || r == '{' || r == '[' || r == '}' || r == ',' || r == ';' {
+			break
+		}
+		p.next()
+	}
+	if p.pos == start {
+		return "", p.errorf("expecting a key, found %q", p.peek())
+	}
+	return p.data[start:p.pos], nil
+}
+
+// parseValue reads a block, an array, a quoted string, a variable reference or an unquoted scalar.
+func (p *parser) parseValue() (interface{}, error) {
+	switch p.peek() {
+	case '{':
+		p.next()
+		block := make(map[string]interface{})
+		if err := p.parseEntries(block, '}'); err != nil {
+			return nil, err
+		}
+		return block, nil
+	case '[':
+		p.next()
+		return p.parseArray()
+	case '"', '\'':
+		return p.parseQuoted()
+	}
+	token := p.parseBare()
+	if token == "" {
+		return nil, p.errorf("expecting a value")
+	}
+	if isVariable(token) {
+		return p.lookup(token[1:])
+	}
+	return parseScalar(token), nil
+}
+
+// parseBare reads an unquoted value, which ends with the current line or at the next separator, closing character or comment.
+func (p *parser) parseBare() string {
+	start := p.pos
+	for !p.eof() {
+		r := p.peek()
+		if r == '\n' || r == ',' || r == ';' || r == '}' || r == ']' || r == '#' || p.hasPrefix(" //") || p.hasPrefix("\t//") {
+			break
+		}
+		p.next()
+	}
+	return strings.TrimSpace(p.data[start:p.pos])
+}
+
+// parseArray reads the elements of an array, whose opening bracket has already been read.
+func (p *parser) parseArray() ([]interface{}, error) {
+	res := make([]interface{}, 0)
+	for {
+		p.skipSpace(true)
+		for p.peek() == ',' {
+			p.next()
+			p.skipSpace(true)
+		}
+		if p.eof() {
+			return nil, p.errorf("unexpected end of configuration, expecting ']'")
+		}
+		if p.peek() == ']' {
+			p.next()
+			return res, nil
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, value)
+	}
+}
+
+// parseQuoted reads a single- or double-quoted string.
+// Escape sequences are only interpreted within double-quoted strings, and variables are never expanded.
+func (p *parser) parseQuoted() (string, error) {
+	delim := p.next()
+	var b strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorf("unterminated string")
+		}
+		r := p.next()
+		switch {
+		case r == delim:
+			return b.String(), nil
+		case r == '\n':
+			return "", p.errorf("unterminated string")
+		case r == '\\' && delim == '"':
+			if p.eof() {
+				return "", p.errorf("unterminated string")
+			}
+			switch e := p.next(); e {
+			case 'n':
+				b.WriteRune('\n')
+			case 't':
+				b.WriteRune('\t')
+			case 'r':
+				b.WriteRune('\r')
+			case '"', '\\', '/':
+				b.WriteRune(e)
+			case 'u':
+				if p.pos+4 > len(p.data) {
+					return "", p.errorf("invalid unicode escape sequence")
+				}
+				n, err := strconv.ParseUint(p.data[p.pos:p.pos+4], 16, 16)
+				if err != nil {
+					return "", p.errorf("invalid unicode escape sequence %q", p.data[p.pos:p.pos+4])
+				}
+				p.pos += 4
+				b.WriteRune(rune(n))
+			default:
+				return "", p.errorf("invalid escape sequence \\%c", e)
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+}
+
+// lookup returns the value of the specified variable, which is the value of the closest key of the same name in the enclosing blocks or, failing that, the value of the environment variable of the same name.
+func (p *parser) lookup(name string) (interface{}, error) {
+	for i := len(p.scopes) - 1; i >= 0; i-- {
+		if v, ok := p.scopes[i][name]; ok {
+			return v, nil
+		}
+	}
+	if v, ok := os.LookupEnv(name); ok {
+		return parseScalar(v), nil
+	}
+	return nil, p.errorf("variable %q is not defined", name)
+}
+
+// skipSpace skips whitespace and comments, as well as newlines if requested.
+func (p *parser) skipSpace(newlines bool) {
+	for !p.eof() {
+		r := p.peek()
+		switch {
+		case r == '\n' && !newlines:
+			return
+		case unicode.IsSpace(r):
+			p.next()
+		case r == '#' || p.hasPrefix("//"):
+			for !p.eof() && p.peek() != '\n' {
+				p.next()
+			}
+		default:
+			return
+		}
+	}
+}
+
+// peek returns the next character without consuming it, or 0 at the end of the configuration.
+func (p *parser) peek() rune {
+	if p.eof() {
+		return 0
+	}
+	r, _ := utf8.DecodeRuneInString(p.data[p.pos:])
+	return r
+}
+
+// next consumes and returns the next character.
+func (p *parser) next() rune {
+	r, n := utf8.DecodeRuneInString(p.data[p.pos:])
+	p.pos += n
+	if r == '\n' {
+		p.line++
+	}
+	return r
+}
+
+// hasPrefix returns whether the remainder of the configuration starts with the specified string.
+func (p *parser) hasPrefix(s string) bool {
+	return strings.HasPrefix(p.data[p.pos:], s)
+}
+
+// eof returns whether the whole configuration has been read.
+func (p *parser) eof() bool {
+	return p.pos >= len(p.data)
+}
+
+// errorf returns an error mentioning the current file (if any) and line.
+func (p *parser) errorf(format string, args ...interface{}) error {
+	if p.file != "" {
+		return fmt.Errorf("%s:%d: %s", p.file, p.line, fmt.Sprintf(format, args...))
+	}
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+// isVariable returns whether the specified unquoted value is a variable reference (e.g. "$PASSWORD").
+// Values containing characters which cannot appear in keys, such as bcrypt hashes (e.g. "$2a$11$..."), are not, so that hashed passwords can be left unquoted.
+func isVariable(token string) bool {
+	if len(token) < 2 || token[0] != '$' {
+		return false
+	}
+	for _, r := range token[1:] {
+		if !isBareKeyChar(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseScalar interprets an unquoted value as a boolean, an integer (possibly with a size suffix), a floating-point number, or a string otherwise.
+func parseScalar(token string) interface{} {
+	switch strings.ToLower(token) {
+	case "true", "yes", "on":
+		return true
+	case "false", "no", "off":
+		return false
+	}
+	if n, err := strconv.ParseInt(token, 10, 64); err == nil {
+		return n
+	}
+	if n, ok := parseSize(token); ok {
+		return n
+	}
+	if f, err := strconv.ParseFloat(token, 64); err == nil {
+		return f
+	}
+	return token
+}
+
+// parseSize interprets the specified token as an integer followed by a size suffix, as understood by the NATS server.
+// Suffixes ending in "b" (or "i" and "ib") are powers of 1024, and the others are powers of 1000 (e.g. "1KB" is 1024 and "1K" is 1000).
+// This means that unquoted durations in minutes (e.g. "2m") are read as sizes, as they are by the NATS server, and hence must be quoted.
+func parseSize(token string) (int64, bool) {
+	i := 0
+	for i < len(token) && (unicode.IsDigit(rune(token[i])) || (i == 0 && token[i] == '-')) {
+		i++
+	}
+	n, err := strconv.ParseInt(token[:i], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	suffix := strings.ToLower(token[i:])
+	if suffix == "" {
+		return 0, false
+	}
+	base := int64(1000)
+	switch {
+	case strings.HasSuffix(suffix, "ib"):
+		base, suffix = 1024, strings.TrimSuffix(suffix, "ib")
+	case strings.HasSuffix(suffix, "i"), strings.HasSuffix(suffix, "b"):
+		base, suffix = 1024, suffix[:len(suffix)-1]
+	}
+	exponent := strings.Index("kmgtp", suffix)
+	if len(suffix) != 1 || exponent < 0 {
+		return 0, false
+	}
+	for j := 0; j <= exponent; j++ {
+		n *= base
+	}
+	return n, true
+}
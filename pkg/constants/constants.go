@@ -30,6 +30,12 @@ const (
 	// MetricsPort is the port for the prometheus metrics endpoint.
 	MetricsPort = 7777
 
+	// ReadinessPort is the port on which the reloader sidecar serves the readiness check of the NATS server.
+	ReadinessPort = 8223
+
+	// ReadinessPath is the path on which the reloader sidecar serves the readiness check of the NATS server.
+	ReadinessPath = "/ready"
+
 	// ConnectRetries is the number of retries for an implicit route.
 	ConnectRetries = 10
 
@@ -63,6 +69,13 @@ const (
 	// PidFilePath is the location of the pid file.
 	PidFilePath = PidFileMountPath + "/" + PidFileName
 
+	// DataVolumeName is the name of the volume backed by the persistent volume claim of each member.
+	DataVolumeName = "data"
+
+	// DefaultDataMountPath is the default path on which the persistent volume
+	// of each member is mounted in the NATS container.
+	DefaultDataMountPath = "/data"
+
 	// ServerSecretVolumeName is the name of the volume used for the server certs.
 	ServerSecretVolumeName = "server-tls-certs"
 
@@ -91,6 +104,22 @@ const (
 	DefaultMetricsImage            = "synadia/prometheus-nats-exporter"
 	DefaultMetricsImageTag         = "0.2.0"
 	DefaultMetricsImagePullPolicy  = "IfNotPresent"
+	DefaultBootConfigImage         = "connecteverything/nats-boot-config"
+	DefaultBootConfigImageTag      = "0.5.2"
+
+	// DefaultLameDuckDurationSeconds is the default duration (in seconds) of the "lame duck" mode.
+	// https://github.com/nats-io/gnatsd/blob/master/server/const.go#L136-L138
+	DefaultLameDuckDurationSeconds = 120
+
+	// DefaultUpgradeFailureThreshold is the default number of upgraded pods that may fail before an upgrade is rolled back.
+	DefaultUpgradeFailureThreshold = 3
+	// DefaultUpgradeTimeoutSeconds is the default number of seconds an upgraded pod has to become ready before it is considered to have failed.
+	DefaultUpgradeTimeoutSeconds = 300
+
+	// DefaultMeshHealthPeriodSeconds is the default number of seconds between two consecutive checks of the route mesh.
+	DefaultMeshHealthPeriodSeconds = 30
+	// DefaultMeshHealthRestartAfterSeconds is the default number of seconds the route mesh must have been unhealthy for before a member is replaced.
+	DefaultMeshHealthRestartAfterSeconds = 120
 
 	// NatsBinaryPath is the path to the NATS binary inside the main container.
 	NatsBinaryPath = "/gnatsd"
@@ -29,8 +29,10 @@ import (
 	kubeinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	corev1listers "k8s.io/client-go/listers/core/v1"
+	policyv1beta1listers "k8s.io/client-go/listers/policy/v1beta1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 
 	"github.com/nats-io/nats-operator/pkg/apis/nats/v1alpha2"
 	natsclient "github.com/nats-io/nats-operator/pkg/client/clientset/versioned"
@@ -64,6 +66,8 @@ type Controller struct {
 	secretLister corev1listers.SecretLister
 	// serviceLister is able to list/get Service resources from a shared informer's store.
 	serviceLister corev1listers.ServiceLister
+	// podDisruptionBudgetLister is able to list/get PodDisruptionBudget resources from a shared informer's store.
+	podDisruptionBudgetLister policyv1beta1listers.PodDisruptionBudgetLister
 	// natsClusterLister is able to list/get NatsCluster resources from a shared informer's store.
 	natsClustersLister natslisters.NatsClusterLister
 	// natsServiceRoleLister is able to list/get NatsServiceRole resources from a shared informer's store.
@@ -84,6 +88,8 @@ type Config struct {
 	KubeConfig            *rest.Config
 	KubeExtCli            extsclient.Interface
 	OperatorCli           natsclient.Interface
+	// EventRecorder is used to record events concerning NatsCluster resources, so that these show up when describing them.
+	EventRecorder record.EventRecorder
 }
 
 func (c *Config) Validate() error {
@@ -115,7 +121,9 @@ func NewNatsClusterController(cfg Config) *Controller {
 	// Obtain references to shared informers for the required types.
 	podInformer := kubeInformerFactory.Core().V1().Pods()
 	secretInformer := kubeInformerFactory.Core().V1().Secrets()
+	configMapInformer := kubeInformerFactory.Core().V1().ConfigMaps()
 	serviceInformer := kubeInformerFactory.Core().V1().Services()
+	podDisruptionBudgetInformer := kubeInformerFactory.Policy().V1beta1().PodDisruptionBudgets()
 	natsClustersInformer := natsInformerFactory.Nats().V1alpha2().NatsClusters()
 	natsServiceRoleInformer := natsInformerFactory.Nats().V1alpha2().NatsServiceRoles()
 
@@ -123,27 +131,31 @@ func NewNatsClusterController(cfg Config) *Controller {
 	podLister := podInformer.Lister()
 	secretLister := secretInformer.Lister()
 	serviceLister := serviceInformer.Lister()
+	podDisruptionBudgetLister := podDisruptionBudgetInformer.Lister()
 	natsClustersLister := natsClustersInformer.Lister()
 	natsServiceRoleLister := natsServiceRoleInformer.Lister()
 
 	// Create a new instance of Controller that uses the lister above.
 	c := &Controller{
-		genericController:     newGenericController(v1alpha2.CRDResourceKind, natsClusterControllerThreadiness),
-		kubeInformerFactory:   kubeInformerFactory,
-		natsInformerFactory:   natsInformerFactory,
-		podLister:             podLister,
-		secretLister:          secretLister,
-		serviceLister:         serviceLister,
-		natsClustersLister:    natsClustersLister,
-		natsServiceRoleLister: natsServiceRoleLister,
-		logger:                logrus.WithField("pkg", "controller"),
-		Config:                cfg,
+		genericController:         newGenericController(v1alpha2.CRDResourceKind, natsClusterControllerThreadiness),
+		kubeInformerFactory:       kubeInformerFactory,
+		natsInformerFactory:       natsInformerFactory,
+		podLister:                 podLister,
+		secretLister:              secretLister,
+		serviceLister:             serviceLister,
+		podDisruptionBudgetLister: podDisruptionBudgetLister,
+		natsClustersLister:        natsClustersLister,
+		natsServiceRoleLister:     natsServiceRoleLister,
+		logger:                    logrus.WithField("pkg", "controller"),
+		Config:                    cfg,
 	}
 	// Make the controller wait for caches to sync.
 	c.hasSyncedFuncs = []cache.InformerSynced{
 		podInformer.Informer().HasSynced,
 		secretInformer.Informer().HasSynced,
+		configMapInformer.Informer().HasSynced,
 		serviceInformer.Informer().HasSynced,
+		podDisruptionBudgetInformer.Informer().HasSynced,
 		natsClustersInformer.Informer().HasSynced,
 		natsServiceRoleInformer.Informer().HasSynced,
 	}
@@ -167,9 +179,9 @@ func NewNatsClusterController(cfg Config) *Controller {
 			c.enqueue(obj)
 		},
 	})
-	// Also setup event handlers to inform us when related resources (secrets, services, pods ans NatsClusterRoles) change.
+	// Also setup event handlers to inform us when related resources (secrets, config maps, services, pods, pod disruption budgets and NatsServiceRoles) change.
 	// This allows us to react promptly to, e.g., deleted pods or edited secrets.
-	for _, inf := range []informer{podInformer, secretInformer, serviceInformer, natsServiceRoleInformer} {
+	for _, inf := range []informer{podInformer, secretInformer, configMapInformer, serviceInformer, podDisruptionBudgetInformer, natsServiceRoleInformer} {
 		inf.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
 			AddFunc: c.handleObject,
 			UpdateFunc: func(_, obj interface{}) {
@@ -198,6 +210,7 @@ func (c *Controller) processQueueItem(key string) error {
 		// The NatsCluster resource may no longer exist.
 		if kubernetesutil.IsKubernetesResourceNotFoundError(err) {
 			c.logger.Warnf("natscluster %q was deleted", key)
+			cluster.DeleteClusterMetrics(namespace, name)
 			return nil
 		}
 		return err
@@ -209,8 +222,16 @@ func (c *Controller) processQueueItem(key string) error {
 	newObj := natsCluster.DeepCopy()
 	newObj.TypeMeta.APIVersion = newObj.GetGroupVersionKind().GroupVersion().String()
 	newObj.TypeMeta.Kind = newObj.GetGroupVersionKind().Kind
-	newObj.Spec.Cleanup()
-	return cluster.New(c.makeClusterConfig(), newObj).Reconcile()
+	cl := cluster.New(c.makeClusterConfig(), newObj)
+	if err := cl.Reconcile(); err != nil {
+		clustersFailed.Inc()
+		return err
+	}
+	// Reconcile never blocks waiting for pods, so we must make sure the NatsCluster resource is processed again in case it is waiting for something that isn't signaled by an event.
+	if d := cl.RequeueAfter(); d > 0 {
+		c.enqueueAfter(key, d)
+	}
+	return nil
 }
 
 func (c *Controller) Run(ctx context.Context) error {
@@ -254,7 +275,7 @@ func (c *Controller) Run(ctx context.Context) error {
 // It does this by looking at the object's metadata.ownerReferences field for an appropriate OwnerReference.
 // It then enqueues that NatsCluster resource to be processed.
 // If the object does not have an appropriate OwnerReference, it may still be a NatsServiceRole that references the NatsCluster in its spec, so we check for that as well.
-// Finally, the object may be a Secret referenced by one or more NatsCluster resources.
+// Finally, the object may be a Secret or ConfigMap referenced by one or more NatsCluster resources.
 // In case the object doesn't match any of the conditions above, it is simply skipped.
 func (c *Controller) handleObject(obj interface{}) {
 	var (
@@ -300,7 +321,7 @@ func (c *Controller) handleObject(obj interface{}) {
 		return
 	}
 
-	// If the current resource is a Secret, we must check whether there are any NatsCluster resources that references it via ".spec.auth.clientsAuthSecret" and enqueue them.
+	// If the current resource is a Secret, we must check whether there are any NatsCluster resources that references it via ".spec.auth.clientsAuthSecret", ".spec.natsConfig.rawFrom" or ".spec.accounts" and enqueue them.
 	if object, ok := obj.(*v1.Secret); ok {
 		// List all NatsCluster resources in the same namespace as the current secret.
 		clusters, err := c.natsClustersLister.NatsClusters(object.Namespace).List(labels.Everything())
@@ -312,6 +333,29 @@ func (c *Controller) handleObject(obj interface{}) {
 		for _, cluster := range clusters {
 			if cluster.Spec.Auth != nil && cluster.Spec.Auth.ClientsAuthSecret == object.Name {
 				c.enqueue(cluster)
+				continue
+			}
+			if cluster.Spec.ServerConfig != nil && cluster.Spec.ServerConfig.RawFrom.ReferencesSecret(object.Name) {
+				c.enqueue(cluster)
+				continue
+			}
+			if cluster.Spec.ReferencesAccountSecret(object.Name) {
+				c.enqueue(cluster)
+			}
+		}
+		return
+	}
+
+	// If the current resource is a ConfigMap, we must check whether there are any NatsCluster resources that reference it via ".spec.natsConfig.rawFrom" and enqueue them.
+	if object, ok := obj.(*v1.ConfigMap); ok {
+		clusters, err := c.natsClustersLister.NatsClusters(object.Namespace).List(labels.Everything())
+		if err != nil {
+			runtime.HandleError(fmt.Errorf("failed to list natscluster resources"))
+			return
+		}
+		for _, cluster := range clusters {
+			if cluster.Spec.ServerConfig != nil && cluster.Spec.ServerConfig.RawFrom.ReferencesConfigMap(object.Name) {
+				c.enqueue(cluster)
 			}
 		}
 		return
@@ -320,14 +364,16 @@ func (c *Controller) handleObject(obj interface{}) {
 
 func (c *Controller) makeClusterConfig() cluster.Config {
 	return cluster.Config{
-		KubeCli:               c.KubeCli.CoreV1(),
-		OperatorCli:           c.OperatorCli.NatsV1alpha2(),
-		PodLister:             c.podLister,
-		SecretLister:          c.secretLister,
-		ServiceLister:         c.serviceLister,
-		NatsServiceRoleLister: c.natsServiceRoleLister,
-		KubeClient:            c.KubeCli,
-		KubeConfig:            c.KubeConfig,
-		NatsClient:            c.OperatorCli,
+		KubeCli:                   c.KubeCli.CoreV1(),
+		OperatorCli:               c.OperatorCli.NatsV1alpha2(),
+		PodLister:                 c.podLister,
+		SecretLister:              c.secretLister,
+		ServiceLister:             c.serviceLister,
+		PodDisruptionBudgetLister: c.podDisruptionBudgetLister,
+		NatsServiceRoleLister:     c.natsServiceRoleLister,
+		EventRecorder:             c.EventRecorder,
+		KubeClient:                c.KubeCli,
+		KubeConfig:                c.KubeConfig,
+		NatsClient:                c.OperatorCli,
 	}
 }
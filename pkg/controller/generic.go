@@ -16,6 +16,7 @@ package controller
 
 import (
 	"fmt"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/util/runtime"
@@ -123,3 +124,8 @@ func (c *genericController) enqueue(obj interface{}) {
 func (c *genericController) enqueueByCoordinates(namespace, name string) {
 	c.workqueue.AddRateLimited(fmt.Sprintf("%s/%s", namespace, name))
 }
+
+// enqueueAfter puts the specified "namespace/name" key onto the work queue after the specified amount of time has passed.
+func (c *genericController) enqueueAfter(key string, d time.Duration) {
+	c.workqueue.AddAfter(key, d)
+}
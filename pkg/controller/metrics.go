@@ -14,7 +14,10 @@
 
 package controller
 
-import "github.com/prometheus/client_golang/prometheus"
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/util/workqueue"
+)
 
 var (
 	clustersTotal = prometheus.NewGauge(prometheus.GaugeOpts{
@@ -53,10 +56,79 @@ var (
 	})
 )
 
+var (
+	workqueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "nats_operator",
+		Subsystem: "workqueue",
+		Name:      "depth",
+		Help:      "Current depth of the work queue",
+	}, []string{"name"})
+
+	workqueueAdds = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "nats_operator",
+		Subsystem: "workqueue",
+		Name:      "adds",
+		Help:      "Total number of items added to the work queue",
+	}, []string{"name"})
+
+	workqueueLatency = prometheus.NewSummaryVec(prometheus.SummaryOpts{
+		Namespace: "nats_operator",
+		Subsystem: "workqueue",
+		Name:      "queue_latency_microseconds",
+		Help:      "How long an item stays in the work queue before being processed",
+	}, []string{"name"})
+
+	workqueueWorkDuration = prometheus.NewSummaryVec(prometheus.SummaryOpts{
+		Namespace: "nats_operator",
+		Subsystem: "workqueue",
+		Name:      "work_duration_microseconds",
+		Help:      "How long processing an item from the work queue takes",
+	}, []string{"name"})
+
+	workqueueRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "nats_operator",
+		Subsystem: "workqueue",
+		Name:      "retries",
+		Help:      "Total number of retries handled by the work queue",
+	}, []string{"name"})
+)
+
 func init() {
 	prometheus.MustRegister(clustersTotal)
 	prometheus.MustRegister(clustersCreated)
 	prometheus.MustRegister(clustersDeleted)
 	prometheus.MustRegister(clustersModified)
 	prometheus.MustRegister(clustersFailed)
+	prometheus.MustRegister(workqueueDepth)
+	prometheus.MustRegister(workqueueAdds)
+	prometheus.MustRegister(workqueueLatency)
+	prometheus.MustRegister(workqueueWorkDuration)
+	prometheus.MustRegister(workqueueRetries)
+
+	// Make named work queues (such as the one used by genericController) report their metrics.
+	// This must happen before any work queue is created.
+	workqueue.SetProvider(workqueueMetricsProvider{})
+}
+
+// workqueueMetricsProvider implements workqueue.MetricsProvider by exposing work queue metrics to Prometheus.
+type workqueueMetricsProvider struct{}
+
+func (workqueueMetricsProvider) NewDepthMetric(name string) workqueue.GaugeMetric {
+	return workqueueDepth.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewAddsMetric(name string) workqueue.CounterMetric {
+	return workqueueAdds.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewLatencyMetric(name string) workqueue.SummaryMetric {
+	return workqueueLatency.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewWorkDurationMetric(name string) workqueue.SummaryMetric {
+	return workqueueWorkDuration.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewRetriesMetric(name string) workqueue.CounterMetric {
+	return workqueueRetries.WithLabelValues(name)
 }
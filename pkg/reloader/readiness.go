@@ -0,0 +1,218 @@
+package natsreloader
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	natsconf "github.com/nats-io/nats-operator/pkg/conf"
+)
+
+const (
+	// monitoringRequestTimeout is the maximum amount of time we wait for
+	// the monitoring endpoint of the server to respond.
+	monitoringRequestTimeout = 5 * time.Second
+
+	// readinessShutdownTimeout is the maximum amount of time we wait for
+	// in-flight readiness checks to be served when shutting down.
+	readinessShutdownTimeout = 5 * time.Second
+)
+
+// ReadinessConfig represents the configuration of the readiness check.
+type ReadinessConfig struct {
+	// ListenAddr is the address on which the readiness check is served.
+	ListenAddr string
+
+	// MonitoringURL is the base URL of the monitoring endpoint of the
+	// local NATS server (e.g. "http://localhost:8222").
+	MonitoringURL string
+
+	// ConfigFile is the NATS server config file from which the expected
+	// routes are read.
+	ConfigFile string
+
+	// Hostname is the hostname of the local NATS server, as found in the
+	// routes of the config file.
+	Hostname string
+}
+
+// ReadinessChecker reports the local NATS server as ready once it has
+// connected to the routes of all the other members of its cluster.
+type ReadinessChecker struct {
+	*ReadinessConfig
+
+	// client is the client used to query the monitoring endpoint.
+	client *http.Client
+
+	// meshJoined is set to 1 once the server has connected to all the
+	// expected routes. From then on only the responsiveness of the server
+	// is checked, so that members leaving the cluster (e.g. when scaling
+	// down) don't make the remaining ones unready.
+	meshJoined int32
+}
+
+// varz encapsulates a response from the "/varz" endpoint of the NATS monitoring API.
+type varz struct {
+	ServerID string `json:"server_id"`
+}
+
+// routez encapsulates a response from the "/routez" endpoint of the NATS monitoring API.
+type routez struct {
+	NumRoutes int `json:"num_routes"`
+}
+
+// NewReadinessChecker returns a configured readiness checker.
+func NewReadinessChecker(config *ReadinessConfig) *ReadinessChecker {
+	transport := &http.Transport{}
+	if strings.HasPrefix(config.MonitoringURL, "https://") {
+		// The certificate presented by the monitoring endpoint is not
+		// issued for "localhost", so it cannot be verified.
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &ReadinessChecker{
+		ReadinessConfig: config,
+		client: &http.Client{
+			Timeout:   monitoringRequestTimeout,
+			Transport: transport,
+		},
+	}
+}
+
+// Run serves the readiness check until the specified context is cancelled.
+func (c *ReadinessChecker) Run(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:    c.ListenAddr,
+		Handler: c,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, fn := context.WithTimeout(context.Background(), readinessShutdownTimeout)
+		defer fn()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Error shutting down readiness check: %s\n", err)
+		}
+	}()
+	log.Printf("Serving readiness check on %s\n", c.ListenAddr)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// ServeHTTP responds with "200 OK" if the server is ready, and with
+// "503 Service Unavailable" otherwise.
+func (c *ReadinessChecker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := c.Check(r.Context()); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// Check returns an error describing why the server is not ready, if that
+// is the case.
+func (c *ReadinessChecker) Check(ctx context.Context) error {
+	if err := c.get(ctx, "/varz", &varz{}); err != nil {
+		return fmt.Errorf("server is not responding: %s", err)
+	}
+	if atomic.LoadInt32(&c.meshJoined) == 1 {
+		return nil
+	}
+
+	expected, err := c.expectedRoutes()
+	if err != nil {
+		return err
+	}
+	r := &routez{}
+	if err := c.get(ctx, "/routez", r); err != nil {
+		return fmt.Errorf("failed to get routes: %s", err)
+	}
+	// Duplicate implicit routes may make the server report more routes
+	// than there are peers.
+	if r.NumRoutes < expected {
+		return fmt.Errorf("connected to %d out of %d routes", r.NumRoutes, expected)
+	}
+
+	if atomic.CompareAndSwapInt32(&c.meshJoined, 0, 1) {
+		log.Printf("Server connected to all %d expected routes\n", expected)
+	}
+	return nil
+}
+
+// expectedRoutes returns the number of routes in the config file that
+// point at other members of the cluster of the local server.
+// Routes to other clusters are not taken into account, as these may not
+// be reachable at all times.
+func (c *ReadinessChecker) expectedRoutes() (int, error) {
+	b, err := ioutil.ReadFile(c.ConfigFile)
+	if err != nil {
+		return 0, err
+	}
+	sconfig, err := natsconf.Unmarshal(b)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse config file: %s", err)
+	}
+	if sconfig.Cluster == nil {
+		return 0, nil
+	}
+
+	// Routes to members of the cluster are of the form
+	// "nats://<hostname>.<domain>:<port>", so we use the route to the
+	// local server to find the domain shared by all members.
+	hosts := make([]string, 0, len(sconfig.Cluster.Routes))
+	for _, route := range sconfig.Cluster.Routes {
+		u, err := url.Parse(route)
+		if err != nil {
+			continue
+		}
+		hosts = append(hosts, u.Hostname())
+	}
+	domain := ""
+	for _, host := range hosts {
+		if strings.HasPrefix(host, c.Hostname+".") {
+			domain = strings.TrimPrefix(host, c.Hostname)
+			break
+		}
+	}
+	if domain == "" {
+		return 0, fmt.Errorf("config file contains no route to %q yet", c.Hostname)
+	}
+
+	n := 0
+	for _, host := range hosts {
+		if host != c.Hostname+domain && strings.HasSuffix(host, domain) && !strings.Contains(strings.TrimSuffix(host, domain), ".") {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// get queries the specified path of the monitoring endpoint and decodes
+// the response into v.
+func (c *ReadinessChecker) get(ctx context.Context, path string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(c.MonitoringURL, "/")+path, nil)
+	if err != nil {
+		return err
+	}
+	res, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("got unexpected status code %d from %q", res.StatusCode, path)
+	}
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
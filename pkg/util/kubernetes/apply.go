@@ -0,0 +1,148 @@
+// Copyright 2019 The nats-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+)
+
+const (
+	// LastAppliedConfigAnnotationKey is the key of the annotation holding the state of an object owned by a NatsCluster resource as last applied by nats-operator.
+	// It allows for telling changes made by nats-operator apart from changes made by third parties when computing three-way patches.
+	LastAppliedConfigAnnotationKey = "nats.io/last-applied-configuration"
+)
+
+// SetLastAppliedConfig records the current state of the specified object in its "nats.io/last-applied-configuration" annotation.
+func SetLastAppliedConfig(obj metav1.Object) error {
+	b, err := serializeForApply(obj, false)
+	if err != nil {
+		return err
+	}
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string, 1)
+	}
+	annotations[LastAppliedConfigAnnotationKey] = string(b)
+	obj.SetAnnotations(annotations)
+	return nil
+}
+
+// CreateThreeWayPatch computes the strategic merge patch that brings current in line with desired, based on the state last applied to current.
+// Fields that are not managed by nats-operator (e.g. ones set by Kubernetes or by other controllers) are left untouched.
+// It also returns the (sorted) paths of the managed fields that have been changed by third parties since the state was last applied, if any.
+// The "nats.io/last-applied-configuration" annotation of desired is set as a side effect, so that it is included in the patch.
+func CreateThreeWayPatch(current, desired metav1.Object, datastruct interface{}) ([]byte, []string, error) {
+	if err := SetLastAppliedConfig(desired); err != nil {
+		return nil, nil, err
+	}
+	modified, err := serializeForApply(desired, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	currentData, err := json.Marshal(current)
+	if err != nil {
+		return nil, nil, err
+	}
+	meta, err := strategicpatch.NewPatchMetaFromStruct(datastruct)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Objects created by previous versions of nats-operator have no last-applied state, in which case no fields are removed and no drift is reported.
+	var original []byte
+	if v, ok := current.GetAnnotations()[LastAppliedConfigAnnotationKey]; ok {
+		original = []byte(v)
+	}
+	patch, err := strategicpatch.CreateThreeWayMergePatch(original, modified, currentData, meta, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	if original == nil {
+		return patch, nil, nil
+	}
+
+	// Computing the patch that would restore the last-applied state tells us which managed fields have been changed by third parties.
+	drift, err := strategicpatch.CreateThreeWayMergePatch(original, original, currentData, meta, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	fields, err := patchedFields(drift)
+	if err != nil {
+		return nil, nil, err
+	}
+	return patch, fields, nil
+}
+
+// IsEmptyPatch returns whether the specified patch leaves the target object unchanged.
+func IsEmptyPatch(patch []byte) bool {
+	return string(patch) == "{}"
+}
+
+// serializeForApply serializes the specified object, leaving out the fields which are never managed by nats-operator.
+// These are the status and the creation timestamp (which would otherwise be serialized as "null" and be removed by patches), as well as the last-applied state itself unless requested otherwise.
+func serializeForApply(obj metav1.Object, withLastApplied bool) ([]byte, error) {
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]interface{})
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	delete(m, "status")
+	if metadata, ok := m["metadata"].(map[string]interface{}); ok {
+		if metadata["creationTimestamp"] == nil {
+			delete(metadata, "creationTimestamp")
+		}
+		if annotations, ok := metadata["annotations"].(map[string]interface{}); ok && !withLastApplied {
+			delete(annotations, LastAppliedConfigAnnotationKey)
+			if len(annotations) == 0 {
+				delete(metadata, "annotations")
+			}
+		}
+	}
+	return json.Marshal(m)
+}
+
+// patchedFields returns the sorted paths of the fields changed by the specified strategic merge patch (e.g. "spec.selector.app").
+// Lists are reported as a whole, and patch directives (e.g. "$setElementOrder") are ignored.
+func patchedFields(patch []byte) ([]string, error) {
+	m := make(map[string]interface{})
+	if err := json.Unmarshal(patch, &m); err != nil {
+		return nil, err
+	}
+	res := make([]string, 0)
+	var walk func(prefix string, m map[string]interface{})
+	walk = func(prefix string, m map[string]interface{}) {
+		for k, v := range m {
+			if strings.HasPrefix(k, "$") {
+				continue
+			}
+			if child, ok := v.(map[string]interface{}); ok && len(child) > 0 {
+				walk(prefix+k+".", child)
+				continue
+			}
+			res = append(res, prefix+k)
+		}
+	}
+	walk("", m)
+	sort.Strings(res)
+	return res, nil
+}
@@ -0,0 +1,590 @@
+// Copyright 2019 The nats-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"github.com/nats-io/nats-operator/pkg/apis/nats/v1alpha2"
+	natsalphav2client "github.com/nats-io/nats-operator/pkg/client/clientset/versioned/typed/nats/v1alpha2"
+	"github.com/nats-io/nats-operator/pkg/conf"
+	"github.com/nats-io/nats-operator/pkg/constants"
+)
+
+// AuthData holds the authorization data which must be gathered from the Kubernetes API in order to render the configuration of a NATS cluster.
+type AuthData struct {
+	// ClientsAuth is the authorization configuration held in the secret referenced by ".spec.auth.clientsAuthSecret", if any.
+	ClientsAuth *natsconf.AuthorizationConfig
+	// ServiceRoleUsers are the users mapped to service accounts by the NatsServiceRole resources of the cluster, if ".spec.auth.enableServiceAccounts" is set.
+	ServiceRoleUsers []*natsconf.User
+	// AccountPasswords are the passwords of the users of the accounts in ".spec.accounts", keyed by username.
+	AccountPasswords map[string]string
+}
+
+// ConfigData holds all the data which must be read from the Kubernetes API in order to render the configuration of a NATS cluster.
+type ConfigData struct {
+	// Auth is the authorization data of the cluster.
+	Auth *AuthData
+	// RawConfig is the snippet of configuration to merge on top of the generated one, as returned by v1alpha2.ParseRawConfig, if any.
+	RawConfig map[string]interface{}
+}
+
+// RenderConfig renders the configuration of the NATS server for the specified NATS cluster, with routes to the specified pods (except the ones which have failed).
+// It doesn't talk to the Kubernetes API, so the data it requires must be read beforehand (see GetConfigData).
+func RenderConfig(cluster *v1alpha2.NatsCluster, pods []*v1.Pod, data *ConfigData) (*natsconf.ServerConfig, error) {
+	cs := cluster.Spec
+	if data == nil {
+		data = &ConfigData{}
+	}
+	sconfig := &natsconf.ServerConfig{
+		Port:     int(constants.ClientPort),
+		HTTPPort: int(constants.MonitoringPort),
+		Cluster: &natsconf.ClusterConfig{
+			Port:   int(constants.ClusterPort),
+			Routes: renderRoutes(cluster, pods),
+		},
+		Logtime: true,
+	}
+
+	if cs.ServerConfig != nil {
+		sconfig.Debug = cs.ServerConfig.Debug
+		sconfig.Trace = cs.ServerConfig.Trace
+		sconfig.WriteDeadline = cs.ServerConfig.WriteDeadline
+		sconfig.MaxConnections = cs.ServerConfig.MaxConnections
+		sconfig.MaxPayload = cs.ServerConfig.MaxPayload
+		sconfig.MaxPending = cs.ServerConfig.MaxPending
+		sconfig.MaxSubscriptions = cs.ServerConfig.MaxSubscriptions
+		sconfig.MaxControlLine = cs.ServerConfig.MaxControlLine
+		sconfig.Logtime = !cs.ServerConfig.DisableLogtime
+	}
+
+	// Observe .spec.lameDuckDurationSeconds if specified.
+	if cs.LameDuckDurationSeconds != nil {
+		sconfig.LameDuckDuration = fmt.Sprintf("%ds", *cs.LameDuckDurationSeconds)
+	}
+	if cs.Pod != nil && cs.Pod.AdvertiseExternalIP {
+		sconfig.Include = filepath.Join(".", constants.BootConfigFilePath)
+	}
+
+	addTLSConfig(sconfig, cs)
+	addAuthConfig(sconfig, cs, data.Auth)
+	if err := addAccountsConfig(sconfig, cs, data.Auth); err != nil {
+		return nil, err
+	}
+
+	// The snippet of configuration provided by the user is merged last, so that it can override anything but the keys managed by nats-operator.
+	if len(data.RawConfig) > 0 {
+		if err := natsconf.Merge(sconfig, data.RawConfig); err != nil {
+			return nil, fmt.Errorf("failed to merge raw config: %v", err)
+		}
+	}
+	return sconfig, nil
+}
+
+// renderRoutes returns the routes to the specified pods (except the ones which have failed), sorted by pod name, followed by the routes to the clusters listed in ".spec.extraRoutes".
+func renderRoutes(cluster *v1alpha2.NatsCluster, pods []*v1.Pod) []string {
+	sorted := make([]*v1.Pod, 0, len(pods))
+	for _, pod := range pods {
+		// Skip pods that have failed
+		if pod.Status.Phase != v1.PodFailed {
+			sorted = append(sorted, pod)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	routes := make([]string, 0, len(sorted)+len(cluster.Spec.ExtraRoutes))
+	for _, pod := range sorted {
+		route := fmt.Sprintf("nats://%s.%s.%s.svc:%d",
+			pod.Name, ManagementServiceName(cluster.Name), cluster.Namespace, constants.ClusterPort)
+		routes = append(routes, route)
+	}
+	for _, extraCluster := range cluster.Spec.ExtraRoutes {
+		switch {
+		case extraCluster.Route != "":
+			// If route is explicit just include as is.
+			routes = append(routes, extraCluster.Route)
+		case extraCluster.Cluster != "":
+			route := fmt.Sprintf("nats://%s:%d",
+				ManagementServiceName(extraCluster.Cluster),
+				constants.ClusterPort)
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
+// addTLSConfig fills in the TLS configuration to be used in the config map.
+func addTLSConfig(sconfig *natsconf.ServerConfig, cs v1alpha2.ClusterSpec) {
+	if cs.TLS == nil {
+		return
+	}
+
+	if cs.TLS.EnableHttps {
+		// Replace monitoring port with https one.
+		sconfig.HTTPSPort = int(constants.MonitoringPort)
+		sconfig.HTTPPort = 0
+	}
+
+	if cs.TLS.ServerSecret != "" {
+		sconfig.TLS = &natsconf.TLSConfig{
+			CAFile:   constants.ServerCertsMountPath + "/" + cs.TLS.ServerSecretCAFileName,
+			CertFile: constants.ServerCertsMountPath + "/" + cs.TLS.ServerSecretCertFileName,
+			KeyFile:  constants.ServerCertsMountPath + "/" + cs.TLS.ServerSecretKeyFileName,
+		}
+
+		if cs.TLS.ClientsTLSTimeout > 0 {
+			sconfig.TLS.Timeout = cs.TLS.ClientsTLSTimeout
+		}
+	}
+	if cs.TLS.RoutesSecret != "" {
+		sconfig.Cluster.TLS = &natsconf.TLSConfig{
+			CAFile:   constants.RoutesCertsMountPath + "/" + cs.TLS.RoutesSecretCAFileName,
+			CertFile: constants.RoutesCertsMountPath + "/" + cs.TLS.RoutesSecretCertFileName,
+			KeyFile:  constants.RoutesCertsMountPath + "/" + cs.TLS.RoutesSecretKeyFileName,
+		}
+		if cs.TLS.RoutesTLSTimeout > 0 {
+			sconfig.Cluster.TLS.Timeout = cs.TLS.RoutesTLSTimeout
+		}
+	}
+	// Mapping certificates to users requires TLS to be enabled for clients.
+	if cs.Auth != nil && cs.Auth.TLSVerifyAndMap && sconfig.TLS != nil {
+		sconfig.TLS.VerifyAndMap = true
+	}
+}
+
+// addAuthConfig fills in the authorization configuration, based on the specified authorization data.
+func addAuthConfig(sconfig *natsconf.ServerConfig, cs v1alpha2.ClusterSpec, authData *AuthData) {
+	if cs.Auth == nil || authData == nil {
+		return
+	}
+
+	if cs.Auth.EnableServiceAccounts {
+		// Expand authorization rules from the service account tokens.
+		sconfig.Authorization = &natsconf.AuthorizationConfig{
+			Users: authData.ServiceRoleUsers,
+		}
+	} else if cs.Auth.ClientsAuthSecret != "" && authData.ClientsAuth != nil {
+		// Copy the authorization configuration so that the authorization data is left untouched.
+		clientAuth := *authData.ClientsAuth
+		if cs.Auth.ClientsAuthTimeout > 0 {
+			clientAuth.Timeout = cs.Auth.ClientsAuthTimeout
+		}
+		sconfig.Authorization = &clientAuth
+	}
+}
+
+// addAccountsConfig fills in the accounts (NATS 2.x) and the system account, based on the specified authorization data.
+func addAccountsConfig(sconfig *natsconf.ServerConfig, cs v1alpha2.ClusterSpec, authData *AuthData) error {
+	if len(cs.Accounts) == 0 {
+		return nil
+	}
+
+	sconfig.Accounts = make(map[string]*natsconf.Account, len(cs.Accounts))
+	for _, account := range cs.Accounts {
+		res := &natsconf.Account{}
+		for _, user := range account.Users {
+			var password string
+			if authData != nil {
+				password = authData.AccountPasswords[user.Username]
+			}
+			if len(password) == 0 {
+				return fmt.Errorf("no password for user %q of account %q", user.Username, account.Name)
+			}
+			u := &natsconf.User{
+				User:     user.Username,
+				Password: password,
+			}
+			if user.Permissions != nil {
+				// Empty lists of subjects are left out, as these would otherwise deny everything.
+				u.Permissions = &natsconf.Permissions{}
+				if len(user.Permissions.Publish) > 0 {
+					u.Permissions.Publish = user.Permissions.Publish
+				}
+				if len(user.Permissions.Subscribe) > 0 {
+					u.Permissions.Subscribe = user.Permissions.Subscribe
+				}
+			}
+			res.Users = append(res.Users, u)
+		}
+		for _, export := range account.Exports {
+			res.Exports = append(res.Exports, &natsconf.Export{
+				Stream:   export.Stream,
+				Service:  export.Service,
+				Accounts: export.Accounts,
+			})
+		}
+		for _, imp := range account.Imports {
+			i := &natsconf.Import{
+				Prefix: imp.Prefix,
+				To:     imp.To,
+			}
+			if imp.Stream != nil {
+				i.Stream = &natsconf.ImportSource{Account: imp.Stream.Account, Subject: imp.Stream.Subject}
+			}
+			if imp.Service != nil {
+				i.Service = &natsconf.ImportSource{Account: imp.Service.Account, Subject: imp.Service.Subject}
+			}
+			res.Imports = append(res.Imports, i)
+		}
+		sconfig.Accounts[account.Name] = res
+	}
+	sconfig.SystemAccount = cs.SystemAccount
+	return nil
+}
+
+// GetConfigData reads all the data required in order to render the configuration of the specified NATS cluster.
+// It never writes to the Kubernetes API, so the tokens of the service accounts mapped by NatsServiceRole resources must be issued beforehand (see IssueServiceRoleTokens).
+func GetConfigData(
+	kubecli corev1client.CoreV1Interface,
+	operatorcli natsalphav2client.NatsV1alpha2Interface,
+	cluster *v1alpha2.NatsCluster,
+) (*ConfigData, error) {
+	authData, err := GetAuthData(kubecli, operatorcli, cluster)
+	if err != nil {
+		return nil, err
+	}
+	res := &ConfigData{Auth: authData}
+	raw, err := GetRawConfig(kubecli, cluster.Namespace, cluster.Spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get raw config: %v", err)
+	}
+	if len(raw) > 0 {
+		if res.RawConfig, err = v1alpha2.ParseRawConfig(raw); err != nil {
+			return nil, fmt.Errorf("invalid raw config: %v", err)
+		}
+	}
+	return res, nil
+}
+
+// GetAuthData reads the authorization data required in order to render the configuration of the specified NATS cluster.
+// The passwords of the users of the accounts in ".spec.accounts" are read from the secrets they reference.
+// When ".spec.auth.enableServiceAccounts" is set, NatsServiceRole resources whose token hasn't been issued yet (see IssueServiceRoleTokens) are skipped.
+func GetAuthData(
+	kubecli corev1client.CoreV1Interface,
+	operatorcli natsalphav2client.NatsV1alpha2Interface,
+	cluster *v1alpha2.NatsCluster,
+) (*AuthData, error) {
+	cs, ns, clusterName := cluster.Spec, cluster.Namespace, cluster.Name
+	res := &AuthData{}
+
+	// Read the passwords of the users of the accounts from the secrets they reference.
+	for _, account := range cs.Accounts {
+		for _, user := range account.Users {
+			if user.PasswordSecretRef == nil {
+				continue
+			}
+			ref := user.PasswordSecretRef
+			secret, err := kubecli.Secrets(ns).Get(ref.Name, metav1.GetOptions{})
+			if err != nil {
+				return nil, fmt.Errorf("failed to get password of user %q: %v", user.Username, err)
+			}
+			password, ok := secret.Data[ref.Key]
+			if !ok {
+				return nil, fmt.Errorf("failed to get password of user %q: secret %q has no key %q", user.Username, ref.Name, ref.Key)
+			}
+			if res.AccountPasswords == nil {
+				res.AccountPasswords = make(map[string]string)
+			}
+			res.AccountPasswords[user.Username] = string(password)
+		}
+	}
+
+	if cs.Auth == nil {
+		return res, nil
+	}
+
+	if cs.Auth.EnableServiceAccounts {
+		roles, err := listServiceRoles(operatorcli, ns, clusterName)
+		if err != nil {
+			return nil, err
+		}
+
+		users := make([]*natsconf.User, 0)
+		for _, role := range roles {
+			// We always get everything and apply, in case there is a diff
+			// then the reloader will apply them.
+			secret, err := kubecli.Secrets(ns).Get(serviceRoleTokenSecretName(role.Name, clusterName), metav1.GetOptions{})
+			if err != nil {
+				if apierrors.IsNotFound(err) {
+					// Skip since no token has been issued for the role yet.
+					continue
+				}
+				return nil, err
+			}
+			token, ok := secret.Data["token"]
+			if !ok {
+				continue
+			}
+			user := &natsconf.User{
+				User:     role.Name,
+				Password: string(token),
+				Permissions: &natsconf.Permissions{
+					Publish:   role.Spec.Permissions.Publish,
+					Subscribe: role.Spec.Permissions.Subscribe,
+				},
+			}
+			users = append(users, user)
+		}
+		res.ServiceRoleUsers = users
+	} else if cs.Auth.ClientsAuthSecret != "" {
+		// Authorization implementation using a secret with the explicit
+		// configuration of all the accounts from a cluster, cannot be
+		// used together with service accounts.
+		result, err := kubecli.Secrets(ns).Get(cs.Auth.ClientsAuthSecret, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, v := range result.Data {
+			if err := json.Unmarshal(v, &res.ClientsAuth); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	return res, nil
+}
+
+// IssueServiceRoleTokens issues a token for each service account mapped by a NatsServiceRole resource of the specified NATS cluster, unless one has already been issued.
+// Tokens are stored in secrets owned by the NatsServiceRole resources, from which GetAuthData reads them.
+// It is a no-op unless ".spec.auth.enableServiceAccounts" is set.
+func IssueServiceRoleTokens(
+	kubecli corev1client.CoreV1Interface,
+	operatorcli natsalphav2client.NatsV1alpha2Interface,
+	cluster *v1alpha2.NatsCluster,
+) error {
+	cs, ns, clusterName := cluster.Spec, cluster.Namespace, cluster.Name
+	if cs.Auth == nil || !cs.Auth.EnableServiceAccounts {
+		return nil
+	}
+
+	roles, err := listServiceRoles(operatorcli, ns, clusterName)
+	if err != nil {
+		return err
+	}
+
+	for _, role := range roles {
+		// Lookup for a ServiceAccount with the same name as the NatsServiceRole.
+		sa, err := kubecli.ServiceAccounts(ns).Get(role.Name, metav1.GetOptions{})
+		if err != nil {
+			// TODO: Collect created secrets when the service account no
+			// longer exists, currently only deleted when the NatsServiceRole
+			// is deleted since it is the owner of the object.
+
+			// Skip since cannot map unless valid service account is found.
+			continue
+		}
+
+		// TODO: Add support for expiration of the issued tokens.
+		tokenSecretName := serviceRoleTokenSecretName(role.Name, clusterName)
+		tokenSecret, err := kubecli.Secrets(ns).Get(tokenSecretName, metav1.GetOptions{})
+		if err == nil {
+			if _, ok := tokenSecret.Data["token"]; ok {
+				continue
+			}
+			// The token request failed after the secret was created, so the token is issued again for the existing secret.
+		} else {
+			if !apierrors.IsNotFound(err) {
+				return err
+			}
+
+			// Create the secret, then make a service token request, and finally
+			// update the secret with the token mapped to the service account.
+			tokenSecret = &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   tokenSecretName,
+					Labels: LabelsForCluster(clusterName),
+				},
+			}
+
+			// When the role that was mapped is deleted, then also delete the secret.
+			addOwnerRefToObject(tokenSecret.GetObjectMeta(), role.AsOwner())
+			tokenSecret, err = kubecli.Secrets(ns).Create(tokenSecret)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Issue token with audience set for the NATS cluster in this namespace only,
+		// this will prevent the token from being usable against the API Server.
+		ar := &authenticationv1.TokenRequest{
+			Spec: authenticationv1.TokenRequestSpec{
+				Audiences: []string{fmt.Sprintf("nats://%s.%s.svc", clusterName, ns)},
+
+				// Service Token will be valid for as long as the created secret exists.
+				BoundObjectRef: &authenticationv1.BoundObjectReference{
+					Kind:       "Secret",
+					APIVersion: "v1",
+					Name:       tokenSecret.Name,
+					UID:        tokenSecret.UID,
+				},
+			},
+		}
+		tr, err := kubecli.ServiceAccounts(ns).CreateToken(sa.Name, ar)
+		if err != nil {
+			return err
+		}
+
+		// Update secret with issued token.
+		tokenSecret.Data = map[string][]byte{
+			"token": []byte(tr.Status.Token),
+		}
+		if _, err := kubecli.Secrets(ns).Update(tokenSecret); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listServiceRoles returns the NatsServiceRole resources targeting the NATS cluster with the specified name.
+func listServiceRoles(operatorcli natsalphav2client.NatsV1alpha2Interface, ns, clusterName string) ([]v1alpha2.NatsServiceRole, error) {
+	roleSelector := map[string]string{
+		LabelClusterNameKey: clusterName,
+	}
+	roles, err := operatorcli.NatsServiceRoles(ns).List(metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(roleSelector).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return roles.Items, nil
+}
+
+// serviceRoleTokenSecretName returns the name of the secret holding the token issued for the NatsServiceRole with the specified name.
+func serviceRoleTokenSecretName(roleName, clusterName string) string {
+	return fmt.Sprintf("%s-%s-bound-token", roleName, clusterName)
+}
+
+// GetRawConfig returns the snippet of configuration specified in ".spec.natsConfig.raw" or referenced by ".spec.natsConfig.rawFrom", if any.
+// A missing ConfigMap, Secret or key is reported as an error unless the reference is marked as optional.
+func GetRawConfig(kubecli corev1client.CoreV1Interface, ns string, cs v1alpha2.ClusterSpec) (string, error) {
+	if cs.ServerConfig == nil {
+		return "", nil
+	}
+	if cs.ServerConfig.RawFrom == nil {
+		return cs.ServerConfig.Raw, nil
+	}
+	if ref := cs.ServerConfig.RawFrom.ConfigMapKeyRef; ref != nil {
+		cm, err := kubecli.ConfigMaps(ns).Get(ref.Name, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) && ref.Optional != nil && *ref.Optional {
+				return "", nil
+			}
+			return "", err
+		}
+		v, ok := cm.Data[ref.Key]
+		if !ok && (ref.Optional == nil || !*ref.Optional) {
+			return "", fmt.Errorf("configmap %q has no key %q", ref.Name, ref.Key)
+		}
+		return v, nil
+	}
+	if ref := cs.ServerConfig.RawFrom.SecretKeyRef; ref != nil {
+		secret, err := kubecli.Secrets(ns).Get(ref.Name, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) && ref.Optional != nil && *ref.Optional {
+				return "", nil
+			}
+			return "", err
+		}
+		v, ok := secret.Data[ref.Key]
+		if !ok && (ref.Optional == nil || !*ref.Optional) {
+			return "", fmt.Errorf("secret %q has no key %q", ref.Name, ref.Key)
+		}
+		return string(v), nil
+	}
+	return "", nil
+}
+
+// configComment returns the comment placed at the top of the configuration file of the NATS cluster with the specified name.
+func configComment(clusterName, ns string) string {
+	return fmt.Sprintf("Configuration of the %s/%s NATS cluster, generated by nats-operator.\nAny changes made to this file will be overwritten.", ns, clusterName)
+}
+
+// NewConfigSecret renders the secret holding the current configuration of the cluster,
+// such as the routes available in the cluster.
+func NewConfigSecret(
+	kubecli corev1client.CoreV1Interface,
+	operatorcli natsalphav2client.NatsV1alpha2Interface,
+	cluster *v1alpha2.NatsCluster,
+) (*v1.Secret, error) {
+	// List all available pods then generate the routes
+	// for the NATS cluster.
+	podList, err := kubecli.Pods(cluster.Namespace).List(ClusterListOpt(cluster.Name))
+	if err != nil {
+		return nil, err
+	}
+	pods := make([]*v1.Pod, 0, len(podList.Items))
+	for i := range podList.Items {
+		pods = append(pods, &podList.Items[i])
+	}
+	data, err := GetConfigData(kubecli, operatorcli, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	sconfig, err := RenderConfig(cluster, pods, data)
+	if err != nil {
+		return nil, err
+	}
+	rawConfig, err := natsconf.MarshalWithComment(sconfig, configComment(cluster.Name, cluster.Namespace))
+	if err != nil {
+		return nil, err
+	}
+
+	cm := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ConfigSecret(cluster.Name),
+			Namespace: cluster.Namespace,
+			Labels:    LabelsForCluster(cluster.Name),
+		},
+		Data: map[string][]byte{
+			constants.ConfigFileName: rawConfig,
+		},
+	}
+	addOwnerRefToObject(cm.GetObjectMeta(), cluster.AsOwner())
+	return cm, nil
+}
+
+// CreateConfigSecret creates the secret that contains the configuration file for a given NATS cluster.
+func CreateConfigSecret(kubecli corev1client.CoreV1Interface, operatorcli natsalphav2client.NatsV1alpha2Interface, cluster *v1alpha2.NatsCluster) error {
+	cm, err := NewConfigSecret(kubecli, operatorcli, cluster)
+	if err != nil {
+		return err
+	}
+	if err := SetLastAppliedConfig(cm); err != nil {
+		return err
+	}
+
+	_, err = kubecli.Secrets(cluster.Namespace).Create(cm)
+	if apierrors.IsAlreadyExists(err) {
+		// Skip in case it was created already and update instead
+		// with the latest configuration.
+		_, err = kubecli.Secrets(cluster.Namespace).Update(cm)
+		return err
+	}
+	return err
+}
@@ -0,0 +1,576 @@
+// Copyright 2019 The nats-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/nats-io/nats-operator/pkg/apis/nats/v1alpha2"
+	operatorfake "github.com/nats-io/nats-operator/pkg/client/clientset/versioned/fake"
+	"github.com/nats-io/nats-operator/pkg/conf"
+)
+
+// newTestPod returns a pod with the specified name and phase.
+func newTestPod(name string, phase v1.PodPhase) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status:     v1.PodStatus{Phase: phase},
+	}
+}
+
+func TestRenderConfig(t *testing.T) {
+	lameDuckDurationSeconds := int64(30)
+	clientsAuth := &natsconf.AuthorizationConfig{
+		Users: []*natsconf.User{
+			{User: "foo", Password: "bar"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		spec     v1alpha2.ClusterSpec
+		pods     []*v1.Pod
+		authData *AuthData
+		raw      string
+		output   string
+		err      string
+	}{
+		{
+			name: "defaults",
+			output: `port: 4222
+http_port: 8222
+cluster {
+  port: 6222
+}
+logtime: true`,
+		},
+		{
+			name: "server config",
+			spec: v1alpha2.ClusterSpec{
+				ServerConfig: &v1alpha2.ServerConfig{
+					Debug:            true,
+					Trace:            true,
+					WriteDeadline:    "5s",
+					MaxConnections:   100,
+					MaxPayload:       1 << 20,
+					MaxPending:       1 << 21,
+					MaxSubscriptions: 10,
+					MaxControlLine:   1024,
+					DisableLogtime:   true,
+				},
+			},
+			output: `port: 4222
+http_port: 8222
+cluster {
+  port: 6222
+}
+debug: true
+trace: true
+logtime: false
+write_deadline: "5s"
+max_connections: 100
+max_control_line: 1KB
+max_payload: 1MB
+max_pending: 2MB
+max_subscriptions: 10`,
+		},
+		{
+			name: "routes",
+			pods: []*v1.Pod{
+				newTestPod("nats-2", v1.PodRunning),
+				newTestPod("nats-3", v1.PodFailed),
+				newTestPod("nats-1", v1.PodPending),
+			},
+			spec: v1alpha2.ClusterSpec{
+				ExtraRoutes: []*v1alpha2.ExtraRoute{
+					{Route: "nats://nats.example.com:6222"},
+					{Cluster: "other"},
+					{},
+				},
+			},
+			output: `port: 4222
+http_port: 8222
+cluster {
+  port: 6222
+  routes: [
+    "nats://nats-1.nats-mgmt.ns.svc:6222"
+    "nats://nats-2.nats-mgmt.ns.svc:6222"
+    "nats://nats.example.com:6222"
+    "nats://other-mgmt:6222"
+  ]
+}
+logtime: true`,
+		},
+		{
+			name: "lame duck duration and external advertise",
+			spec: v1alpha2.ClusterSpec{
+				LameDuckDurationSeconds: &lameDuckDurationSeconds,
+				Pod: &v1alpha2.PodPolicy{
+					AdvertiseExternalIP: true,
+				},
+			},
+			output: `port: 4222
+http_port: 8222
+cluster {
+  port: 6222
+}
+logtime: true
+lame_duck_duration: "30s"
+include "advertise/client_advertise.conf"`,
+		},
+		{
+			name: "tls",
+			spec: v1alpha2.ClusterSpec{
+				TLS: &v1alpha2.TLSConfig{
+					ServerSecret:             "server-tls",
+					ServerSecretCAFileName:   "ca.pem",
+					ServerSecretCertFileName: "server.pem",
+					ServerSecretKeyFileName:  "server-key.pem",
+					RoutesSecret:             "routes-tls",
+					RoutesSecretCAFileName:   "ca.pem",
+					RoutesSecretCertFileName: "route.pem",
+					RoutesSecretKeyFileName:  "route-key.pem",
+					EnableHttps:              true,
+					ClientsTLSTimeout:        3,
+					RoutesTLSTimeout:         5,
+				},
+				Auth: &v1alpha2.AuthConfig{
+					TLSVerifyAndMap: true,
+				},
+			},
+			output: `port: 4222
+https_port: 8222
+cluster {
+  port: 6222
+  tls {
+    ca_file: "/etc/nats-routes-tls-certs/ca.pem"
+    cert_file: "/etc/nats-routes-tls-certs/route.pem"
+    key_file: "/etc/nats-routes-tls-certs/route-key.pem"
+    timeout: 5
+  }
+}
+tls {
+  ca_file: "/etc/nats-server-tls-certs/ca.pem"
+  cert_file: "/etc/nats-server-tls-certs/server.pem"
+  key_file: "/etc/nats-server-tls-certs/server-key.pem"
+  timeout: 3
+  verify_and_map: true
+}
+logtime: true`,
+		},
+		{
+			name: "verify and map without server tls",
+			spec: v1alpha2.ClusterSpec{
+				TLS: &v1alpha2.TLSConfig{},
+				Auth: &v1alpha2.AuthConfig{
+					TLSVerifyAndMap: true,
+				},
+			},
+			output: `port: 4222
+http_port: 8222
+cluster {
+  port: 6222
+}
+logtime: true`,
+		},
+		{
+			name: "clients auth secret",
+			spec: v1alpha2.ClusterSpec{
+				Auth: &v1alpha2.AuthConfig{
+					ClientsAuthSecret:  "clients-auth",
+					ClientsAuthTimeout: 5,
+				},
+			},
+			authData: &AuthData{
+				ClientsAuth: clientsAuth,
+			},
+			output: `port: 4222
+http_port: 8222
+cluster {
+  port: 6222
+}
+logtime: true
+authorization {
+  timeout: 5
+  users: [
+    {
+      username: "foo"
+      password: "bar"
+    }
+  ]
+}`,
+		},
+		{
+			name: "service accounts",
+			spec: v1alpha2.ClusterSpec{
+				Auth: &v1alpha2.AuthConfig{
+					EnableServiceAccounts: true,
+					// Ignored when service accounts are enabled.
+					ClientsAuthSecret: "clients-auth",
+				},
+			},
+			authData: &AuthData{
+				ClientsAuth: clientsAuth,
+				ServiceRoleUsers: []*natsconf.User{
+					{
+						User:     "nats-user",
+						Password: "token",
+						Permissions: &natsconf.Permissions{
+							Publish:   []string{"foo.>"},
+							Subscribe: []string{"bar.*"},
+						},
+					},
+				},
+			},
+			output: `port: 4222
+http_port: 8222
+cluster {
+  port: 6222
+}
+logtime: true
+authorization {
+  users: [
+    {
+      username: "nats-user"
+      password: "token"
+      permissions {
+        publish: [
+          "foo.>"
+        ]
+        subscribe: [
+          "bar.*"
+        ]
+      }
+    }
+  ]
+}`,
+		},
+		{
+			name: "raw config",
+			spec: v1alpha2.ClusterSpec{
+				ServerConfig: &v1alpha2.ServerConfig{
+					Debug:      true,
+					MaxPayload: 1 << 20,
+				},
+			},
+			raw: `
+debug: false
+max_payload: 4MB
+ping_interval: "1m"
+cluster {
+  no_advertise: true
+}
+`,
+			output: `port: 4222
+http_port: 8222
+cluster {
+  port: 6222
+  no_advertise: true
+}
+logtime: true
+max_payload: 4MB
+ping_interval: "1m"`,
+		},
+		{
+			name: "accounts",
+			spec: v1alpha2.ClusterSpec{
+				Accounts: []*v1alpha2.AccountConfig{
+					{
+						Name: "A",
+						Users: []*v1alpha2.AccountUser{
+							{
+								Username: "foo",
+								Permissions: &v1alpha2.Permissions{
+									Publish: []string{"public.>"},
+								},
+							},
+						},
+						Exports: []*v1alpha2.AccountExport{
+							{Stream: "public.>"},
+							{Service: "req.time", Accounts: []string{"B"}},
+						},
+					},
+					{
+						Name:  "B",
+						Users: []*v1alpha2.AccountUser{{Username: "bar"}},
+						Imports: []*v1alpha2.AccountImport{
+							{Stream: &v1alpha2.AccountImportSource{Account: "A", Subject: "public.>"}, Prefix: "a"},
+							{Service: &v1alpha2.AccountImportSource{Account: "A", Subject: "req.time"}, To: "time"},
+						},
+					},
+					{
+						Name:  "SYS",
+						Users: []*v1alpha2.AccountUser{{Username: "admin"}},
+					},
+				},
+				SystemAccount: "SYS",
+			},
+			authData: &AuthData{
+				AccountPasswords: map[string]string{
+					"foo":   "foo-password",
+					"bar":   "bar-password",
+					"admin": "admin-password",
+				},
+			},
+			output: `port: 4222
+http_port: 8222
+cluster {
+  port: 6222
+}
+logtime: true
+accounts {
+  A {
+    users: [
+      {
+        username: "foo"
+        password: "foo-password"
+        permissions {
+          publish: [
+            "public.>"
+          ]
+        }
+      }
+    ]
+    exports: [
+      {
+        stream: "public.>"
+      }
+      {
+        service: "req.time"
+        accounts: [
+          "B"
+        ]
+      }
+    ]
+  }
+  B {
+    users: [
+      {
+        username: "bar"
+        password: "bar-password"
+      }
+    ]
+    imports: [
+      {
+        stream {
+          account: "A"
+          subject: "public.>"
+        }
+        prefix: "a"
+      }
+      {
+        service {
+          account: "A"
+          subject: "req.time"
+        }
+        to: "time"
+      }
+    ]
+  }
+  SYS {
+    users: [
+      {
+        username: "admin"
+        password: "admin-password"
+      }
+    ]
+  }
+}
+system_account: "SYS"`,
+		},
+		{
+			name: "accounts with missing password",
+			spec: v1alpha2.ClusterSpec{
+				Accounts: []*v1alpha2.AccountConfig{
+					{
+						Name:  "A",
+						Users: []*v1alpha2.AccountUser{{Username: "foo"}},
+					},
+				},
+			},
+			err: `no password for user "foo" of account "A"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := &v1alpha2.NatsCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "nats", Namespace: "ns"},
+				Spec:       tt.spec,
+			}
+			data := &ConfigData{Auth: tt.authData}
+			if tt.raw != "" {
+				overlay, err := v1alpha2.ParseRawConfig(tt.raw)
+				if err != nil {
+					t.Fatalf("Error: %s", err)
+				}
+				data.RawConfig = overlay
+			}
+			sconfig, err := RenderConfig(cluster, tt.pods, data)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("Expected error %q, got: %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error: %s", err)
+			}
+			res, err := natsconf.Marshal(sconfig)
+			if err != nil {
+				t.Fatalf("Error: %s", err)
+			}
+			if o := strings.TrimSpace(string(res)); o != tt.output {
+				t.Errorf("Expected %+v, got: %+v", tt.output, o)
+			}
+		})
+	}
+
+	// The authorization data must be left untouched.
+	if clientsAuth.Timeout != 0 {
+		t.Errorf("Expected the authorization data to be left untouched, got timeout %d", clientsAuth.Timeout)
+	}
+}
+
+// newTestOperatorClient returns a fake clientset listing NatsServiceRole resources with the specified names, all targeting the "nats" cluster.
+// Listing is served by a reactor, as the fake clientset doesn't register these resources under the "nats.io" group.
+func newTestOperatorClient(names ...string) *operatorfake.Clientset {
+	list := &v1alpha2.NatsServiceRoleList{}
+	for _, name := range names {
+		list.Items = append(list.Items, v1alpha2.NatsServiceRole{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "ns",
+				UID:       types.UID(name),
+				Labels:    map[string]string{LabelClusterNameKey: "nats"},
+			},
+			Spec: v1alpha2.ServiceRoleSpec{
+				Permissions: v1alpha2.Permissions{Publish: []string{"foo.>"}},
+			},
+		})
+	}
+	operatorClient := operatorfake.NewSimpleClientset()
+	operatorClient.PrependReactor("list", "natsserviceroles", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, list, nil
+	})
+	return operatorClient
+}
+
+func TestGetConfigData(t *testing.T) {
+	cluster := &v1alpha2.NatsCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "nats", Namespace: "ns"},
+		Spec: v1alpha2.ClusterSpec{
+			Auth:         &v1alpha2.AuthConfig{EnableServiceAccounts: true},
+			ServerConfig: &v1alpha2.ServerConfig{Raw: `ping_interval: "1m"`},
+		},
+	}
+	kubeClient := fake.NewSimpleClientset(
+		&v1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "issued", Namespace: "ns"}},
+		&v1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "pending", Namespace: "ns"}},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "issued-nats-bound-token", Namespace: "ns"},
+			Data:       map[string][]byte{"token": []byte("issued-token")},
+		},
+	)
+	operatorClient := newTestOperatorClient("issued", "pending")
+
+	data, err := GetConfigData(kubeClient.CoreV1(), operatorClient.NatsV1alpha2(), cluster)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	// Roles whose token hasn't been issued yet are skipped.
+	users := data.Auth.ServiceRoleUsers
+	if len(users) != 1 || users[0].User != "issued" || users[0].Password != "issued-token" || !reflect.DeepEqual(users[0].Permissions.Publish, []string{"foo.>"}) {
+		t.Errorf("Expected a single user for the issued role, got: %+v", users)
+	}
+	expectedRaw := map[string]interface{}{"ping_interval": "1m"}
+	if !reflect.DeepEqual(data.RawConfig, expectedRaw) {
+		t.Errorf("Expected %+v, got: %+v", expectedRaw, data.RawConfig)
+	}
+	// Gathering the data must only read from the Kubernetes API.
+	for _, action := range kubeClient.Actions() {
+		if action.GetVerb() != "get" && action.GetVerb() != "list" {
+			t.Errorf("Expected only reads, got: %s %s", action.GetVerb(), action.GetResource().Resource)
+		}
+	}
+
+	// Invalid snippets of configuration are reported.
+	cluster.Spec.ServerConfig.Raw = `port: 4333`
+	if _, err := GetConfigData(kubeClient.CoreV1(), operatorClient.NatsV1alpha2(), cluster); err == nil || err.Error() != "invalid raw config: keys managed by nats-operator cannot be set: port" {
+		t.Errorf("Expected error %q, got: %v", "invalid raw config: keys managed by nats-operator cannot be set: port", err)
+	}
+}
+
+func TestIssueServiceRoleTokens(t *testing.T) {
+	cluster := &v1alpha2.NatsCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "nats", Namespace: "ns"},
+		Spec: v1alpha2.ClusterSpec{
+			Auth: &v1alpha2.AuthConfig{EnableServiceAccounts: true},
+		},
+	}
+	kubeClient := fake.NewSimpleClientset(
+		&v1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "issued", Namespace: "ns"}},
+		&v1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "pending", Namespace: "ns"}},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "issued-nats-bound-token", Namespace: "ns"},
+			Data:       map[string][]byte{"token": []byte("issued-token")},
+		},
+	)
+	tokenRequests := 0
+	kubeClient.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "token" {
+			return false, nil, nil
+		}
+		tokenRequests++
+		return true, &authenticationv1.TokenRequest{Status: authenticationv1.TokenRequestStatus{Token: "pending-token"}}, nil
+	})
+	// Roles without a matching service account are skipped.
+	operatorClient := newTestOperatorClient("issued", "pending", "orphaned")
+
+	if err := IssueServiceRoleTokens(kubeClient.CoreV1(), operatorClient.NatsV1alpha2(), cluster); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if tokenRequests != 1 {
+		t.Errorf("Expected a single token request, got: %d", tokenRequests)
+	}
+	secret, err := kubeClient.CoreV1().Secrets("ns").Get("pending-nats-bound-token", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if token := string(secret.Data["token"]); token != "pending-token" {
+		t.Errorf("Expected token %q, got: %q", "pending-token", token)
+	}
+	if len(secret.OwnerReferences) != 1 || secret.OwnerReferences[0].Name != "pending" {
+		t.Errorf("Expected the secret to be owned by the role, got: %+v", secret.OwnerReferences)
+	}
+
+	// Tokens which have already been issued are left untouched.
+	if err := IssueServiceRoleTokens(kubeClient.CoreV1(), operatorClient.NatsV1alpha2(), cluster); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if tokenRequests != 1 {
+		t.Errorf("Expected no further token requests, got: %d", tokenRequests)
+	}
+}
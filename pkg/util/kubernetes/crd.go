@@ -40,6 +40,9 @@ const (
 )
 
 var (
+	// natsClusterLabelSelectorPath is the path to the label selector of a NatsCluster exposed through the "/scale" subresource.
+	natsClusterLabelSelectorPath = ".status.selector"
+
 	// crds contains all the custom resource definitions that nats-operator registers upon starting.
 	crds = []*extsv1beta1.CustomResourceDefinition{
 		// NatsCluster
@@ -56,6 +59,42 @@ var (
 					Kind:       v1alpha2.CRDResourceKind,
 					ShortNames: []string{"nats"},
 				},
+				Validation: natsClusterValidation(),
+				AdditionalPrinterColumns: []extsv1beta1.CustomResourceColumnDefinition{
+					{
+						Name:        "Size",
+						Type:        "integer",
+						Description: "The current size of the NATS cluster.",
+						JSONPath:    ".status.size",
+					},
+					{
+						Name:        "Version",
+						Type:        "string",
+						Description: "The current version of the NATS cluster.",
+						JSONPath:    ".status.currentVersion",
+					},
+					{
+						Name:        "Phase",
+						Type:        "string",
+						Description: "The current phase of the NATS cluster.",
+						JSONPath:    ".status.phase",
+					},
+					{
+						Name:     "Age",
+						Type:     "date",
+						JSONPath: ".metadata.creationTimestamp",
+					},
+				},
+				// Enable the "/status" subresource so that the status of a NatsCluster can only be changed by the operator.
+				// Enable the "/scale" subresource so that a NatsCluster can be scaled using "kubectl scale" or a HorizontalPodAutoscaler.
+				Subresources: &extsv1beta1.CustomResourceSubresources{
+					Status: &extsv1beta1.CustomResourceSubresourceStatus{},
+					Scale: &extsv1beta1.CustomResourceSubresourceScale{
+						SpecReplicasPath:   ".spec.size",
+						StatusReplicasPath: ".status.size",
+						LabelSelectorPath:  &natsClusterLabelSelectorPath,
+					},
+				},
 			},
 		},
 		// NatsServiceRole
@@ -68,9 +107,11 @@ var (
 				Version: v1alpha2.SchemeGroupVersion.Version,
 				Scope:   extsv1beta1.NamespaceScoped,
 				Names: extsv1beta1.CustomResourceDefinitionNames{
-					Plural: v1alpha2.ServiceRoleCRDResourcePlural,
-					Kind:   v1alpha2.ServiceRoleCRDResourceKind,
+					Plural:     v1alpha2.ServiceRoleCRDResourcePlural,
+					Kind:       v1alpha2.ServiceRoleCRDResourceKind,
+					ShortNames: []string{"nsr"},
 				},
+				Validation: natsServiceRoleValidation(),
 			},
 		},
 	}
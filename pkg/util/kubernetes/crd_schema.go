@@ -0,0 +1,203 @@
+// Copyright 2019 The nats-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	extsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/nats-io/nats-operator/pkg/apis/nats/v1alpha2"
+	"github.com/nats-io/nats-operator/pkg/util/semver"
+)
+
+const (
+	// subjectPattern is a regular expression matching well-formed NATS subjects, in which wildcards are full tokens and ">" is the last token.
+	subjectPattern = `^(([^.\s*>]+|\*)(\.([^.\s*>]+|\*))*(\.>)?|>)$`
+)
+
+var (
+	// quantityType is the type used to represent resource quantities, which are serialized as strings (e.g. "10Gi").
+	quantityType = reflect.TypeOf(resource.Quantity{})
+
+	// pullPolicies is the list of valid image pull policies.
+	pullPolicies = []string{"Always", "IfNotPresent", "Never"}
+
+	// accessModes is the list of valid access modes for persistent volumes.
+	accessModes = []string{"ReadWriteOnce", "ReadOnlyMany", "ReadWriteMany"}
+
+	// natsClusterSchemaOverrides holds additional constraints for the fields of the schema of NatsCluster resources, keyed by their path.
+	natsClusterSchemaOverrides = map[string]func(*extsv1beta1.JSONSchemaProps){
+		"spec.size":                                   withMinimum(1),
+		"spec.version":                                withPattern(semver.Pattern),
+		"spec.lameDuckDurationSeconds":                withMinimum(1),
+		"spec.natsConfig.maxConnections":              withMinimum(0),
+		"spec.natsConfig.maxPayload":                  withMinimum(0),
+		"spec.natsConfig.maxPending":                  withMinimum(0),
+		"spec.natsConfig.maxSubscriptions":            withMinimum(0),
+		"spec.natsConfig.maxControlLine":              withMinimum(0),
+		"spec.pod.reloaderImagePullPolicy":            withEnum(pullPolicies...),
+		"spec.pod.metricsImagePullPolicy":             withEnum(pullPolicies...),
+		"spec.pod.readinessProbe.initialDelaySeconds": withMinimum(0),
+		"spec.pod.readinessProbe.timeoutSeconds":      withMinimum(1),
+		"spec.pod.readinessProbe.periodSeconds":       withMinimum(1),
+		"spec.pod.readinessProbe.successThreshold":    withMinimum(1),
+		"spec.pod.readinessProbe.failureThreshold":    withMinimum(1),
+		"spec.pod.topology.antiAffinity[].mode":       withEnum(string(v1alpha2.AntiAffinityModeRequired), string(v1alpha2.AntiAffinityModePreferred)),
+		"spec.pod.topology.antiAffinity[].weight":     withRange(0, 100),
+		"spec.tls.clientsTLSTimeout":                  withMinimum(0),
+		"spec.tls.routesTLSTimeout":                   withMinimum(0),
+		"spec.auth.clientsAuthTimeout":                withMinimum(0),
+		"spec.scaling.maxParallelCreations":           withMinimum(1),
+		"spec.scaling.maxParallelDeletions":           withMinimum(1),
+		"spec.disruptionBudget.maxUnavailable":        withMinimum(0),
+		"spec.disruptionBudget.minAvailable":          withMinimum(0),
+		"spec.upgrade.failureThreshold":               withMinimum(1),
+		"spec.upgrade.timeoutSeconds":                 withMinimum(1),
+		"spec.upgradeStrategy.type":                   withEnum(string(v1alpha2.UpgradeStrategyTypePartition), string(v1alpha2.UpgradeStrategyTypeCanary)),
+		"spec.upgradeStrategy.partition.ordinal":      withMinimum(0),
+		"spec.upgradeStrategy.canary.replicas":        withMinimum(1),
+		"spec.upgradeStrategy.canary.soakSeconds":     withMinimum(0),
+		"spec.meshHealth.periodSeconds":               withMinimum(1),
+		"spec.meshHealth.restartAfterSeconds":         withMinimum(1),
+		"spec.storage.accessModes[]":                  withEnum(accessModes...),
+
+		"spec.pod.topology.spreadConstraints[].maxSkew":           withMinimum(1),
+		"spec.pod.topology.spreadConstraints[].whenUnsatisfiable": withEnum(string(v1alpha2.UnsatisfiableConstraintActionDoNotSchedule), string(v1alpha2.UnsatisfiableConstraintActionScheduleAnyway)),
+
+		"spec.accounts[].users[].permissions.publish[]":   withPattern(subjectPattern),
+		"spec.accounts[].users[].permissions.subscribe[]": withPattern(subjectPattern),
+		"spec.accounts[].exports[].stream":                withPattern(subjectPattern),
+		"spec.accounts[].exports[].service":               withPattern(subjectPattern),
+		"spec.accounts[].imports[].stream.subject":        withPattern(subjectPattern),
+		"spec.accounts[].imports[].service.subject":       withPattern(subjectPattern),
+	}
+
+	// natsServiceRoleSchemaOverrides holds additional constraints for the fields of the schema of NatsServiceRole resources, keyed by their path.
+	natsServiceRoleSchemaOverrides = map[string]func(*extsv1beta1.JSONSchemaProps){
+		"spec.permissions.publish[]":   withPattern(subjectPattern),
+		"spec.permissions.subscribe[]": withPattern(subjectPattern),
+	}
+)
+
+// natsClusterValidation returns the validation schema for NatsCluster resources.
+func natsClusterValidation() *extsv1beta1.CustomResourceValidation {
+	return specValidation(reflect.TypeOf(v1alpha2.ClusterSpec{}), natsClusterSchemaOverrides)
+}
+
+// natsServiceRoleValidation returns the validation schema for NatsServiceRole resources.
+func natsServiceRoleValidation() *extsv1beta1.CustomResourceValidation {
+	return specValidation(reflect.TypeOf(v1alpha2.ServiceRoleSpec{}), natsServiceRoleSchemaOverrides)
+}
+
+// specValidation returns a validation schema constraining the ".spec" field of a custom resource to the specified type.
+// The ".status" field is deliberately left out, as it is managed by nats-operator alone.
+func specValidation(t reflect.Type, overrides map[string]func(*extsv1beta1.JSONSchemaProps)) *extsv1beta1.CustomResourceValidation {
+	spec := schemaFor(t, "spec", overrides)
+	return &extsv1beta1.CustomResourceValidation{
+		OpenAPIV3Schema: &extsv1beta1.JSONSchemaProps{
+			Type: "object",
+			Properties: map[string]extsv1beta1.JSONSchemaProps{
+				"spec": spec,
+			},
+		},
+	}
+}
+
+// schemaFor builds the OpenAPI v3 schema for the specified Go type, which is found at the specified path.
+// Every node of the resulting schema has a type, and constraints found in the overrides map are applied to the matching paths.
+// Structs that belong to the Kubernetes API (e.g. pod templates or tolerations) are described as plain objects, since these are validated by Kubernetes when pods are created.
+func schemaFor(t reflect.Type, path string, overrides map[string]func(*extsv1beta1.JSONSchemaProps)) extsv1beta1.JSONSchemaProps {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var res extsv1beta1.JSONSchemaProps
+	switch {
+	case t == quantityType:
+		res = extsv1beta1.JSONSchemaProps{Type: "string"}
+	case t.Kind() == reflect.Struct && strings.HasPrefix(t.PkgPath(), "k8s.io/"):
+		res = extsv1beta1.JSONSchemaProps{Type: "object"}
+	case t.Kind() == reflect.Struct:
+		res = extsv1beta1.JSONSchemaProps{Type: "object", Properties: map[string]extsv1beta1.JSONSchemaProps{}}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := strings.Split(f.Tag.Get("json"), ",")[0]
+			if f.PkgPath != "" || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			res.Properties[name] = schemaFor(f.Type, path+"."+name, overrides)
+		}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		items := schemaFor(t.Elem(), path+"[]", overrides)
+		res = extsv1beta1.JSONSchemaProps{Type: "array", Items: &extsv1beta1.JSONSchemaPropsOrArray{Schema: &items}}
+	case t.Kind() == reflect.Map:
+		values := schemaFor(t.Elem(), path+"{}", overrides)
+		res = extsv1beta1.JSONSchemaProps{Type: "object", AdditionalProperties: &extsv1beta1.JSONSchemaPropsOrBool{Allows: true, Schema: &values}}
+	case t.Kind() == reflect.String:
+		res = extsv1beta1.JSONSchemaProps{Type: "string"}
+	case t.Kind() == reflect.Bool:
+		res = extsv1beta1.JSONSchemaProps{Type: "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		res = extsv1beta1.JSONSchemaProps{Type: "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		res = extsv1beta1.JSONSchemaProps{Type: "number"}
+	default:
+		res = extsv1beta1.JSONSchemaProps{Type: "object"}
+	}
+
+	if fn, ok := overrides[path]; ok {
+		fn(&res)
+	}
+	return res
+}
+
+// withMinimum returns a function that sets the minimum value of a schema.
+func withMinimum(min float64) func(*extsv1beta1.JSONSchemaProps) {
+	return func(props *extsv1beta1.JSONSchemaProps) {
+		props.Minimum = &min
+	}
+}
+
+// withRange returns a function that sets the minimum and maximum values of a schema.
+func withRange(min, max float64) func(*extsv1beta1.JSONSchemaProps) {
+	return func(props *extsv1beta1.JSONSchemaProps) {
+		props.Minimum = &min
+		props.Maximum = &max
+	}
+}
+
+// withPattern returns a function that sets the pattern of a schema.
+func withPattern(pattern string) func(*extsv1beta1.JSONSchemaProps) {
+	return func(props *extsv1beta1.JSONSchemaProps) {
+		props.Pattern = pattern
+	}
+}
+
+// withEnum returns a function that sets the allowed values of a schema.
+func withEnum(values ...string) func(*extsv1beta1.JSONSchemaProps) {
+	return func(props *extsv1beta1.JSONSchemaProps) {
+		for _, v := range values {
+			b, _ := json.Marshal(v)
+			props.Enum = append(props.Enum, extsv1beta1.JSON{Raw: b})
+		}
+	}
+}
@@ -15,17 +15,19 @@
 package kubernetes
 
 import (
-	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
-	authenticationv1 "k8s.io/api/authentication/v1"
 	"k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
@@ -41,8 +43,6 @@ import (
 
 	"github.com/nats-io/nats-operator/pkg/apis/nats/v1alpha2"
 	natsclient "github.com/nats-io/nats-operator/pkg/client/clientset/versioned"
-	natsalphav2client "github.com/nats-io/nats-operator/pkg/client/clientset/versioned/typed/nats/v1alpha2"
-	"github.com/nats-io/nats-operator/pkg/conf"
 	"github.com/nats-io/nats-operator/pkg/constants"
 	"github.com/nats-io/nats-operator/pkg/util/retryutil"
 )
@@ -50,6 +50,10 @@ import (
 const (
 	TolerateUnreadyEndpointsAnnotation = "service.alpha.kubernetes.io/tolerate-unready-endpoints"
 	versionAnnotationKey               = "nats.version"
+	// podSpecHashAnnotationKey is the key of the annotation that holds the hash of the spec from which a pod was rendered.
+	podSpecHashAnnotationKey = "nats.io/pod-spec-hash"
+	// ScaleDownPriorityAnnotationKey is the key of the annotation that may be set on a pod in order for it to be removed before (higher values) or after (lower values) other pods when scaling down.
+	ScaleDownPriorityAnnotationKey = "nats.io/scale-down-priority"
 )
 
 const (
@@ -68,6 +72,63 @@ func SetNATSVersion(pod *v1.Pod, version string) {
 	pod.Labels[LabelClusterVersionKey] = version
 }
 
+// GetPodSpecHash returns the hash of the spec from which the specified pod was rendered, or an empty string if it is unknown.
+func GetPodSpecHash(pod *v1.Pod) string {
+	return pod.Annotations[podSpecHashAnnotationKey]
+}
+
+// SetPodSpecHash sets the hash of the spec from which the specified pod was rendered.
+func SetPodSpecHash(pod *v1.Pod, hash string) {
+	if pod.Annotations == nil {
+		pod.Annotations = make(map[string]string)
+	}
+	pod.Annotations[podSpecHashAnnotationKey] = hash
+}
+
+// GetScaleDownPriority returns the scale-down priority of the specified pod, which is zero unless set using the "nats.io/scale-down-priority" annotation.
+func GetScaleDownPriority(pod *v1.Pod) (int, error) {
+	v, ok := pod.Annotations[ScaleDownPriorityAnnotationKey]
+	if !ok {
+		return 0, nil
+	}
+	p, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q for annotation %q: %v", v, ScaleDownPriorityAnnotationKey, err)
+	}
+	return p, nil
+}
+
+// podSpecHashPodName is the name of the pod rendered in order to compute the hash of the pod spec of a NATS cluster.
+// A fixed name is used so that all the pods belonging to the same NATS cluster have the same hash.
+const podSpecHashPodName = "pod-spec-hash"
+
+// PodSpecHash returns the hash of the pod spec rendered by NewNatsPodSpec for the members of the NATS cluster with the specified name and spec.
+// The version is left out as version changes are handled by upgrading pods, and so are the images nats-operator uses by default, so that upgrading nats-operator does not cause every pod in every NATS cluster to be replaced.
+// Hence, two pods belonging to the same NATS cluster have the same hash unless their spec has drifted.
+func PodSpecHash(clusterName string, cs v1alpha2.ClusterSpec) (string, error) {
+	cs.Version = ""
+	spec := newNatsPod("", podSpecHashPodName, clusterName, cs, metav1.OwnerReference{}).Spec
+	defaults := map[string]string{
+		constants.NatsContainerName: MakeNATSImage("", constants.DefaultServerImage),
+		"reloader":                  fmt.Sprintf("%s:%s", constants.DefaultReloaderImage, constants.DefaultReloaderImageTag),
+		"metrics":                   fmt.Sprintf("%s:%s", constants.DefaultMetricsImage, constants.DefaultMetricsImageTag),
+		"bootconfig":                fmt.Sprintf("%s:%s", constants.DefaultBootConfigImage, constants.DefaultBootConfigImageTag),
+	}
+	for _, containers := range [][]v1.Container{spec.InitContainers, spec.Containers} {
+		for i := range containers {
+			if containers[i].Image == defaults[containers[i].Name] {
+				containers[i].Image = ""
+			}
+		}
+	}
+	b, err := json.Marshal(spec)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal pod spec: %v", err)
+	}
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:]), nil
+}
+
 func GetPodNames(pods []*v1.Pod) []string {
 	if len(pods) == 0 {
 		return nil
@@ -88,11 +149,21 @@ func PodWithNodeSelector(p *v1.Pod, ns map[string]string) *v1.Pod {
 	return p
 }
 
-func createService(kubecli corev1client.CoreV1Interface, svcName, clusterName, ns, clusterIP string, ports []v1.ServicePort, owner metav1.OwnerReference, selectors map[string]string, tolerateUnready bool) error {
+// CreateService creates the specified service, recording its state as the last-applied one.
+func CreateService(kubecli corev1client.CoreV1Interface, svc *v1.Service) error {
+	if err := SetLastAppliedConfig(svc); err != nil {
+		return err
+	}
+	_, err := kubecli.Services(svc.Namespace).Create(svc)
+	return err
+}
+
+// newService returns a service for the specified NATS cluster, owned by the specified owner.
+func newService(svcName, clusterName, ns, clusterIP string, ports []v1.ServicePort, owner metav1.OwnerReference, selectors map[string]string, tolerateUnready bool) *v1.Service {
 	svc := newNatsServiceManifest(svcName, clusterName, clusterIP, ports, selectors, tolerateUnready)
+	svc.Namespace = ns
 	addOwnerRefToObject(svc.GetObjectMeta(), owner)
-	_, err := kubecli.Services(ns).Create(svc)
-	return err
+	return svc
 }
 
 // ClientServiceName returns the name of the client service based on the specified cluster name.
@@ -100,7 +171,8 @@ func ClientServiceName(clusterName string) string {
 	return clusterName
 }
 
-func CreateClientService(kubecli corev1client.CoreV1Interface, clusterName, ns string, owner metav1.OwnerReference) error {
+// NewClientService returns the service used by clients to connect to the NATS cluster.
+func NewClientService(clusterName, ns string, owner metav1.OwnerReference) *v1.Service {
 	ports := []v1.ServicePort{{
 		Name:       "client",
 		Port:       constants.ClientPort,
@@ -108,15 +180,15 @@ func CreateClientService(kubecli corev1client.CoreV1Interface, clusterName, ns s
 		Protocol:   v1.ProtocolTCP,
 	}}
 	selectors := LabelsForCluster(clusterName)
-	return createService(kubecli, ClientServiceName(clusterName), clusterName, ns, "", ports, owner, selectors, false)
+	return newService(ClientServiceName(clusterName), clusterName, ns, "", ports, owner, selectors, false)
 }
 
 func ManagementServiceName(clusterName string) string {
 	return clusterName + "-mgmt"
 }
 
-// CreateMgmtService creates an headless service for NATS management purposes.
-func CreateMgmtService(kubecli corev1client.CoreV1Interface, clusterName, clusterVersion, ns string, owner metav1.OwnerReference) error {
+// NewMgmtService returns the headless service used for NATS management purposes, selecting the pods running the specified version.
+func NewMgmtService(clusterName, clusterVersion, ns string, owner metav1.OwnerReference) *v1.Service {
 	ports := []v1.ServicePort{
 		{
 			Name:       "cluster",
@@ -139,188 +211,72 @@ func CreateMgmtService(kubecli corev1client.CoreV1Interface, clusterName, cluste
 	}
 	selectors := LabelsForCluster(clusterName)
 	selectors[LabelClusterVersionKey] = clusterVersion
-	return createService(kubecli, ManagementServiceName(clusterName), clusterName, ns, v1.ClusterIPNone, ports, owner, selectors, true)
+	return newService(ManagementServiceName(clusterName), clusterName, ns, v1.ClusterIPNone, ports, owner, selectors, true)
 }
 
-// addTLSConfig fills in the TLS configuration to be used in the config map.
-func addTLSConfig(sconfig *natsconf.ServerConfig, cs v1alpha2.ClusterSpec) {
-	if cs.TLS == nil {
-		return
-	}
-
-	if cs.TLS.EnableHttps {
-		// Replace monitoring port with https one.
-		sconfig.HTTPSPort = int(constants.MonitoringPort)
-		sconfig.HTTPPort = 0
-	}
-
-	if cs.TLS.ServerSecret != "" {
-		sconfig.TLS = &natsconf.TLSConfig{
-			CAFile:   constants.ServerCertsMountPath + "/" + cs.TLS.ServerSecretCAFileName,
-			CertFile: constants.ServerCertsMountPath + "/" + cs.TLS.ServerSecretCertFileName,
-			KeyFile:  constants.ServerCertsMountPath + "/" + cs.TLS.ServerSecretKeyFileName,
-		}
+// PodDisruptionBudgetName returns the name of the PodDisruptionBudget based on the specified cluster name.
+func PodDisruptionBudgetName(clusterName string) string {
+	return clusterName
+}
 
-		if cs.TLS.ClientsTLSTimeout > 0 {
-			sconfig.TLS.Timeout = cs.TLS.ClientsTLSTimeout
-		}
-	}
-	if cs.TLS.RoutesSecret != "" {
-		sconfig.Cluster.TLS = &natsconf.TLSConfig{
-			CAFile:   constants.RoutesCertsMountPath + "/" + cs.TLS.RoutesSecretCAFileName,
-			CertFile: constants.RoutesCertsMountPath + "/" + cs.TLS.RoutesSecretCertFileName,
-			KeyFile:  constants.RoutesCertsMountPath + "/" + cs.TLS.RoutesSecretKeyFileName,
-		}
-		if cs.TLS.RoutesTLSTimeout > 0 {
-			sconfig.Cluster.TLS.Timeout = cs.TLS.RoutesTLSTimeout
-		}
-	}
-	if cs.Auth != nil && cs.Auth.TLSVerifyAndMap {
-		sconfig.TLS.VerifyAndMap = true
+// NewPodDisruptionBudget returns the PodDisruptionBudget protecting the members of the specified cluster, requiring that at least minAvailable of them remain available.
+func NewPodDisruptionBudget(clusterName, ns string, minAvailable int32, owner metav1.OwnerReference) *policyv1beta1.PodDisruptionBudget {
+	min := intstr.FromInt(int(minAvailable))
+	pdb := &policyv1beta1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      PodDisruptionBudgetName(clusterName),
+			Namespace: ns,
+			Labels:    LabelsForCluster(clusterName),
+		},
+		Spec: policyv1beta1.PodDisruptionBudgetSpec{
+			MinAvailable: &min,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: LabelsForCluster(clusterName),
+			},
+		},
 	}
+	addOwnerRefToObject(pdb.GetObjectMeta(), owner)
+	return pdb
 }
 
-func addAuthConfig(
-	kubecli corev1client.CoreV1Interface,
-	operatorcli natsalphav2client.NatsV1alpha2Interface,
-	ns string,
-	clusterName string,
-	sconfig *natsconf.ServerConfig,
-	cs v1alpha2.ClusterSpec,
-	owner metav1.OwnerReference,
-) error {
-	if cs.Auth == nil {
-		return nil
+// PodOrdinal returns the ordinal of the member of the specified NATS cluster with the specified pod name.
+// Pod names are of the form "<natscluster-name>-<idx>", where "<idx>" is the ordinal as a base-16 integer.
+func PodOrdinal(clusterName, podName string) (int, error) {
+	idx, err := strconv.ParseInt(strings.TrimPrefix(podName, clusterName+"-"), 16, 0)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse ordinal of pod %q: %v", podName, err)
 	}
+	return int(idx), nil
+}
 
-	if cs.Auth.EnableServiceAccounts {
-		roleSelector := map[string]string{
-			LabelClusterNameKey: clusterName,
-		}
-
-		users := make([]*natsconf.User, 0)
-		roles, err := operatorcli.NatsServiceRoles(ns).List(metav1.ListOptions{
-			LabelSelector: labels.SelectorFromSet(roleSelector).String(),
-		})
-		if err != nil {
-			return err
-		}
-
-		for _, role := range roles.Items {
-			// Lookup for a ServiceAccount with the same name as the NatsServiceRole.
-			sa, err := kubecli.ServiceAccounts(ns).Get(role.Name, metav1.GetOptions{})
-			if err != nil {
-				// TODO: Collect created secrets when the service account no
-				// longer exists, currently only deleted when the NatsServiceRole
-				// is deleted since it is the owner of the object.
-
-				// Skip since cannot map unless valid service account is found.
-				continue
-			}
-
-			// TODO: Add support for expiration of the issued tokens.
-			tokenSecretName := fmt.Sprintf("%s-%s-bound-token", role.Name, clusterName)
-			cs, err := kubecli.Secrets(ns).Get(tokenSecretName, metav1.GetOptions{})
-			if err == nil {
-				// We always get everything and apply, in case there is a diff
-				// then the reloader will apply them.
-				user := &natsconf.User{
-					User:     role.Name,
-					Password: string(cs.Data["token"]),
-					Permissions: &natsconf.Permissions{
-						Publish:   role.Spec.Permissions.Publish,
-						Subscribe: role.Spec.Permissions.Subscribe,
-					},
-				}
-				users = append(users, user)
-				continue
-			}
-
-			// Create the secret, then make a service token request, and finally
-			// update the secret with the token mapped to the service account.
-			tokenSecret := &v1.Secret{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:   tokenSecretName,
-					Labels: LabelsForCluster(clusterName),
-				},
-			}
-
-			// When the role that was mapped is deleted, then also delete the secret.
-			addOwnerRefToObject(tokenSecret.GetObjectMeta(), role.AsOwner())
-			tokenSecret, err = kubecli.Secrets(ns).Create(tokenSecret)
-			if err != nil {
-				return err
-			}
+// PersistentVolumeClaimName returns the name of the persistent volume claim used by the member with the specified pod name.
+func PersistentVolumeClaimName(podName string) string {
+	return fmt.Sprintf("%s-%s", podName, constants.DataVolumeName)
+}
 
-			// Issue token with audience set for the NATS cluster in this namespace only,
-			// this will prevent the token from being usable against the API Server.
-			ar := &authenticationv1.TokenRequest{
-				Spec: authenticationv1.TokenRequestSpec{
-					Audiences: []string{fmt.Sprintf("nats://%s.%s.svc", clusterName, ns)},
-
-					// Service Token will be valid for as long as the created secret exists.
-					BoundObjectRef: &authenticationv1.BoundObjectReference{
-						Kind:       "Secret",
-						APIVersion: "v1",
-						Name:       tokenSecret.Name,
-						UID:        tokenSecret.UID,
-					},
+// CreatePersistentVolumeClaim creates the persistent volume claim used by the member with the specified pod name, unless it already exists.
+// Existing claims are kept as they are, so that a replacement pod gets the volume used by its predecessor.
+// Claims are never deleted when the cluster is scaled down, and are only garbage-collected (through their owner reference) when the NatsCluster resource is deleted.
+func CreatePersistentVolumeClaim(kubecli corev1client.CoreV1Interface, podName, clusterName, ns string, storage *v1alpha2.StorageConfig, owner metav1.OwnerReference) error {
+	pvc := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   PersistentVolumeClaimName(podName),
+			Labels: LabelsForCluster(clusterName),
+		},
+		Spec: v1.PersistentVolumeClaimSpec{
+			AccessModes:      storage.AccessModes,
+			StorageClassName: storage.StorageClassName,
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{
+					v1.ResourceStorage: storage.Size,
 				},
-			}
-			tr, err := kubecli.ServiceAccounts(ns).CreateToken(sa.Name, ar)
-			if err != nil {
-				return err
-			}
-
-			if err == nil {
-				// Update secret with issued token, then save the user in the NATS Config.
-				token := tr.Status.Token
-				tokenSecret.Data = map[string][]byte{
-					"token": []byte(token),
-				}
-				tokenSecret, err = kubecli.Secrets(ns).Update(tokenSecret)
-				if err != nil {
-					return err
-				}
-				user := &natsconf.User{
-					User:     role.Name,
-					Password: string(token),
-					Permissions: &natsconf.Permissions{
-						Publish:   role.Spec.Permissions.Publish,
-						Subscribe: role.Spec.Permissions.Subscribe,
-					},
-				}
-				users = append(users, user)
-			}
-		}
-
-		// Expand authorization rules from the service account tokens.
-		sconfig.Authorization = &natsconf.AuthorizationConfig{
-			Users: users,
-		}
-		return nil
-	} else if cs.Auth.ClientsAuthSecret != "" {
-		// Authorization implementation using a secret with the explicit
-		// configuration of all the accounts from a cluster, cannot be
-		// used together with service accounts.
-		result, err := kubecli.Secrets(ns).Get(cs.Auth.ClientsAuthSecret, metav1.GetOptions{})
-		if err != nil {
-			return err
-		}
-
-		var clientAuth *natsconf.AuthorizationConfig
-		for _, v := range result.Data {
-			err := json.Unmarshal(v, &clientAuth)
-			if err != nil {
-				return err
-			}
-			if cs.Auth.ClientsAuthTimeout > 0 {
-				clientAuth.Timeout = cs.Auth.ClientsAuthTimeout
-			}
-			sconfig.Authorization = clientAuth
-			break
-		}
-		return nil
+			},
+		},
+	}
+	addOwnerRefToObject(pvc.GetObjectMeta(), owner)
+	_, err := kubecli.PersistentVolumeClaims(ns).Create(pvc)
+	if err != nil && !IsKubernetesResourceAlreadyExistError(err) {
+		return err
 	}
 	return nil
 }
@@ -365,200 +321,6 @@ func ConfigSecret(clusterName string) string {
 	return clusterName
 }
 
-// CreateConfigSecret creates the secret that contains the configuration file for a given NATS cluster..
-func CreateConfigSecret(kubecli corev1client.CoreV1Interface, operatorcli natsalphav2client.NatsV1alpha2Interface, clusterName, ns string, cluster v1alpha2.ClusterSpec, owner metav1.OwnerReference) error {
-	sconfig := &natsconf.ServerConfig{
-		Port:     int(constants.ClientPort),
-		HTTPPort: int(constants.MonitoringPort),
-		Cluster: &natsconf.ClusterConfig{
-			Port: int(constants.ClusterPort),
-		},
-	}
-
-	if cluster.ServerConfig != nil {
-		sconfig.Debug = cluster.ServerConfig.Debug
-		sconfig.Trace = cluster.ServerConfig.Trace
-		sconfig.WriteDeadline = cluster.ServerConfig.WriteDeadline
-		sconfig.MaxConnections = cluster.ServerConfig.MaxConnections
-		sconfig.MaxPayload = cluster.ServerConfig.MaxPayload
-		sconfig.MaxPending = cluster.ServerConfig.MaxPending
-		sconfig.MaxSubscriptions = cluster.ServerConfig.MaxSubscriptions
-		sconfig.MaxControlLine = cluster.ServerConfig.MaxControlLine
-		sconfig.Logtime = !cluster.ServerConfig.DisableLogtime
-	} else {
-		sconfig.Logtime = true
-	}
-
-	if cluster.ExtraRoutes != nil {
-		routes := make([]string, 0)
-		for _, extraCluster := range cluster.ExtraRoutes {
-			switch {
-			case extraCluster.Route != "":
-				// If route is explicit just include as is.
-				routes = append(routes, extraCluster.Route)
-			case extraCluster.Cluster != "":
-				route := fmt.Sprintf("nats://%s:%d",
-					ManagementServiceName(extraCluster.Cluster),
-					constants.ClusterPort)
-				routes = append(routes, route)
-			}
-		}
-		sconfig.Cluster.Routes = routes
-	}
-
-	// Observe .spec.lameDuckDurationSeconds if specified.
-	if cluster.LameDuckDurationSeconds != nil {
-		sconfig.LameDuckDuration = fmt.Sprintf("%ds", *cluster.LameDuckDurationSeconds)
-	}
-	if cluster.Pod != nil && cluster.Pod.AdvertiseExternalIP {
-		sconfig.Include = filepath.Join(".", constants.BootConfigFilePath)
-	}
-
-	addTLSConfig(sconfig, cluster)
-	err := addAuthConfig(kubecli, operatorcli, ns, clusterName, sconfig, cluster, owner)
-	if err != nil {
-		return err
-	}
-
-	rawConfig, err := natsconf.Marshal(sconfig)
-	if err != nil {
-		return err
-	}
-
-	// FIXME: Quoted "include" causes include to be ignored.
-	// Remove once using NATS v2.0 as the default container image.
-	if cluster.Pod != nil && cluster.Pod.AdvertiseExternalIP {
-		rawConfig = bytes.Replace(rawConfig, []byte(`"include":`), []byte("include "), 1)
-	}
-
-	labels := LabelsForCluster(clusterName)
-	cm := &v1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:   ConfigSecret(clusterName),
-			Labels: labels,
-		},
-		Data: map[string][]byte{
-			constants.ConfigFileName: rawConfig,
-		},
-	}
-	addOwnerRefToObject(cm.GetObjectMeta(), owner)
-
-	_, err = kubecli.Secrets(ns).Create(cm)
-	if apierrors.IsAlreadyExists(err) {
-		// Skip in case it was created already and update instead
-		// with the latest configuration.
-		_, err = kubecli.Secrets(ns).Update(cm)
-		return err
-	}
-
-	return nil
-}
-
-// UpdateConfigSecret applies the new configuration of the cluster,
-// such as modifying the routes available in the cluster.
-func UpdateConfigSecret(
-	kubecli corev1client.CoreV1Interface,
-	operatorcli natsalphav2client.NatsV1alpha2Interface,
-	clusterName, ns string,
-	cluster v1alpha2.ClusterSpec,
-	owner metav1.OwnerReference,
-) error {
-	// List all available pods then generate the routes
-	// for the NATS cluster.
-	routes := make([]string, 0)
-	podList, err := kubecli.Pods(ns).List(ClusterListOpt(clusterName))
-	if err != nil {
-		return err
-	}
-	for _, pod := range podList.Items {
-		// Skip pods that have failed
-		switch pod.Status.Phase {
-		case "Failed":
-			continue
-		}
-
-		route := fmt.Sprintf("nats://%s.%s.%s.svc:%d",
-			pod.Name, ManagementServiceName(clusterName), ns, constants.ClusterPort)
-		routes = append(routes, route)
-	}
-
-	if cluster.ExtraRoutes != nil {
-		for _, extraCluster := range cluster.ExtraRoutes {
-			switch {
-			case extraCluster.Route != "":
-				// If route is explicit just include as is.
-				routes = append(routes, extraCluster.Route)
-			case extraCluster.Cluster != "":
-				route := fmt.Sprintf("nats://%s:%d",
-					ManagementServiceName(extraCluster.Cluster),
-					constants.ClusterPort)
-				routes = append(routes, route)
-			}
-		}
-	}
-
-	sconfig := &natsconf.ServerConfig{
-		Port:     int(constants.ClientPort),
-		HTTPPort: int(constants.MonitoringPort),
-		Cluster: &natsconf.ClusterConfig{
-			Port:   int(constants.ClusterPort),
-			Routes: routes,
-		},
-	}
-
-	if cluster.ServerConfig != nil {
-		sconfig.Debug = cluster.ServerConfig.Debug
-		sconfig.Trace = cluster.ServerConfig.Trace
-		sconfig.WriteDeadline = cluster.ServerConfig.WriteDeadline
-		sconfig.MaxConnections = cluster.ServerConfig.MaxConnections
-		sconfig.MaxPayload = cluster.ServerConfig.MaxPayload
-		sconfig.MaxPending = cluster.ServerConfig.MaxPending
-		sconfig.MaxSubscriptions = cluster.ServerConfig.MaxSubscriptions
-		sconfig.MaxControlLine = cluster.ServerConfig.MaxControlLine
-		sconfig.Logtime = !cluster.ServerConfig.DisableLogtime
-	} else {
-		sconfig.Logtime = true
-	}
-
-	if cluster.Pod != nil && cluster.Pod.AdvertiseExternalIP {
-		sconfig.Include = filepath.Join(".", constants.BootConfigFilePath)
-	}
-
-	addTLSConfig(sconfig, cluster)
-	err = addAuthConfig(kubecli, operatorcli, ns, clusterName, sconfig, cluster, owner)
-	if err != nil {
-		return err
-	}
-
-	rawConfig, err := natsconf.Marshal(sconfig)
-	if err != nil {
-		return err
-	}
-
-	// FIXME: Quoted "include" causes include to be ignored.
-	// Remove once using NATS v2.0 as the default container image.
-	if cluster.Pod != nil && cluster.Pod.AdvertiseExternalIP {
-		rawConfig = bytes.Replace(rawConfig, []byte(`"include":`), []byte("include "), 1)
-	}
-
-	cm, err := kubecli.Secrets(ns).Get(clusterName, metav1.GetOptions{})
-	if err != nil {
-		return err
-	}
-	// Make sure that the secret has the required labels.
-	if cm.Labels == nil {
-		cm.Labels = make(map[string]string)
-	}
-	for key, val := range LabelsForCluster(clusterName) {
-		cm.Labels[key] = val
-	}
-	// Update the configuration.
-	cm.Data[constants.ConfigFileName] = rawConfig
-
-	_, err = kubecli.Secrets(ns).Update(cm)
-	return err
-}
-
 func newNatsConfigMapVolume(clusterName string) v1.Volume {
 	return v1.Volume{
 		Name: constants.ConfigMapVolumeName,
@@ -593,6 +355,24 @@ func newNatsPidFileVolumeMount() v1.VolumeMount {
 	}
 }
 
+func newNatsDataVolume(podName string) v1.Volume {
+	return v1.Volume{
+		Name: constants.DataVolumeName,
+		VolumeSource: v1.VolumeSource{
+			PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+				ClaimName: PersistentVolumeClaimName(podName),
+			},
+		},
+	}
+}
+
+func newNatsDataVolumeMount(mountPath string) v1.VolumeMount {
+	return v1.VolumeMount{
+		Name:      constants.DataVolumeName,
+		MountPath: mountPath,
+	}
+}
+
 func newNatsServiceManifest(svcName, clusterName, clusterIP string, ports []v1.ServicePort, selectors map[string]string, tolerateUnready bool) *v1.Service {
 	labels := map[string]string{
 		LabelAppKey:         LabelAppValue,
@@ -660,7 +440,19 @@ func addOwnerRefToObject(o metav1.Object, r metav1.OwnerReference) {
 }
 
 // NewNatsPodSpec returns a NATS peer pod specification, based on the cluster specification.
-func NewNatsPodSpec(namespace, name, clusterName string, cs v1alpha2.ClusterSpec, owner metav1.OwnerReference) *v1.Pod {
+// The hash of the rendered pod spec is stored in an annotation so that drifts can be detected later on.
+func NewNatsPodSpec(namespace, name, clusterName string, cs v1alpha2.ClusterSpec, owner metav1.OwnerReference) (*v1.Pod, error) {
+	hash, err := PodSpecHash(clusterName, cs)
+	if err != nil {
+		return nil, err
+	}
+	pod := newNatsPod(namespace, name, clusterName, cs, owner)
+	SetPodSpecHash(pod, hash)
+	return pod, nil
+}
+
+// newNatsPod renders the pod with the specified name for the specified NATS cluster.
+func newNatsPod(namespace, name, clusterName string, cs v1alpha2.ClusterSpec, owner metav1.OwnerReference) *v1.Pod {
 	var (
 		enableClientsHostPort bool
 		annotations           = map[string]string{}
@@ -686,6 +478,14 @@ func NewNatsPodSpec(namespace, name, clusterName string, cs v1alpha2.ClusterSpec
 	volumeMount = newNatsPidFileVolumeMount()
 	volumeMounts = append(volumeMounts, volumeMount)
 
+	// Persistent volume bound to the identity of the member.
+	if cs.Storage != nil {
+		volume = newNatsDataVolume(name)
+		volumes = append(volumes, volume)
+		volumeMount = newNatsDataVolumeMount(cs.Storage.MountPath)
+		volumeMounts = append(volumeMounts, volumeMount)
+	}
+
 	if cs.Pod != nil {
 		// User supplied volumes and mounts
 		volumeMounts = append(volumeMounts, cs.Pod.VolumeMounts...)
@@ -693,6 +493,9 @@ func NewNatsPodSpec(namespace, name, clusterName string, cs v1alpha2.ClusterSpec
 	}
 	container := natsPodContainer(clusterName, cs.Version, cs.ServerImage, enableClientsHostPort)
 	container = containerWithLivenessProbe(container, natsLivenessProbe(cs))
+	if cs.Pod != nil && cs.Pod.EnableConfigReload && cs.Pod.ReadinessProbe != nil {
+		container.ReadinessProbe = natsReadinessProbe(cs.Pod.ReadinessProbe)
+	}
 
 	// In case TLS was enabled as part of the NATS cluster
 	// configuration then should include the configuration here.
@@ -842,6 +645,9 @@ func NewNatsPodSpec(namespace, name, clusterName string, cs v1alpha2.ClusterSpec
 
 		reloaderContainer := natsPodReloaderContainer(image, imageTag, imagePullPolicy)
 		reloaderContainer.VolumeMounts = volumeMounts
+		if cs.Pod.ReadinessProbe != nil {
+			reloaderContainer = reloaderContainerWithReadinessCheck(reloaderContainer, cs)
+		}
 		containers = append(containers, reloaderContainer)
 	}
 
@@ -0,0 +1,326 @@
+// Copyright 2019 The nats-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/nats-io/nats-operator/pkg/apis/nats/v1alpha2"
+	"github.com/nats-io/nats-operator/pkg/constants"
+)
+
+func TestPodSpecHash(t *testing.T) {
+	base := v1alpha2.ClusterSpec{
+		Size:        3,
+		Version:     "1.4.0",
+		ServerImage: "nats",
+		Pod:         &v1alpha2.PodPolicy{EnableConfigReload: true},
+	}
+	base.SetDefaults()
+	podSpecHash := func(t *testing.T, podName string, cs v1alpha2.ClusterSpec) string {
+		pod, err := NewNatsPodSpec("nats", podName, "example-nats", cs, metav1.OwnerReference{})
+		if err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+		return GetPodSpecHash(pod)
+	}
+	baseHash := podSpecHash(t, "example-nats-1", base)
+	if baseHash == "" {
+		t.Fatalf("Expected the pod spec hash to be set")
+	}
+
+	tests := []struct {
+		name    string
+		podName string
+		mutate  func(*v1alpha2.ClusterSpec)
+		changed bool
+	}{
+		{
+			name:    "different pod name",
+			podName: "example-nats-a",
+			mutate:  func(*v1alpha2.ClusterSpec) {},
+		},
+		{
+			name:    "different version",
+			podName: "example-nats-1",
+			mutate:  func(cs *v1alpha2.ClusterSpec) { cs.Version = "1.4.1" },
+		},
+		{
+			name:    "different size",
+			podName: "example-nats-1",
+			mutate:  func(cs *v1alpha2.ClusterSpec) { cs.Size = 5 },
+		},
+		{
+			name:    "different auth",
+			podName: "example-nats-1",
+			mutate:  func(cs *v1alpha2.ClusterSpec) { cs.Auth = &v1alpha2.AuthConfig{EnableServiceAccounts: true} },
+		},
+		{
+			name:    "different tls timeouts",
+			podName: "example-nats-1",
+			mutate: func(cs *v1alpha2.ClusterSpec) {
+				cs.TLS = &v1alpha2.TLSConfig{ClientsTLSTimeout: 5, RoutesTLSTimeout: 5}
+			},
+		},
+		{
+			name:    "default reloader image unset",
+			podName: "example-nats-1",
+			mutate: func(cs *v1alpha2.ClusterSpec) {
+				cs.Pod.ReloaderImage = ""
+				cs.Pod.ReloaderImageTag = ""
+			},
+		},
+		{
+			name:    "different server image",
+			podName: "example-nats-1",
+			mutate:  func(cs *v1alpha2.ClusterSpec) { cs.ServerImage = "example.com/nats" },
+			changed: true,
+		},
+		{
+			name:    "different resources",
+			podName: "example-nats-1",
+			mutate: func(cs *v1alpha2.ClusterSpec) {
+				cs.Pod.Resources = v1.ResourceRequirements{
+					Limits: v1.ResourceList{v1.ResourceMemory: resource.MustParse("1Gi")},
+				}
+			},
+			changed: true,
+		},
+		{
+			name:    "different reloader image tag",
+			podName: "example-nats-1",
+			mutate:  func(cs *v1alpha2.ClusterSpec) { cs.Pod.ReloaderImageTag = "latest" },
+			changed: true,
+		},
+		{
+			name:    "different tolerations",
+			podName: "example-nats-1",
+			mutate: func(cs *v1alpha2.ClusterSpec) {
+				cs.Pod.Tolerations = []v1.Toleration{{Key: "dedicated", Operator: v1.TolerationOpExists}}
+			},
+			changed: true,
+		},
+		{
+			name:    "tls enabled",
+			podName: "example-nats-1",
+			mutate:  func(cs *v1alpha2.ClusterSpec) { cs.TLS = &v1alpha2.TLSConfig{ServerSecret: "server-tls"} },
+			changed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := base.DeepCopy()
+			tt.mutate(cs)
+			hash := podSpecHash(t, tt.podName, *cs)
+			if tt.changed && hash == baseHash {
+				t.Errorf("Expected hash to change, got: %s", hash)
+			}
+			if !tt.changed && hash != baseHash {
+				t.Errorf("Expected %s, got: %s", baseHash, hash)
+			}
+		})
+	}
+}
+
+func TestNewNatsPodSpecStorage(t *testing.T) {
+	tests := []struct {
+		name      string
+		storage   *v1alpha2.StorageConfig
+		claimName string
+		mountPath string
+	}{
+		{
+			name: "no storage",
+		},
+		{
+			name:      "default mount path",
+			storage:   &v1alpha2.StorageConfig{Size: resource.MustParse("1Gi")},
+			claimName: "example-nats-a-data",
+			mountPath: "/data",
+		},
+		{
+			name:      "custom mount path",
+			storage:   &v1alpha2.StorageConfig{Size: resource.MustParse("1Gi"), MountPath: "/var/lib/nats"},
+			claimName: "example-nats-a-data",
+			mountPath: "/var/lib/nats",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := v1alpha2.ClusterSpec{Size: 3, Storage: tt.storage}
+			cs.SetDefaults()
+			pod, err := NewNatsPodSpec("nats", "example-nats-a", "example-nats", cs, metav1.OwnerReference{})
+			if err != nil {
+				t.Fatalf("Error: %s", err)
+			}
+
+			// Look for the data volume and for its mount in the "nats" container.
+			var (
+				claimName string
+				mountPath string
+			)
+			for _, volume := range pod.Spec.Volumes {
+				if volume.Name == constants.DataVolumeName && volume.PersistentVolumeClaim != nil {
+					claimName = volume.PersistentVolumeClaim.ClaimName
+				}
+			}
+			for _, container := range pod.Spec.Containers {
+				if container.Name != constants.NatsContainerName {
+					continue
+				}
+				for _, mount := range container.VolumeMounts {
+					if mount.Name == constants.DataVolumeName {
+						mountPath = mount.MountPath
+					}
+				}
+			}
+			if claimName != tt.claimName {
+				t.Errorf("Expected claim %q, got: %q", tt.claimName, claimName)
+			}
+			if mountPath != tt.mountPath {
+				t.Errorf("Expected mount path %q, got: %q", tt.mountPath, mountPath)
+			}
+		})
+	}
+}
+
+func TestCreatePersistentVolumeClaim(t *testing.T) {
+	storageClassName := "standard"
+	storage := &v1alpha2.StorageConfig{
+		StorageClassName: &storageClassName,
+		Size:             resource.MustParse("10Gi"),
+		AccessModes:      []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+	}
+	owner := metav1.OwnerReference{Name: "example-nats", UID: "8d6a1f2b"}
+	kubeClient := fake.NewSimpleClientset()
+
+	// Creating the claim twice (e.g. when replacing a member) must keep the existing claim.
+	for i := 0; i < 2; i++ {
+		if err := CreatePersistentVolumeClaim(kubeClient.CoreV1(), "example-nats-1", "example-nats", "nats", storage, owner); err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+	}
+
+	pvc, err := kubeClient.CoreV1().PersistentVolumeClaims("nats").Get(PersistentVolumeClaimName("example-nats-1"), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if pvc.Name != "example-nats-1-data" {
+		t.Errorf("Expected %q, got: %q", "example-nats-1-data", pvc.Name)
+	}
+	if !reflect.DeepEqual(pvc.Labels, LabelsForCluster("example-nats")) {
+		t.Errorf("Expected %+v, got: %+v", LabelsForCluster("example-nats"), pvc.Labels)
+	}
+	if !reflect.DeepEqual(pvc.OwnerReferences, []metav1.OwnerReference{owner}) {
+		t.Errorf("Expected %+v, got: %+v", []metav1.OwnerReference{owner}, pvc.OwnerReferences)
+	}
+	if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName != storageClassName {
+		t.Errorf("Expected storage class %q, got: %v", storageClassName, pvc.Spec.StorageClassName)
+	}
+	if size := pvc.Spec.Resources.Requests[v1.ResourceStorage]; size.Cmp(storage.Size) != 0 {
+		t.Errorf("Expected size %s, got: %s", storage.Size.String(), size.String())
+	}
+}
+
+func TestPodWithTopologyDomains(t *testing.T) {
+	zones := v1.NodeSelectorRequirement{Key: "zone", Operator: v1.NodeSelectorOpIn, Values: []string{"a", "b"}}
+	anyRegion := v1.NodeSelectorRequirement{Key: "region", Operator: v1.NodeSelectorOpExists}
+	gpu := v1.NodeSelectorRequirement{Key: "gpu", Operator: v1.NodeSelectorOpDoesNotExist}
+
+	tests := []struct {
+		name        string
+		affinity    *v1.Affinity
+		topologyKey string
+		domains     []string
+		required    bool
+		expected    *v1.Affinity
+	}{
+		{
+			name:        "required domains",
+			topologyKey: "zone",
+			domains:     []string{"a", "b"},
+			required:    true,
+			expected: &v1.Affinity{NodeAffinity: &v1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{NodeSelectorTerms: []v1.NodeSelectorTerm{
+					{MatchExpressions: []v1.NodeSelectorRequirement{zones}},
+				}},
+			}},
+		},
+		{
+			name:        "required without domains",
+			topologyKey: "region",
+			required:    true,
+			expected: &v1.Affinity{NodeAffinity: &v1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{NodeSelectorTerms: []v1.NodeSelectorTerm{
+					{MatchExpressions: []v1.NodeSelectorRequirement{anyRegion}},
+				}},
+			}},
+		},
+		{
+			name:        "preferred domains",
+			topologyKey: "zone",
+			domains:     []string{"a", "b"},
+			expected: &v1.Affinity{NodeAffinity: &v1.NodeAffinity{
+				PreferredDuringSchedulingIgnoredDuringExecution: []v1.PreferredSchedulingTerm{
+					{Weight: 100, Preference: v1.NodeSelectorTerm{MatchExpressions: []v1.NodeSelectorRequirement{zones}}},
+				},
+			}},
+		},
+		{
+			name: "required domains added to every existing term",
+			affinity: &v1.Affinity{NodeAffinity: &v1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{NodeSelectorTerms: []v1.NodeSelectorTerm{
+					{MatchExpressions: []v1.NodeSelectorRequirement{anyRegion}},
+					{MatchExpressions: []v1.NodeSelectorRequirement{gpu}},
+				}},
+			}},
+			topologyKey: "zone",
+			domains:     []string{"a", "b"},
+			required:    true,
+			expected: &v1.Affinity{NodeAffinity: &v1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{NodeSelectorTerms: []v1.NodeSelectorTerm{
+					{MatchExpressions: []v1.NodeSelectorRequirement{anyRegion, zones}},
+					{MatchExpressions: []v1.NodeSelectorRequirement{gpu, zones}},
+				}},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &v1.Pod{Spec: v1.PodSpec{Affinity: tt.affinity}}
+			PodWithTopologyDomains(pod, tt.topologyKey, tt.domains, tt.required)
+			if !reflect.DeepEqual(pod.Spec.Affinity, tt.expected) {
+				t.Errorf("Expected %+v, got: %+v", tt.expected, pod.Spec.Affinity)
+			}
+			// Only required domains restrict where the pod may be scheduled.
+			var expected []string
+			if tt.required {
+				expected = tt.domains
+			}
+			if domains := GetTopologyDomains(pod, tt.topologyKey); !reflect.DeepEqual(domains, expected) {
+				t.Errorf("Expected domains %v, got: %v", expected, domains)
+			}
+		})
+	}
+}
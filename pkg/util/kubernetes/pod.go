@@ -149,6 +149,43 @@ func natsLivenessProbe(cs v1alpha2.ClusterSpec) *v1.Probe {
 	}
 }
 
+// natsReadinessProbe returns a readiness probe for the NATS container which queries the readiness check served by the reloader sidecar.
+func natsReadinessProbe(p *v1alpha2.ReadinessProbePolicy) *v1.Probe {
+	return &v1.Probe{
+		Handler: v1.Handler{
+			HTTPGet: &v1.HTTPGetAction{
+				Path: constants.ReadinessPath,
+				Port: intstr.IntOrString{IntVal: constants.ReadinessPort},
+			},
+		},
+		InitialDelaySeconds: p.InitialDelaySeconds,
+		TimeoutSeconds:      p.TimeoutSeconds,
+		PeriodSeconds:       p.PeriodSeconds,
+		SuccessThreshold:    p.SuccessThreshold,
+		FailureThreshold:    p.FailureThreshold,
+	}
+}
+
+// reloaderContainerWithReadinessCheck makes the specified reloader container serve the readiness check of the NATS server.
+func reloaderContainerWithReadinessCheck(c v1.Container, cs v1alpha2.ClusterSpec) v1.Container {
+	scheme := "http"
+	if cs.TLS != nil && cs.TLS.EnableHttps {
+		scheme = "https"
+	}
+	c.Command = append(c.Command,
+		"-readiness-addr",
+		fmt.Sprintf(":%d", constants.ReadinessPort),
+		"-monitoring-url",
+		fmt.Sprintf("%s://localhost:%d", scheme, constants.MonitoringPort),
+	)
+	c.Ports = append(c.Ports, v1.ContainerPort{
+		Name:          "readiness",
+		ContainerPort: int32(constants.ReadinessPort),
+		Protocol:      v1.ProtocolTCP,
+	})
+	return c
+}
+
 // PodWithAntiAffinity sets pod anti-affinity with the pods in the same NATS cluster
 func PodWithAntiAffinity(pod *v1.Pod, clusterName string) *v1.Pod {
 	ls := &metav1.LabelSelector{MatchLabels: map[string]string{
@@ -173,12 +210,98 @@ func podWithAntiAffinity(pod *v1.Pod, ls *metav1.LabelSelector) *v1.Pod {
 	return pod
 }
 
+// podWithTopology sets pod anti-affinity with the pods in the same NATS cluster based on the specified topology policy.
+func podWithTopology(pod *v1.Pod, clusterName string, topology *v1alpha2.TopologyPolicy) *v1.Pod {
+	ls := &metav1.LabelSelector{MatchLabels: map[string]string{
+		LabelClusterNameKey: clusterName,
+	}}
+	antiAffinity := &v1.PodAntiAffinity{}
+	for _, term := range topology.AntiAffinity {
+		podAffinityTerm := v1.PodAffinityTerm{
+			LabelSelector: ls,
+			TopologyKey:   term.TopologyKey,
+		}
+		switch term.Mode {
+		case v1alpha2.AntiAffinityModeRequired:
+			antiAffinity.RequiredDuringSchedulingIgnoredDuringExecution = append(antiAffinity.RequiredDuringSchedulingIgnoredDuringExecution, podAffinityTerm)
+		default:
+			antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution, v1.WeightedPodAffinityTerm{
+				Weight:          term.Weight,
+				PodAffinityTerm: podAffinityTerm,
+			})
+		}
+	}
+	if len(antiAffinity.RequiredDuringSchedulingIgnoredDuringExecution) == 0 && len(antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution) == 0 {
+		return pod
+	}
+
+	pod.Spec.Affinity = &v1.Affinity{
+		PodAntiAffinity: antiAffinity,
+	}
+	return pod
+}
+
+// PodWithTopologyDomains requires (or, if required is false, prefers) the specified pod to be scheduled on a node in one of the specified domains of the specified topology key.
+// If no domains are specified, the pod is required (or prefers) to be scheduled on a node with the topology key.
+// This is how spread constraints are enforced, as there is no way of expressing them directly in the version of the Kubernetes API nats-operator is built against.
+func PodWithTopologyDomains(pod *v1.Pod, topologyKey string, domains []string, required bool) *v1.Pod {
+	requirement := v1.NodeSelectorRequirement{
+		Key:      topologyKey,
+		Operator: v1.NodeSelectorOpIn,
+		Values:   domains,
+	}
+	if len(domains) == 0 {
+		requirement.Operator = v1.NodeSelectorOpExists
+		requirement.Values = nil
+	}
+	if pod.Spec.Affinity == nil {
+		pod.Spec.Affinity = &v1.Affinity{}
+	}
+	if pod.Spec.Affinity.NodeAffinity == nil {
+		pod.Spec.Affinity.NodeAffinity = &v1.NodeAffinity{}
+	}
+	nodeAffinity := pod.Spec.Affinity.NodeAffinity
+	if !required {
+		nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution, v1.PreferredSchedulingTerm{
+			Weight:     100,
+			Preference: v1.NodeSelectorTerm{MatchExpressions: []v1.NodeSelectorRequirement{requirement}},
+		})
+		return pod
+	}
+	// Node selector terms are ORed, so the requirement is added to each of them.
+	if nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &v1.NodeSelector{NodeSelectorTerms: []v1.NodeSelectorTerm{{}}}
+	}
+	terms := nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	for i := range terms {
+		terms[i].MatchExpressions = append(terms[i].MatchExpressions, requirement)
+	}
+	return pod
+}
+
+// GetTopologyDomains returns the domains of the specified topology key to which the scheduling of the specified pod has been restricted using PodWithTopologyDomains, or nil if it hasn't.
+func GetTopologyDomains(pod *v1.Pod, topologyKey string) []string {
+	if pod.Spec.Affinity == nil || pod.Spec.Affinity.NodeAffinity == nil || pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return nil
+	}
+	for _, term := range pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+		for _, requirement := range term.MatchExpressions {
+			if requirement.Key == topologyKey && requirement.Operator == v1.NodeSelectorOpIn {
+				return requirement.Values
+			}
+		}
+	}
+	return nil
+}
+
 func applyPodPolicy(clusterName string, pod *v1.Pod, policy *v1alpha2.PodPolicy) {
 	if policy == nil {
 		return
 	}
 
-	if policy.AntiAffinity {
+	if policy.Topology != nil {
+		pod = podWithTopology(pod, clusterName, policy.Topology)
+	} else if policy.AntiAffinity {
 		pod = PodWithAntiAffinity(pod, clusterName)
 	}
 
@@ -248,8 +371,8 @@ func WaitUntilPodCondition(ctx context.Context, kubeClient corev1.CoreV1Interfac
 	return nil
 }
 
-// isPodRunningAndReady returns whether the specified pod is running, ready and has its ".status.podIP" field populated.
-func isPodRunningAndReady(pod *v1.Pod) bool {
+// IsPodRunningAndReady returns whether the specified pod is running, ready and has its ".status.podIP" field populated.
+func IsPodRunningAndReady(pod *v1.Pod) bool {
 	return pod.Status.Phase == v1.PodRunning && podutil.IsPodReady(pod) && pod.Status.PodIP != ""
 }
 
@@ -263,7 +386,7 @@ func WaitUntilPodReady(ctx context.Context, kubeClient corev1.CoreV1Interface, p
 			return false, fmt.Errorf("pod %q has been deleted", ResourceKey(pod))
 		default:
 			pod = event.Object.(*v1.Pod)
-			return isPodRunningAndReady(pod), nil
+			return IsPodRunningAndReady(pod), nil
 		}
 	})
 }
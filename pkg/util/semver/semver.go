@@ -0,0 +1,153 @@
+// Copyright 2019 The nats-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semver
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Pattern is a regular expression matching versions of the form "MAJOR.MINOR.PATCH[-PRERELEASE][+BUILD]", optionally prefixed with "v".
+	Pattern = `^v?(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)(?:-([0-9A-Za-z.-]+))?(?:\+([0-9A-Za-z.-]+))?$`
+)
+
+var (
+	// semverRegex is the compiled form of Pattern.
+	semverRegex = regexp.MustCompile(Pattern)
+)
+
+// Version represents a semantic version (https://semver.org).
+type Version struct {
+	Major      int
+	Minor      int
+	Patch      int
+	PreRelease string
+	Build      string
+}
+
+// Parse parses the specified string as a semantic version.
+func Parse(s string) (*Version, error) {
+	m := semverRegex.FindStringSubmatch(s)
+	if m == nil {
+		return nil, fmt.Errorf("%q is not a valid semantic version", s)
+	}
+	// The regular expression guarantees that the major, minor and patch components are valid integers.
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+	patch, _ := strconv.Atoi(m[3])
+	return &Version{
+		Major:      major,
+		Minor:      minor,
+		Patch:      patch,
+		PreRelease: m[4],
+		Build:      m[5],
+	}, nil
+}
+
+// MustParse parses the specified string as a semantic version, panicking in case of an error.
+func MustParse(s string) *Version {
+	v, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// String returns the string representation of the version.
+func (v *Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.PreRelease != "" {
+		s += "-" + v.PreRelease
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 depending on whether v is lower than, equal to or greater than o.
+// Build metadata is ignored, as mandated by the specification.
+func (v *Version) Compare(o *Version) int {
+	if c := compareInts(v.Major, o.Major); c != 0 {
+		return c
+	}
+	if c := compareInts(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+	if c := compareInts(v.Patch, o.Patch); c != 0 {
+		return c
+	}
+	return comparePreReleases(v.PreRelease, o.PreRelease)
+}
+
+// LessThan returns whether v is lower than o.
+func (v *Version) LessThan(o *Version) bool {
+	return v.Compare(o) < 0
+}
+
+// AtLeast returns whether v is greater than or equal to o.
+func (v *Version) AtLeast(o *Version) bool {
+	return v.Compare(o) >= 0
+}
+
+// comparePreReleases compares two pre-release identifiers according to the rules in https://semver.org/#spec-item-11.
+func comparePreReleases(a, b string) int {
+	// A version without a pre-release identifier has higher precedence than one with a pre-release identifier.
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			// Both identifiers are numeric, and hence compared numerically.
+			if c := compareInts(an, bn); c != 0 {
+				return c
+			}
+		case aErr == nil:
+			// Numeric identifiers have lower precedence than alphanumeric ones.
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	// A larger set of pre-release fields has higher precedence than a smaller one when all the preceding identifiers are equal.
+	return compareInts(len(as), len(bs))
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
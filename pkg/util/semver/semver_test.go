@@ -0,0 +1,97 @@
+// Copyright 2019 The nats-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semver_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nats-io/nats-operator/pkg/util/semver"
+)
+
+// TestParse tests the "Parse" function.
+func TestParse(t *testing.T) {
+	tests := []struct {
+		description     string
+		str             string
+		expectedVersion *semver.Version
+		expectedError   error
+	}{
+		{
+			description:     "plain version",
+			str:             "1.4.0",
+			expectedVersion: &semver.Version{Major: 1, Minor: 4, Patch: 0},
+			expectedError:   nil,
+		},
+		{
+			description:     "version prefixed with \"v\"",
+			str:             "v2.0.0",
+			expectedVersion: &semver.Version{Major: 2, Minor: 0, Patch: 0},
+			expectedError:   nil,
+		},
+		{
+			description:     "version with pre-release and build metadata",
+			str:             "2.0.0-RC5+abc",
+			expectedVersion: &semver.Version{Major: 2, Minor: 0, Patch: 0, PreRelease: "RC5", Build: "abc"},
+			expectedError:   nil,
+		},
+		{
+			description:     "version missing the patch component",
+			str:             "1.4",
+			expectedVersion: nil,
+			expectedError:   fmt.Errorf("%q is not a valid semantic version", "1.4"),
+		},
+		{
+			description:     "version with leading zeros",
+			str:             "1.04.0",
+			expectedVersion: nil,
+			expectedError:   fmt.Errorf("%q is not a valid semantic version", "1.04.0"),
+		},
+		{
+			description:     "image tag",
+			str:             "latest",
+			expectedVersion: nil,
+			expectedError:   fmt.Errorf("%q is not a valid semantic version", "latest"),
+		},
+	}
+	for _, test := range tests {
+		v, err := semver.Parse(test.str)
+		assert.Equal(t, test.expectedVersion, v, "test case: %s", test.description)
+		assert.Equal(t, test.expectedError, err, "test case: %s", test.description)
+	}
+}
+
+// TestCompare tests the "Compare" function.
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.4.0", "1.4.0", 0},
+		{"1.4.0", "1.4.1", -1},
+		{"1.10.0", "1.9.0", 1},
+		{"2.0.0", "1.99.99", 1},
+		{"2.0.0-RC1", "2.0.0", -1},
+		{"2.0.0-RC1", "2.0.0-RC2", -1},
+		{"2.0.0-alpha.1", "2.0.0-alpha.beta", -1},
+		{"2.0.0-alpha", "2.0.0-alpha.1", -1},
+		{"1.4.0+a", "1.4.0+b", 0},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, semver.MustParse(test.a).Compare(semver.MustParse(test.b)), "%s <=> %s", test.a, test.b)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2019 The nats-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+
+	"github.com/nats-io/nats-operator/pkg/apis/nats/v1alpha2"
+)
+
+// jsonPatchOperation represents a single JSON patch (RFC 6902) operation.
+type jsonPatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// defaultNatsCluster sets default values for the unset fields of the NatsCluster resource contained in the specified admission request.
+func (s *Server) defaultNatsCluster(req *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+	if req.Operation != admissionv1beta1.Create && req.Operation != admissionv1beta1.Update {
+		return allowed()
+	}
+	cluster := &v1alpha2.NatsCluster{}
+	if err := json.Unmarshal(req.Object.Raw, cluster); err != nil {
+		return denied(fmt.Errorf("failed to decode natscluster: %v", err))
+	}
+
+	// Set default values and check whether anything has changed.
+	spec := cluster.Spec.DeepCopy()
+	spec.SetDefaults()
+	if reflect.DeepEqual(spec, &cluster.Spec) {
+		return allowed()
+	}
+
+	// Replace the whole spec with the defaulted one.
+	// The "add" operation is used as it also works in case ".spec" is absent from the object.
+	b, err := json.Marshal([]jsonPatchOperation{
+		{
+			Op:    "add",
+			Path:  "/spec",
+			Value: spec,
+		},
+	})
+	if err != nil {
+		return denied(fmt.Errorf("failed to encode patch: %v", err))
+	}
+	res := allowed()
+	patchType := admissionv1beta1.PatchTypeJSONPatch
+	res.Patch = b
+	res.PatchType = &patchType
+	return res
+}
@@ -0,0 +1,96 @@
+// Copyright 2019 The nats-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/nats-io/nats-operator/pkg/apis/nats/v1alpha2"
+	kubernetesutil "github.com/nats-io/nats-operator/pkg/util/kubernetes"
+)
+
+// validateNatsCluster decides on whether the NatsCluster resource contained in the specified admission request is valid.
+func (s *Server) validateNatsCluster(req *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+	cluster := &v1alpha2.NatsCluster{}
+	if err := json.Unmarshal(req.Object.Raw, cluster); err != nil {
+		return denied(fmt.Errorf("failed to decode natscluster: %v", err))
+	}
+
+	switch req.Operation {
+	case admissionv1beta1.Create:
+		if err := cluster.Spec.Validate(); err != nil {
+			return denied(err)
+		}
+	case admissionv1beta1.Update:
+		// Do not get in the way of resources that are being deleted, as these may still need to be updated (e.g. in order to have finalizers removed).
+		if cluster.DeletionTimestamp != nil {
+			return allowed()
+		}
+		old := &v1alpha2.NatsCluster{}
+		if err := json.Unmarshal(req.OldObject.Raw, old); err != nil {
+			return denied(fmt.Errorf("failed to decode natscluster: %v", err))
+		}
+		if err := cluster.Spec.ValidateUpdate(&old.Spec); err != nil {
+			return denied(err)
+		}
+	default:
+		return allowed()
+	}
+
+	// Make sure that the secrets containing TLS certificates exist.
+	if err := s.validateTLSSecrets(req.Namespace, cluster.Spec.TLS); err != nil {
+		return denied(err)
+	}
+	return allowed()
+}
+
+// validateTLSSecrets checks whether the secrets referenced by the specified TLS configuration exist in the specified namespace.
+// Unexpected errors while looking up secrets are logged but otherwise ignored, so that temporary failures do not prevent resources from being admitted.
+func (s *Server) validateTLSSecrets(namespace string, tls *v1alpha2.TLSConfig) error {
+	if tls == nil {
+		return nil
+	}
+	for _, name := range []string{tls.ServerSecret, tls.RoutesSecret} {
+		if len(name) == 0 {
+			continue
+		}
+		if _, err := s.kubeClient.CoreV1().Secrets(namespace).Get(name, metav1.GetOptions{}); err != nil {
+			if kubernetesutil.IsKubernetesResourceNotFoundError(err) {
+				return fmt.Errorf("spec: tls: secret %q does not exist", name)
+			}
+			s.logger.Warnf("failed to get secret %q in namespace %q: %v", name, namespace, err)
+		}
+	}
+	return nil
+}
+
+// validateNatsServiceRole decides on whether the NatsServiceRole resource contained in the specified admission request is valid.
+func (s *Server) validateNatsServiceRole(req *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+	if req.Operation != admissionv1beta1.Create && req.Operation != admissionv1beta1.Update {
+		return allowed()
+	}
+	role := &v1alpha2.NatsServiceRole{}
+	if err := json.Unmarshal(req.Object.Raw, role); err != nil {
+		return denied(fmt.Errorf("failed to decode natsservicerole: %v", err))
+	}
+	if err := role.Spec.Validate(); err != nil {
+		return denied(err)
+	}
+	return allowed()
+}
@@ -0,0 +1,161 @@
+// Copyright 2019 The nats-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// DefaultNatsClusterPath is the path on which default values are set for NatsCluster resources.
+	DefaultNatsClusterPath = "/default-natscluster"
+	// ValidateNatsClusterPath is the path on which admission requests for NatsCluster resources are validated.
+	ValidateNatsClusterPath = "/validate-natscluster"
+	// ValidateNatsServiceRolePath is the path on which admission requests for NatsServiceRole resources are validated.
+	ValidateNatsServiceRolePath = "/validate-natsservicerole"
+
+	// maxRequestBodySize is the maximum size of the body of an admission request we are willing to read.
+	maxRequestBodySize = 3 * 1024 * 1024
+	// shutdownTimeout is the maximum amount of time we wait for in-flight admission requests to be served when shutting down.
+	shutdownTimeout = 5 * time.Second
+)
+
+// admitFunc is a function that decides on whether a given admission request should be allowed.
+type admitFunc func(*admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse
+
+// Server serves the admission webhooks for the resources managed by nats-operator.
+type Server struct {
+	// kubeClient is the client used to lookup resources referenced by the objects under review.
+	kubeClient kubernetes.Interface
+	// logger is the logger used by the server.
+	logger *logrus.Entry
+	// mux routes admission requests to the appropriate handler.
+	mux *http.ServeMux
+}
+
+// NewServer returns a new admission webhook server which uses the specified client to lookup resources.
+func NewServer(kubeClient kubernetes.Interface) *Server {
+	s := &Server{
+		kubeClient: kubeClient,
+		logger:     logrus.WithField("pkg", "webhook"),
+		mux:        http.NewServeMux(),
+	}
+	s.mux.HandleFunc(DefaultNatsClusterPath, s.handlerFor(s.defaultNatsCluster))
+	s.mux.HandleFunc(ValidateNatsClusterPath, s.handlerFor(s.validateNatsCluster))
+	s.mux.HandleFunc(ValidateNatsServiceRolePath, s.handlerFor(s.validateNatsServiceRole))
+	return s
+}
+
+// ServeHTTP routes the specified request to the appropriate handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Run serves admission requests over TLS on the specified address until the specified context is cancelled.
+func (s *Server) Run(ctx context.Context, addr, certFile, keyFile string) error {
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: s,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, fn := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer fn()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			s.logger.Errorf("failed to shutdown webhook server: %v", err)
+		}
+	}()
+	s.logger.Infof("serving admission webhooks on %s", addr)
+	if err := srv.ListenAndServeTLS(certFile, keyFile); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// handlerFor returns an HTTP handler that decodes admission reviews, passes the enclosed request to the specified function and writes back its response.
+func (s *Server) handlerFor(admit admitFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, fmt.Sprintf("unsupported method %q", r.Method), http.StatusMethodNotAllowed)
+			return
+		}
+		if contentType := r.Header.Get("Content-Type"); contentType != "application/json" {
+			http.Error(w, fmt.Sprintf("unsupported content type %q", contentType), http.StatusUnsupportedMediaType)
+			return
+		}
+
+		// Decode the admission review contained in the body of the request.
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to read request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		review := admissionv1beta1.AdmissionReview{}
+		if err := json.Unmarshal(body, &review); err != nil {
+			http.Error(w, fmt.Sprintf("failed to decode admission review: %v", err), http.StatusBadRequest)
+			return
+		}
+		if review.Request == nil {
+			http.Error(w, "admission review contains no request", http.StatusBadRequest)
+			return
+		}
+
+		// Decide on the request and write back the admission review containing the response.
+		// The request is stripped from the admission review in order to avoid echoing back the objects under review.
+		res := admit(review.Request)
+		res.UID = review.Request.UID
+		review.Request = nil
+		review.Response = res
+		b, err := json.Marshal(review)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to encode admission review: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write(b); err != nil {
+			s.logger.Errorf("failed to write admission review: %v", err)
+		}
+	}
+}
+
+// allowed returns an admission response allowing the request under review.
+func allowed() *admissionv1beta1.AdmissionResponse {
+	return &admissionv1beta1.AdmissionResponse{
+		Allowed: true,
+	}
+}
+
+// denied returns an admission response denying the request under review because of the specified error.
+func denied(err error) *admissionv1beta1.AdmissionResponse {
+	return &admissionv1beta1.AdmissionResponse{
+		Allowed: false,
+		Result: &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Reason:  metav1.StatusReasonInvalid,
+			Message: err.Error(),
+			Code:    http.StatusUnprocessableEntity,
+		},
+	}
+}
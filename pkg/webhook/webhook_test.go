@@ -0,0 +1,376 @@
+// Copyright 2019 The nats-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/nats-io/nats-operator/pkg/apis/nats/v1alpha2"
+)
+
+const (
+	// testNamespace is the namespace in which the objects under review live.
+	testNamespace = "nats"
+	// testUID is the UID of the admission requests sent to the server.
+	testUID = types.UID("4a8f2c1e")
+)
+
+// newTestServer returns an admission webhook server backed by a fake clientset containing the specified objects.
+func newTestServer(objects ...runtime.Object) *Server {
+	return NewServer(fake.NewSimpleClientset(objects...))
+}
+
+// newTestSecret returns a secret with the specified name in the test namespace.
+func newTestSecret(name string) *v1.Secret {
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+	}
+}
+
+// mustMarshal returns the JSON representation of the specified value.
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	return b
+}
+
+// review sends an admission review containing the specified request to the specified path of the specified server, and returns the response it contains.
+func review(t *testing.T, s *Server, path string, req *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+	req.UID = testUID
+	req.Namespace = testNamespace
+	body := mustMarshal(t, admissionv1beta1.AdmissionReview{Request: req})
+	r := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got: %d (%s)", http.StatusOK, w.Code, w.Body.String())
+	}
+	res := admissionv1beta1.AdmissionReview{}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if res.Request != nil {
+		t.Errorf("Expected the request not to be echoed back")
+	}
+	if res.Response == nil {
+		t.Fatalf("Expected a response")
+	}
+	if res.Response.UID != testUID {
+		t.Errorf("Expected UID %q, got: %q", testUID, res.Response.UID)
+	}
+	return res.Response
+}
+
+// checkResponse checks whether the specified response allows the request, or denies it with a message starting with the specified one.
+func checkResponse(t *testing.T, res *admissionv1beta1.AdmissionResponse, err string) {
+	if err == "" {
+		if !res.Allowed {
+			t.Errorf("Expected the request to be allowed, got: %+v", res.Result)
+		}
+		return
+	}
+	if res.Allowed {
+		t.Fatalf("Expected the request to be denied with %q", err)
+	}
+	if res.Result == nil || !strings.HasPrefix(res.Result.Message, err) {
+		t.Errorf("Expected error %q, got: %+v", err, res.Result)
+	}
+	if res.Result != nil && res.Result.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected code %d, got: %d", http.StatusUnprocessableEntity, res.Result.Code)
+	}
+}
+
+func TestValidateNatsCluster(t *testing.T) {
+	tests := []struct {
+		name      string
+		operation admissionv1beta1.Operation
+		spec      v1alpha2.ClusterSpec
+		oldSpec   *v1alpha2.ClusterSpec
+		deleting  bool
+		err       string
+	}{
+		{
+			name:      "valid create",
+			operation: admissionv1beta1.Create,
+			spec:      v1alpha2.ClusterSpec{Size: 3, Version: "1.4.1"},
+		},
+		{
+			name:      "invalid create",
+			operation: admissionv1beta1.Create,
+			spec:      v1alpha2.ClusterSpec{Size: 0},
+			err:       "spec: size must be a positive integer",
+		},
+		{
+			name:      "create with existing tls secrets",
+			operation: admissionv1beta1.Create,
+			spec:      v1alpha2.ClusterSpec{Size: 3, TLS: &v1alpha2.TLSConfig{ServerSecret: "server-tls", RoutesSecret: "routes-tls"}},
+		},
+		{
+			name:      "create with missing server tls secret",
+			operation: admissionv1beta1.Create,
+			spec:      v1alpha2.ClusterSpec{Size: 3, TLS: &v1alpha2.TLSConfig{ServerSecret: "missing"}},
+			err:       `spec: tls: secret "missing" does not exist`,
+		},
+		{
+			name:      "create with missing routes tls secret",
+			operation: admissionv1beta1.Create,
+			spec:      v1alpha2.ClusterSpec{Size: 3, TLS: &v1alpha2.TLSConfig{ServerSecret: "server-tls", RoutesSecret: "missing"}},
+			err:       `spec: tls: secret "missing" does not exist`,
+		},
+		{
+			name:      "valid update",
+			operation: admissionv1beta1.Update,
+			spec:      v1alpha2.ClusterSpec{Size: 5, Version: "1.4.1"},
+			oldSpec:   &v1alpha2.ClusterSpec{Size: 3, Version: "1.4.0"},
+		},
+		{
+			name:      "downgrade across major versions",
+			operation: admissionv1beta1.Update,
+			spec:      v1alpha2.ClusterSpec{Size: 3, Version: "1.4.1"},
+			oldSpec:   &v1alpha2.ClusterSpec{Size: 3, Version: "2.0.0"},
+			err:       "spec: downgrading from version 2.0.0 to 1.4.1 is not supported",
+		},
+		{
+			name:      "invalid update of a cluster being deleted",
+			operation: admissionv1beta1.Update,
+			spec:      v1alpha2.ClusterSpec{Size: 0},
+			oldSpec:   &v1alpha2.ClusterSpec{Size: 3},
+			deleting:  true,
+		},
+		{
+			name:      "delete",
+			operation: admissionv1beta1.Delete,
+			spec:      v1alpha2.ClusterSpec{Size: 0},
+		},
+	}
+
+	s := newTestServer(newTestSecret("server-tls"), newTestSecret("routes-tls"))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := &v1alpha2.NatsCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "example-nats", Namespace: testNamespace},
+				Spec:       tt.spec,
+			}
+			if tt.deleting {
+				now := metav1.Now()
+				cluster.DeletionTimestamp = &now
+			}
+			req := &admissionv1beta1.AdmissionRequest{
+				Operation: tt.operation,
+				Object:    runtime.RawExtension{Raw: mustMarshal(t, cluster)},
+			}
+			if tt.oldSpec != nil {
+				old := cluster.DeepCopy()
+				old.DeletionTimestamp = nil
+				old.Spec = *tt.oldSpec
+				req.OldObject = runtime.RawExtension{Raw: mustMarshal(t, old)}
+			}
+			checkResponse(t, review(t, s, ValidateNatsClusterPath, req), tt.err)
+		})
+	}
+}
+
+func TestValidateNatsServiceRole(t *testing.T) {
+	tests := []struct {
+		name        string
+		operation   admissionv1beta1.Operation
+		permissions v1alpha2.Permissions
+		err         string
+	}{
+		{
+			name:        "valid create",
+			operation:   admissionv1beta1.Create,
+			permissions: v1alpha2.Permissions{Publish: []string{"foo.>"}, Subscribe: []string{"bar.*"}},
+		},
+		{
+			name:        "invalid create",
+			operation:   admissionv1beta1.Create,
+			permissions: v1alpha2.Permissions{Publish: []string{"foo.>.bar"}},
+			err:         "spec: permissions: publish: subject \"foo.>.bar\"",
+		},
+		{
+			name:        "invalid update",
+			operation:   admissionv1beta1.Update,
+			permissions: v1alpha2.Permissions{Subscribe: []string{"foo*"}},
+			err:         "spec: permissions: subscribe: subject \"foo*\"",
+		},
+		{
+			name:        "delete",
+			operation:   admissionv1beta1.Delete,
+			permissions: v1alpha2.Permissions{Publish: []string{""}},
+		},
+	}
+
+	s := newTestServer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			role := &v1alpha2.NatsServiceRole{
+				ObjectMeta: metav1.ObjectMeta{Name: "nats-user", Namespace: testNamespace},
+				Spec:       v1alpha2.ServiceRoleSpec{Permissions: tt.permissions},
+			}
+			req := &admissionv1beta1.AdmissionRequest{
+				Operation: tt.operation,
+				Object:    runtime.RawExtension{Raw: mustMarshal(t, role)},
+			}
+			checkResponse(t, review(t, s, ValidateNatsServiceRolePath, req), tt.err)
+		})
+	}
+}
+
+func TestHandlerRejectsMalformedRequests(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		code        int
+	}{
+		{
+			name:        "wrong method",
+			method:      http.MethodGet,
+			contentType: "application/json",
+			code:        http.StatusMethodNotAllowed,
+		},
+		{
+			name:        "wrong content type",
+			method:      http.MethodPost,
+			contentType: "text/plain",
+			body:        "{}",
+			code:        http.StatusUnsupportedMediaType,
+		},
+		{
+			name:        "malformed body",
+			method:      http.MethodPost,
+			contentType: "application/json",
+			body:        "{",
+			code:        http.StatusBadRequest,
+		},
+		{
+			name:        "no request",
+			method:      http.MethodPost,
+			contentType: "application/json",
+			body:        "{}",
+			code:        http.StatusBadRequest,
+		},
+	}
+
+	s := newTestServer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, ValidateNatsClusterPath, strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+			if w.Code != tt.code {
+				t.Errorf("Expected status %d, got: %d", tt.code, w.Code)
+			}
+		})
+	}
+}
+
+func TestDefaultNatsCluster(t *testing.T) {
+	defaulted := v1alpha2.ClusterSpec{Size: 3}
+	defaulted.SetDefaults()
+
+	tests := []struct {
+		name      string
+		operation admissionv1beta1.Operation
+		spec      v1alpha2.ClusterSpec
+		patch     bool
+	}{
+		{
+			name:      "create",
+			operation: admissionv1beta1.Create,
+			spec:      v1alpha2.ClusterSpec{Size: 3},
+			patch:     true,
+		},
+		{
+			name:      "update",
+			operation: admissionv1beta1.Update,
+			spec:      v1alpha2.ClusterSpec{Size: 3, Version: "v" + defaulted.Version},
+			patch:     true,
+		},
+		{
+			name:      "already defaulted",
+			operation: admissionv1beta1.Create,
+			spec:      defaulted,
+		},
+		{
+			name:      "delete",
+			operation: admissionv1beta1.Delete,
+			spec:      v1alpha2.ClusterSpec{Size: 3},
+		},
+	}
+
+	s := newTestServer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := &v1alpha2.NatsCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "example-nats", Namespace: testNamespace},
+				Spec:       tt.spec,
+			}
+			req := &admissionv1beta1.AdmissionRequest{
+				Operation: tt.operation,
+				Object:    runtime.RawExtension{Raw: mustMarshal(t, cluster)},
+			}
+			res := review(t, s, DefaultNatsClusterPath, req)
+			checkResponse(t, res, "")
+			if !tt.patch {
+				if res.Patch != nil || res.PatchType != nil {
+					t.Errorf("Expected no patch, got: %s", string(res.Patch))
+				}
+				return
+			}
+			if res.PatchType == nil || *res.PatchType != admissionv1beta1.PatchTypeJSONPatch {
+				t.Errorf("Expected patch type %q, got: %v", admissionv1beta1.PatchTypeJSONPatch, res.PatchType)
+			}
+			// Decode the patch keeping the value as raw JSON so that it can be decoded into a spec.
+			var ops []struct {
+				Op    string          `json:"op"`
+				Path  string          `json:"path"`
+				Value json.RawMessage `json:"value"`
+			}
+			if err := json.Unmarshal(res.Patch, &ops); err != nil {
+				t.Fatalf("Error: %s", err)
+			}
+			if len(ops) != 1 || ops[0].Op != "add" || ops[0].Path != "/spec" {
+				t.Fatalf("Expected a single \"add\" operation on \"/spec\", got: %s", string(res.Patch))
+			}
+			spec := v1alpha2.ClusterSpec{}
+			if err := json.Unmarshal(ops[0].Value, &spec); err != nil {
+				t.Fatalf("Error: %s", err)
+			}
+			if !reflect.DeepEqual(spec, defaulted) {
+				t.Errorf("Expected %+v, got: %+v", defaulted, spec)
+			}
+		})
+	}
+}
@@ -125,3 +125,60 @@ func TestLameDuckModeWhenScalingDown(t *testing.T) {
 		}
 	}
 }
+
+// TestLameDuckModeWhenDeletingCluster creates a NatsCluster resource with three members and waits for the full mesh to be formed.
+// Then, it deletes the NatsCluster resource and makes sure that every pod has been placed in the "lame duck" mode before being deleted.
+func TestLameDuckModeWhenDeletingCluster(t *testing.T) {
+	var (
+		size = 3
+		// TODO Replace with an adequate stable tag once there is one.
+		version = "5d86964"
+		// TODO Remove once the "nats" image has an adequate stable tag.
+		serverImage = "natsop2018/gnatsd"
+	)
+
+	// Create a NatsCluster resource with three members, removed in parallel on deletion.
+	natsCluster, err := f.CreateCluster(f.Namespace, "test-nats-", size, version, func(natsCluster *natsv1alpha2.NatsCluster) {
+		natsCluster.Spec.ServerImage = serverImage
+		natsCluster.Spec.Scaling = &natsv1alpha2.ScalingPolicy{
+			MaxParallelDeletions: size,
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait until the full mesh is formed.
+	ctx1, fn := context.WithTimeout(context.Background(), waitTimeout)
+	defer fn()
+	if err = f.WaitUntilFullMesh(ctx1, natsCluster, size); err != nil {
+		t.Fatal(err)
+	}
+
+	// For every pod, we wait for a log message indicating the "gnatsd" process has entered the "lame duck" mode.
+	var wg sync.WaitGroup
+	wg.Add(size)
+	errCh := make(chan error, size)
+	for idx := 1; idx <= size; idx++ {
+		go func(idx int) {
+			defer wg.Done()
+			ctx, fn := context.WithTimeout(context.Background(), waitTimeout)
+			defer fn()
+			if err := f.WaitUntilPodLogLineMatches(ctx, natsCluster, idx, "Entering lame duck mode, stop accepting new clients"); err != nil {
+				errCh <- err
+			}
+		}(idx)
+	}
+
+	// Delete the NatsCluster resource.
+	if err = f.DeleteCluster(natsCluster); err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait for all the goroutines to terminate and make sure that all the pods have entered the "lame duck" mode.
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		t.Error(err)
+	}
+}
@@ -114,3 +114,52 @@ func TestResizeClusterFrom5To3(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// TestResizeClusterFrom1To5InParallel creates a NatsCluster resource with a single member and waits for it to become ready.
+// Then, it sets a size of 5 and a scaling policy allowing for four parallel creations in the NatsCluster resource, and waits for the scale-up operation to complete.
+func TestResizeClusterFrom1To5InParallel(t *testing.T) {
+	var (
+		initialSize = 1
+		finalSize   = 5
+		version     = "1.3.0"
+	)
+
+	var (
+		natsCluster *natsv1alpha2.NatsCluster
+		err         error
+	)
+
+	// Create a NatsCluster resource with a single member.
+	if natsCluster, err = f.CreateCluster(f.Namespace, "test-nats-", initialSize, version); err != nil {
+		t.Fatal(err)
+	}
+	// Make sure we cleanup the NatsCluster resource after we're done testing.
+	defer func() {
+		if err = f.DeleteCluster(natsCluster); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	// Wait until the single member is running with the initial size.
+	ctx1, fn := context.WithTimeout(context.Background(), waitTimeout)
+	defer fn()
+	if err = f.WaitUntilFullMeshWithVersion(ctx1, natsCluster, initialSize, version); err != nil {
+		t.Fatal(err)
+	}
+
+	// Scale the cluster up to five members, creating the four new members at once.
+	natsCluster.Spec.Size = finalSize
+	natsCluster.Spec.Scaling = &natsv1alpha2.ScalingPolicy{
+		MaxParallelCreations: finalSize - initialSize,
+	}
+	if natsCluster, err = f.PatchCluster(natsCluster); err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait until the full mesh is formed with the final size.
+	ctx2, fn := context.WithTimeout(context.Background(), waitTimeout)
+	defer fn()
+	if err = f.WaitUntilFullMeshWithVersion(ctx2, natsCluster, finalSize, version); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,102 @@
+package reloadertest
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/nats-io/nats-operator/pkg/reloader"
+)
+
+const readinessTestConfig = `{
+  "cluster": {
+    "routes": [
+      "nats://nats-1.nats-mgmt.default.svc:6222",
+      "nats://nats-2.nats-mgmt.default.svc:6222",
+      "nats://nats-3.nats-mgmt.default.svc:6222",
+      "nats://other-nats-mgmt:6222"
+    ]
+  }
+}`
+
+func TestReadinessChecker(t *testing.T) {
+	configfile, err := ioutil.TempFile(os.TempDir(), "nats-conf-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(configfile.Name())
+	if _, err := configfile.WriteString(readinessTestConfig); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fake monitoring endpoint reporting a configurable number of routes.
+	var numRoutes int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/varz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"server_id":"test"}`)
+	})
+	mux.HandleFunc("/routez", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"num_routes":%d}`, atomic.LoadInt32(&numRoutes))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	rc := natsreloader.NewReadinessChecker(&natsreloader.ReadinessConfig{
+		MonitoringURL: srv.URL,
+		ConfigFile:    configfile.Name(),
+		Hostname:      "nats-1",
+	})
+
+	// The server must be connected to "nats-2" and "nats-3" in order to be ready.
+	atomic.StoreInt32(&numRoutes, 1)
+	if err := rc.Check(context.Background()); err == nil {
+		t.Fatal("Expected server with missing routes not to be ready")
+	}
+	atomic.StoreInt32(&numRoutes, 2)
+	if err := rc.Check(context.Background()); err != nil {
+		t.Fatalf("Expected server to be ready, got: %s", err)
+	}
+
+	// Readiness must not be lost when members leave the cluster.
+	atomic.StoreInt32(&numRoutes, 0)
+	if err := rc.Check(context.Background()); err != nil {
+		t.Fatalf("Expected server to remain ready, got: %s", err)
+	}
+
+	// Readiness must be lost when the server stops responding.
+	srv.Close()
+	if err := rc.Check(context.Background()); err == nil {
+		t.Fatal("Expected unresponsive server not to be ready")
+	}
+}
+
+func TestReadinessCheckerWithoutOwnRoute(t *testing.T) {
+	configfile, err := ioutil.TempFile(os.TempDir(), "nats-conf-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(configfile.Name())
+	if _, err := configfile.WriteString(readinessTestConfig); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"server_id":"test","num_routes":3}`)
+	}))
+	defer srv.Close()
+
+	// The config file has not been updated with a route to "nats-4" yet.
+	rc := natsreloader.NewReadinessChecker(&natsreloader.ReadinessConfig{
+		MonitoringURL: srv.URL,
+		ConfigFile:    configfile.Name(),
+		Hostname:      "nats-4",
+	})
+	if err := rc.Check(context.Background()); err == nil {
+		t.Fatal("Expected server missing from the config file not to be ready")
+	}
+}